@@ -7,12 +7,60 @@ type expressionKind uint
 const (
 	literalKind expressionKind = iota
 	binaryKind
+	parameterKind
+	castKind
+	callKind
 )
 
 type expression struct {
-	literal *token
-	binary  *binaryExpression
-	kind    expressionKind
+	literal   *token
+	binary    *binaryExpression
+	parameter *parameterExpression
+	cast      *castExpression
+	call      *callExpression
+	kind      expressionKind
+}
+
+func (exp *expression) isStringLiteral() bool {
+	return exp.kind == literalKind && exp.literal.kind == stringKind
+}
+
+// sourceToken returns the token that errors about the expression point
+// at.
+func (exp *expression) sourceToken() *token {
+	switch exp.kind {
+	case binaryKind:
+		return exp.binary.a.sourceToken()
+	case parameterKind:
+		return exp.parameter.placeholder
+	case castKind:
+		return exp.cast.exp.sourceToken()
+	case callKind:
+		return &exp.call.name
+	}
+
+	return exp.literal
+}
+
+// castExpression converts exp to a type, such as the string of a typed
+// literal like DATE '2026-10-18'.
+type castExpression struct {
+	exp       expression
+	datatype  token
+	modifiers []*token
+}
+
+// callExpression is a function call. Functions such as current_date
+// that are written without parentheses have no args.
+type callExpression struct {
+	name token
+	args []expression
+}
+
+// parameterExpression is a $n placeholder, index is n.
+type parameterExpression struct {
+	placeholder *token
+	index       uint
 }
 
 type binaryExpression struct {
@@ -25,6 +73,8 @@ const (
 	SelectKind AstKind = iota
 	CreateTableKind
 	InsertKind
+	CopyKind
+	SetKind
 )
 
 type InsertStatement struct {
@@ -32,9 +82,12 @@ type InsertStatement struct {
 	values *[]*expression
 }
 
+// columnDefinition is a column of a CREATE TABLE. modifiers are the
+// numbers in parentheses after the type, as in varchar(20).
 type columnDefinition struct {
-	name     token
-	datatype token
+	name      token
+	datatype  token
+	modifiers []*token
 }
 
 type CreateTableStatement struct {
@@ -58,10 +111,28 @@ type SelectStatement struct {
 	where *expression
 }
 
+type CopyStatement struct {
+	table     *token
+	query     *SelectStatement
+	from      bool
+	file      token
+	header    bool
+	delimiter rune
+}
+
+// SetStatement changes a session setting, value is a string, number or
+// identifier.
+type SetStatement struct {
+	name  token
+	value token
+}
+
 type Statement struct {
 	SelectStatement      *SelectStatement
 	CreateTableStatement *CreateTableStatement
 	InsertStatement      *InsertStatement
+	CopyStatement        *CopyStatement
+	SetStatement         *SetStatement
 	Kind                 AstKind
 }
 
@@ -1,25 +1,81 @@
 package gosql
 
+import (
+    "context"
+    "time"
+)
+
 type ColumnType uint
 
 const (
     TextType ColumnType = iota
     IntType
     BoolType
+    FloatType
+    SmallIntType
+    BigIntType
+    RealType
+    NumericType
+    VarcharType
+    CharType
+    DateType
+    TimeType
+    TimestampType
+    TimestampTzType
+    IntervalType
+    JsonType
+    JsonbType
+    ByteaType
 )
 
 type Cell interface {
     AsText() string
     AsInt() int32
     AsBool() bool
+    AsFloat() float64
+    AsBigInt() int64
+    AsTime() time.Time
+}
+
+// ResultColumn is an alias so that Results.Columns keeps its type.
+type ResultColumn = struct {
+    Type ColumnType
+    Name string
 }
 
 type Results struct {
-    Columns []struct {
-        Type ColumnType
-        Name string
+    Columns []ResultColumn
+    Rows    [][]Cell
+}
+
+// RowIterator is a cursor over the rows of a query. Rows are produced
+// as Next is called rather than all up front.
+type RowIterator interface {
+    Columns() []ResultColumn
+    // Next advances to the next row and reports whether there is one.
+    Next() bool
+    // Row returns the cells of the current row.
+    Row() []Cell
+    Err() error
+    Close() error
+}
+
+// Collect reads the remaining rows of an iterator into Results and
+// closes it.
+func Collect(rows RowIterator) (*Results, error) {
+    defer rows.Close()
+
+    results := &Results{Columns: rows.Columns(), Rows: [][]Cell{}}
+    for rows.Next() {
+        results.Rows = append(results.Rows, rows.Row())
+    }
+
+    err := rows.Err()
+    if err != nil {
+        return nil, err
     }
-    Rows [][]Cell
+
+    return results, nil
 }
 
 type Backend interface {
@@ -27,3 +83,111 @@ type Backend interface {
     Insert(*InsertStatement) error
     Select(*SelectStatement) (*Results, error)
 }
+
+// ContextBackend is a Backend whose statements can be canceled. The
+// methods give up with ErrQueryCanceled once the context is done,
+// including while rows are being read.
+type ContextBackend interface {
+    Backend
+    CreateTableContext(context.Context, *CreateTableStatement) error
+    InsertContext(context.Context, *InsertStatement) error
+    QueryContext(context.Context, *SelectStatement) (RowIterator, error)
+}
+
+// CopyBackend is a Backend that runs COPY statements.
+type CopyBackend interface {
+    Backend
+    Copy(*CopyStatement) (uint, error)
+    CopyContext(context.Context, *CopyStatement) (uint, error)
+}
+
+// DescribeBackend is a Backend that can report the types of the
+// parameters of a statement and the columns it returns without
+// executing it.
+type DescribeBackend interface {
+    Backend
+    Describe(*Statement) ([]ColumnType, []ResultColumn, error)
+}
+
+// WithContext returns b as a ContextBackend. A backend that is not one
+// has the context checked before each statement and its queries
+// collected with Select.
+func WithContext(b Backend) ContextBackend {
+    if cb, ok := b.(ContextBackend); ok {
+        return cb
+    }
+
+    return contextBackend{b}
+}
+
+type contextBackend struct {
+    Backend
+}
+
+func (b contextBackend) CreateTableContext(ctx context.Context, crt *CreateTableStatement) error {
+    err := checkContext(ctx)
+    if err != nil {
+        return err
+    }
+
+    return b.CreateTable(crt)
+}
+
+func (b contextBackend) InsertContext(ctx context.Context, inst *InsertStatement) error {
+    err := checkContext(ctx)
+    if err != nil {
+        return err
+    }
+
+    return b.Insert(inst)
+}
+
+func (b contextBackend) QueryContext(ctx context.Context, slct *SelectStatement) (RowIterator, error) {
+    err := checkContext(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    results, err := b.Select(slct)
+    if err != nil {
+        return nil, err
+    }
+
+    return &resultsRows{results: results}, nil
+}
+
+// resultsRows iterates over rows already collected in Results.
+type resultsRows struct {
+    results *Results
+    index   int
+    row     []Cell
+}
+
+func (r *resultsRows) Columns() []ResultColumn {
+    return r.results.Columns
+}
+
+func (r *resultsRows) Next() bool {
+    r.row = nil
+    if r.index >= len(r.results.Rows) {
+        return false
+    }
+
+    r.row = r.results.Rows[r.index]
+    r.index++
+    return true
+}
+
+func (r *resultsRows) Row() []Cell {
+    return r.row
+}
+
+func (r *resultsRows) Err() error {
+    return nil
+}
+
+func (r *resultsRows) Close() error {
+    r.row = nil
+    r.index = len(r.results.Rows)
+    return nil
+}
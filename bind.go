@@ -0,0 +1,272 @@
+package gosql
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+func (exp *expression) maxParameter() uint {
+	switch exp.kind {
+	case parameterKind:
+		return exp.parameter.index
+	case binaryKind:
+		return max(exp.binary.a.maxParameter(), exp.binary.b.maxParameter())
+	case castKind:
+		return exp.cast.exp.maxParameter()
+	case callKind:
+		n := uint(0)
+		for _, arg := range exp.call.args {
+			n = max(n, arg.maxParameter())
+		}
+		return n
+	}
+
+	return 0
+}
+
+func (slct *SelectStatement) maxParameter() uint {
+	n := uint(0)
+	if slct.item != nil {
+		for _, item := range *slct.item {
+			if item.exp != nil {
+				n = max(n, item.exp.maxParameter())
+			}
+		}
+	}
+
+	if slct.where != nil {
+		n = max(n, slct.where.maxParameter())
+	}
+
+	return n
+}
+
+// NumParameters returns the number of parameters the statement expects,
+// which is the highest $n placeholder it uses.
+func (stmt *Statement) NumParameters() int {
+	n := uint(0)
+
+	switch stmt.Kind {
+	case SelectKind:
+		n = stmt.SelectStatement.maxParameter()
+	case InsertKind:
+		if stmt.InsertStatement.values != nil {
+			for _, value := range *stmt.InsertStatement.values {
+				n = max(n, value.maxParameter())
+			}
+		}
+	case CopyKind:
+		if stmt.CopyStatement.query != nil {
+			n = stmt.CopyStatement.query.maxParameter()
+		}
+	}
+
+	return int(n)
+}
+
+// Numeric is a decimal number in text form, such as "12.50". Bound as a
+// parameter it keeps every digit where a float would be rounded.
+type Numeric string
+
+// parameterToken turns a Go value into the literal token it stands
+// for. The value never goes through the lexer so it cannot change the
+// structure of the statement.
+func parameterToken(value any, loc location) (*token, error) {
+	var i int64
+	switch v := value.(type) {
+	case string:
+		return &token{kind: stringKind, value: v, loc: loc}, nil
+	case []byte:
+		return &token{kind: stringKind, value: string(v), loc: loc}, nil
+	case bool:
+		if v {
+			return &token{kind: boolKind, value: string(trueKeyword), loc: loc}, nil
+		}
+		return &token{kind: boolKind, value: string(falseKeyword), loc: loc}, nil
+	case int:
+		i = int64(v)
+	case int8:
+		i = int64(v)
+	case int16:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint8:
+		i = int64(v)
+	case uint16:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case Numeric:
+		_, _, ok := parseDecimal(string(v))
+		if !ok {
+			return nil, ErrInvalidParameter
+		}
+		return &token{kind: numericKind, value: string(v), loc: loc}, nil
+	case float32:
+		return floatToken(float64(v), loc)
+	case float64:
+		return floatToken(v, loc)
+	case time.Time:
+		// Strings are read as dates and times where one is expected
+		date, era := formatDate(v)
+		return &token{kind: stringKind, value: date + v.Format(" 15:04:05.999999-07:00") + era, loc: loc}, nil
+	default:
+		return nil, ErrInvalidParameter
+	}
+
+	return &token{kind: numericKind, value: strconv.FormatInt(i, 10), loc: loc}, nil
+}
+
+func floatToken(f float64, loc location) (*token, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, ErrInvalidParameter
+	}
+
+	return &token{kind: numericKind, value: floatLiteral(f), loc: loc}, nil
+}
+
+func (exp *expression) bind(params []any) (*expression, error) {
+	switch exp.kind {
+	case parameterKind:
+		t, err := parameterToken(params[exp.parameter.index-1], exp.parameter.placeholder.loc)
+		if err != nil {
+			return nil, err
+		}
+
+		return &expression{literal: t, kind: literalKind}, nil
+
+	case binaryKind:
+		a, err := exp.binary.a.bind(params)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := exp.binary.b.bind(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return &expression{
+			binary: &binaryExpression{a: *a, b: *b, op: exp.binary.op},
+			kind:   binaryKind,
+		}, nil
+
+	case castKind:
+		inner, err := exp.cast.exp.bind(params)
+		if err != nil {
+			return nil, err
+		}
+
+		cast := *exp.cast
+		cast.exp = *inner
+		return &expression{cast: &cast, kind: castKind}, nil
+
+	case callKind:
+		call := *exp.call
+		call.args = []expression{}
+		for _, arg := range exp.call.args {
+			bound, err := arg.bind(params)
+			if err != nil {
+				return nil, err
+			}
+
+			call.args = append(call.args, *bound)
+		}
+
+		return &expression{call: &call, kind: callKind}, nil
+	}
+
+	return exp, nil
+}
+
+func (slct *SelectStatement) bind(params []any) (*SelectStatement, error) {
+	bound := *slct
+
+	if slct.item != nil {
+		items := []*selectItem{}
+		for _, item := range *slct.item {
+			boundItem := *item
+			if item.exp != nil {
+				exp, err := item.exp.bind(params)
+				if err != nil {
+					return nil, err
+				}
+				boundItem.exp = exp
+			}
+
+			items = append(items, &boundItem)
+		}
+
+		bound.item = &items
+	}
+
+	if slct.where != nil {
+		where, err := slct.where.bind(params)
+		if err != nil {
+			return nil, err
+		}
+		bound.where = where
+	}
+
+	return &bound, nil
+}
+
+// Bind returns a copy of the statement with the $n placeholders
+// replaced by the n-th value of params. Values may be strings, byte
+// slices, booleans, integers up to 64 bits wide, floats, Numerics or
+// times.
+func (stmt *Statement) Bind(params []any) (*Statement, error) {
+	if len(params) != stmt.NumParameters() {
+		return nil, ErrParameterCount
+	}
+
+	bound := *stmt
+
+	switch stmt.Kind {
+	case SelectKind:
+		slct, err := stmt.SelectStatement.bind(params)
+		if err != nil {
+			return nil, err
+		}
+		bound.SelectStatement = slct
+
+	case InsertKind:
+		if stmt.InsertStatement.values == nil {
+			break
+		}
+
+		inst := *stmt.InsertStatement
+		values := []*expression{}
+		for _, value := range *inst.values {
+			exp, err := value.bind(params)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, exp)
+		}
+
+		inst.values = &values
+		bound.InsertStatement = &inst
+
+	case CopyKind:
+		if stmt.CopyStatement.query == nil {
+			break
+		}
+
+		cp := *stmt.CopyStatement
+		query, err := cp.query.bind(params)
+		if err != nil {
+			return nil, err
+		}
+
+		cp.query = query
+		bound.CopyStatement = &cp
+	}
+
+	return &bound, nil
+}
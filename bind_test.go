@@ -0,0 +1,106 @@
+package gosql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	mb := newDumpFixture(t)
+
+	tests := []struct {
+		source  string
+		params  []ColumnType
+		columns []ResultColumn
+		err     error
+	}{
+		{
+			source:  "SELECT id, name AS fullname FROM users WHERE (id > $1) AND ($2 = name);",
+			params:  []ColumnType{IntType, TextType},
+			columns: []ResultColumn{{Type: IntType, Name: "id"}, {Type: TextType, Name: "fullname"}},
+		},
+		{
+			source:  "SELECT $1 + 1, $3 || 'a', $2 AND active FROM users;",
+			params:  []ColumnType{IntType, BoolType, TextType},
+			columns: []ResultColumn{{Type: IntType, Name: "?column?"}, {Type: TextType, Name: "?column?"}, {Type: BoolType, Name: "?column?"}},
+		},
+		{
+			source: "INSERT INTO users VALUES ($1, $2, $3);",
+			params: []ColumnType{IntType, TextType, BoolType},
+		},
+		{
+			source:  "SELECT $1 = $2, $2 = id FROM users;",
+			params:  []ColumnType{IntType, IntType},
+			columns: []ResultColumn{{Type: BoolType, Name: "?column?"}, {Type: BoolType, Name: "?column?"}},
+		},
+		{
+			source:  "SELECT $1::int + 1, CAST($2 AS boolean) FROM users;",
+			params:  []ColumnType{IntType, BoolType},
+			columns: []ResultColumn{{Type: IntType, Name: "?column?"}, {Type: BoolType, Name: "boolean"}},
+		},
+		{
+			source: "SELECT id + name FROM users;",
+			err:    ErrInvalidOperands,
+		},
+		{
+			source: "SELECT id FROM missing WHERE id = $1;",
+			err:    ErrTableDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+
+		params, columns, err := mb.Describe(ast.Statements[0])
+		if test.err == nil {
+			assert.Nil(t, err, test.source)
+		} else {
+			assert.ErrorIs(t, err, test.err, test.source)
+		}
+		assert.Equal(t, test.params, params, test.source)
+		assert.Equal(t, test.columns, columns, test.source)
+	}
+}
+
+func TestBind(t *testing.T) {
+	mb := newDumpFixture(t)
+
+	ast, err := Parse("SELECT name FROM users WHERE (id = $1) OR (name = $2);")
+	assert.Nil(t, err)
+	stmt := ast.Statements[0]
+	assert.Equal(t, 2, stmt.NumParameters())
+
+	_, err = mb.Select(stmt.SelectStatement)
+	assert.Equal(t, ErrUnboundParameter, err)
+
+	_, err = stmt.Bind([]any{1})
+	assert.Equal(t, ErrParameterCount, err)
+
+	_, err = stmt.Bind([]any{uint64(1), "x"})
+	assert.Equal(t, ErrInvalidParameter, err)
+
+	bound, err := stmt.Bind([]any{3, "' OR 'a' = 'a"})
+	assert.Nil(t, err)
+
+	results, err := mb.Select(bound.SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results.Rows))
+	assert.Equal(t, "", results.Rows[0][0].AsText())
+
+	// The original statement is left untouched
+	_, err = mb.Select(stmt.SelectStatement)
+	assert.Equal(t, ErrUnboundParameter, err)
+
+	ast, err = Parse("INSERT INTO users VALUES ($1, $2, $3);")
+	assert.Nil(t, err)
+
+	// Inserting without binding fails rather than skipping the values
+	assert.Equal(t, ErrUnboundParameter, mb.Insert(ast.Statements[0].InsertStatement))
+
+	bound, err = ast.Statements[0].Bind([]any{int32(4), "Bob", true})
+	assert.Nil(t, err)
+	assert.Nil(t, mb.Insert(bound.InsertStatement))
+	assert.Equal(t, "Bob", mb.tables["users"].rows[3][1].AsText())
+}
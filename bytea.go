@@ -0,0 +1,166 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// bytea values are stored as their bytes. Their text form is the hex
+// format PostgreSQL prints by default: \x and two hex digits a byte.
+
+func formatBytea(c Cell) string {
+	return `\x` + hex.EncodeToString([]byte(c.AsText()))
+}
+
+// parseBytea reads the hex format, or else the escape format.
+func parseBytea(s string) (MemoryCell, error) {
+	if strings.HasPrefix(s, `\x`) || strings.HasPrefix(s, `\X`) {
+		return decodeHex(s[2:])
+	}
+
+	return decodeEscape(s)
+}
+
+// decodeEscape reads the escape format, in which a backslash starts
+// either another backslash or the three octal digits of a byte.
+func decodeEscape(s string) (MemoryCell, error) {
+	b := []byte{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '\\' {
+			b = append(b, '\\')
+			i++
+			continue
+		}
+
+		if i+4 > len(s) {
+			return nil, ErrInvalidBytea
+		}
+
+		c, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return nil, ErrInvalidBytea
+		}
+
+		b = append(b, byte(c))
+		i += 3
+	}
+
+	return MemoryCell(b), nil
+}
+
+// decodeHex reads pairs of hex digits, which may be separated by
+// whitespace.
+func decodeHex(s string) (MemoryCell, error) {
+	b, err := hex.DecodeString(withoutSpace(s))
+	if err != nil {
+		return nil, ErrInvalidBytea
+	}
+
+	return MemoryCell(b), nil
+}
+
+func withoutSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// encodeBytes writes bytes as encode does in the hex, base64 or escape
+// format. Like PostgreSQL base64 lines are broken every 76 characters.
+func encodeBytes(b []byte, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "hex":
+		return hex.EncodeToString(b), nil
+
+	case "base64":
+		s := base64.StdEncoding.EncodeToString(b)
+		lines := []string{}
+		for len(s) > 76 {
+			lines = append(lines, s[:76])
+			s = s[76:]
+		}
+		return strings.Join(append(lines, s), "\n"), nil
+
+	case "escape":
+		var sb strings.Builder
+		for _, c := range b {
+			switch {
+			case c == '\\':
+				sb.WriteString(`\\`)
+			case c < 0x20 || c > 0x7e:
+				fmt.Fprintf(&sb, `\%03o`, c)
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		return sb.String(), nil
+	}
+
+	return "", ErrInvalidEncoding
+}
+
+// decodeBytes is the inverse of encodeBytes.
+func decodeBytes(s string, format string) (MemoryCell, error) {
+	switch strings.ToLower(format) {
+	case "hex":
+		return decodeHex(s)
+
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(withoutSpace(s))
+		if err != nil {
+			return nil, ErrInvalidBytea
+		}
+		return MemoryCell(b), nil
+
+	case "escape":
+		return decodeEscape(s)
+	}
+
+	return nil, ErrInvalidEncoding
+}
+
+// compareBytea compares two bytea cells byte by byte, returning -1, 0
+// or 1. The bool is false unless both are bytea.
+func compareBytea(l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (int, bool) {
+	if lt != ByteaType || rt != ByteaType {
+		return 0, false
+	}
+
+	return bytes.Compare(l, r), true
+}
+
+// coerceBytea reads a string literal compared with a bytea value as
+// bytea, like coerceTemporal does for dates and times.
+func coerceBytea(bexp *binaryExpression, l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (MemoryCell, ColumnType, MemoryCell, ColumnType, error) {
+	var err error
+	switch {
+	case lt == ByteaType && bexp.b.isStringLiteral():
+		r, err = parseBytea(r.AsText())
+		if err != nil {
+			return nil, 0, nil, 0, newSourceError(err, bexp.b.literal)
+		}
+		rt = lt
+
+	case rt == ByteaType && bexp.a.isStringLiteral():
+		l, err = parseBytea(l.AsText())
+		if err != nil {
+			return nil, 0, nil, 0, newSourceError(err, bexp.a.literal)
+		}
+		lt = rt
+	}
+
+	return l, lt, r, rt, nil
+}
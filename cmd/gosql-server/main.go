@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"gosql"
+	"gosql/server"
+)
+
+func main() {
+	network := flag.String("network", "tcp", "network to listen on, tcp or unix")
+	address := flag.String("address", "localhost:5432", "address or socket path to listen on")
+	password := flag.String("password", "", "require this cleartext password, trust all connections when empty")
+	allowFileCopy := flag.Bool("allow-file-copy", false, "let clients COPY to and from files on this machine")
+	flag.Parse()
+
+	if *network == "unix" {
+		// Clean up the socket of a previous run
+		os.Remove(*address)
+	}
+
+	s := server.New(gosql.NewMemoryBackend())
+	s.Password = *password
+	s.AllowFileCopy = *allowFileCopy
+
+	log.Printf("Listening on %s %s", *network, *address)
+	log.Fatal(s.ListenAndServe(*network, *address))
+}
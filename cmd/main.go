@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -12,8 +13,14 @@ import (
 	// "github.com/olekukonko/tablewriter/tw"
 )
 
-func doSelect(mb gosql.Backend, slct *gosql.SelectStatement) error {
-	results, err := mb.Select(slct)
+func doSelect(ctx context.Context, mb gosql.ContextBackend, slct *gosql.SelectStatement) error {
+	it, err := mb.QueryContext(ctx, slct)
+	if err != nil {
+		return err
+	}
+
+	// The table is sized from every row so they have to be read first
+	results, err := gosql.Collect(it)
 	if err != nil {
 		return err
 	}
@@ -39,15 +46,13 @@ func doSelect(mb gosql.Backend, slct *gosql.SelectStatement) error {
 			typ := results.Columns[i].Type
 			s := ""
 			switch typ {
-			case gosql.IntType:
-				s = fmt.Sprintf("%d", cell.AsInt())
-			case gosql.TextType:
-				s = cell.AsText()
 			case gosql.BoolType:
 				s = "true"
 				if !cell.AsBool() {
 					s = "false"
 				}
+			default:
+				s = gosql.FormatCell(cell, typ)
 			}
 			row = append(row, s)
 		}
@@ -70,6 +75,7 @@ func doSelect(mb gosql.Backend, slct *gosql.SelectStatement) error {
 
 func main() {
 	mb := gosql.NewMemoryBackend()
+	settings := gosql.Settings{}
 
 	l, err := readline.NewEx(&readline.Config{
 		Prompt:          "# ",
@@ -106,32 +112,44 @@ repl:
 
 		ast, err := gosql.Parse(line)
 		if err != nil {
-			fmt.Println("Error while parsing:", err)
+			fmt.Print(gosql.RenderError(line, err))
 			continue repl
 		}
 
 		for _, stmt := range ast.Statements {
-			switch stmt.Kind {
-			case gosql.CreateTableKind:
-				err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+			if stmt.Kind == gosql.SetKind {
+				err = settings.Set(stmt.SetStatement)
 				if err != nil {
-					fmt.Println("Error creating table", err)
+					fmt.Print(gosql.RenderError(line, err))
 					continue repl
 				}
-			
+				continue
+			}
+
+			// Every statement runs under statement_timeout, as in db.go
+			ctx, cancel := settings.Context(context.Background())
+			switch stmt.Kind {
+			case gosql.CreateTableKind:
+				err = mb.CreateTableContext(ctx, stmt.CreateTableStatement)
+
 			case gosql.InsertKind:
-				err = mb.Insert(stmt.InsertStatement)
-				if err != nil {
-					fmt.Println("Error inserting values:", err)
-					continue repl
+				err = mb.InsertContext(ctx, stmt.InsertStatement)
+
+			case gosql.CopyKind:
+				var n uint
+				n, err = mb.CopyContext(ctx, stmt.CopyStatement)
+				if err == nil {
+					fmt.Printf("(%d rows copied)\n", n)
 				}
 
 			case gosql.SelectKind:
-				err := doSelect(mb, stmt.SelectStatement)
-				if err != nil {
-					fmt.Println("Error selecting values:", err)
-					continue repl
-				}
+				err = doSelect(ctx, mb, stmt.SelectStatement)
+			}
+			cancel()
+
+			if err != nil {
+				fmt.Print(gosql.RenderError(line, err))
+				continue repl
 			}
 		}
 		fmt.Println("ok")
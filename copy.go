@@ -0,0 +1,207 @@
+package gosql
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// textToMemoryCell converts the textual form of a value, as found in a
+// CSV file, into a cell of the given column type.
+func textToMemoryCell(value string, ct ColumnType) (MemoryCell, error) {
+	switch {
+	case isNumericType(ct):
+		return parseNumeric(value, ct)
+
+	case ct == BoolType:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "t", "true", "y", "yes", "on", "1":
+			return trueMemoryCell, nil
+		case "f", "false", "n", "no", "off", "0":
+			return falseMemoryCell, nil
+		}
+
+		return nil, ErrInvalidDatatype
+
+	case isTemporalType(ct):
+		return parseTemporal(value, ct)
+
+	case isJSONType(ct):
+		return parseJSON(value, ct)
+
+	case ct == ByteaType:
+		return parseBytea(value)
+
+	default:
+		return MemoryCell(value), nil
+	}
+}
+
+// memoryCellToText is the inverse of textToMemoryCell.
+func memoryCellToText(mc Cell, ct ColumnType) string {
+	switch ct {
+	case SmallIntType, IntType, BigIntType:
+		return strconv.FormatInt(mc.AsBigInt(), 10)
+	case RealType:
+		return formatFloat(mc.AsFloat(), 32)
+	case FloatType:
+		return FormatFloat(mc.AsFloat())
+	case BoolType:
+		if mc.AsBool() {
+			return "t"
+		}
+		return "f"
+	case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+		return formatTemporal(mc, ct)
+	case ByteaType:
+		return formatBytea(mc)
+	default:
+		return mc.AsText()
+	}
+}
+
+func (mb *MemoryBackend) copyFrom(ctx context.Context, cp *CopyStatement) (uint, error) {
+	t, ok := mb.tables[cp.table.value]
+	if !ok {
+		return 0, newSourceError(ErrTableDoesNotExist, cp.table)
+	}
+
+	f, err := os.Open(cp.file.value)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = cp.delimiter
+	r.FieldsPerRecord = -1
+
+	rows := [][]MemoryCell{}
+	for first := true; ; first = false {
+		if len(rows)%cancelCheckInterval == 0 {
+			err := checkContext(ctx)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if first && cp.header {
+			continue
+		}
+
+		line, _ := r.FieldPos(0)
+		if len(record) != len(t.colums) {
+			return 0, fmt.Errorf("%w, line %d: expected %d values, got %d", ErrInvalidCopyData, line, len(t.colums), len(record))
+		}
+
+		row := []MemoryCell{}
+		for i, value := range record {
+			cell, err := textToMemoryCell(value, t.columnTypes[i])
+			if err == nil {
+				cell, err = t.storeCell(i, cell, t.columnTypes[i])
+			}
+			if err != nil {
+				line, col := r.FieldPos(i)
+				return 0, fmt.Errorf("%w, line %d, column %d: invalid value %q for %s", ErrInvalidCopyData, line, col, value, t.colums[i])
+			}
+
+			row = append(row, cell)
+		}
+
+		rows = append(rows, row)
+	}
+
+	// Rows are only added once the whole file is known to be valid
+	t.rows = append(t.rows, rows...)
+	return uint(len(rows)), nil
+}
+
+func (mb *MemoryBackend) copyTo(ctx context.Context, cp *CopyStatement) (uint, error) {
+	query := cp.query
+	if query == nil {
+		query = &SelectStatement{
+			item: &[]*selectItem{{asteriks: true}},
+			from: &fromItem{table: cp.table},
+		}
+	}
+
+	rows, err := mb.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns := rows.Columns()
+
+	f, err := os.Create(cp.file.value)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Comma = cp.delimiter
+
+	if cp.header {
+		header := []string{}
+		for _, col := range columns {
+			header = append(header, col.Name)
+		}
+
+		err = w.Write(header)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := uint(0)
+	for rows.Next() {
+		record := []string{}
+		for i, cell := range rows.Row() {
+			record = append(record, memoryCellToText(cell, columns[i].Type))
+		}
+
+		err = w.Write(record)
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	w.Flush()
+	err = w.Error()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, f.Close()
+}
+
+func (mb *MemoryBackend) Copy(cp *CopyStatement) (uint, error) {
+	return mb.CopyContext(context.Background(), cp)
+}
+
+func (mb *MemoryBackend) CopyContext(ctx context.Context, cp *CopyStatement) (uint, error) {
+	if cp.from {
+		return mb.copyFrom(ctx, cp)
+	}
+
+	return mb.copyTo(ctx, cp)
+}
@@ -0,0 +1,76 @@
+package gosql
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func execCopyTest(t *testing.T, mb *MemoryBackend, source string) (uint, error) {
+	ast, err := Parse(source)
+	assert.Nil(t, err, source)
+	assert.Equal(t, CopyKind, ast.Statements[0].Kind, source)
+
+	return mb.Copy(ast.Statements[0].CopyStatement)
+}
+
+func TestParseCopy(t *testing.T) {
+	tests := []struct {
+		source string
+		ok     bool
+	}{
+		{source: "COPY users FROM 'users.csv';", ok: true},
+		{source: "COPY users FROM 'users.csv' WITH (HEADER, DELIMITER ';');", ok: true},
+		{source: "COPY users TO 'users.csv' WITH (HEADER false);", ok: true},
+		{source: "COPY (SELECT id FROM users WHERE id > 1) TO 'users.csv';", ok: true},
+		{source: "COPY (SELECT id FROM users) FROM 'users.csv';", ok: false},
+		{source: "COPY users FROM 'users.csv' WITH (DELIMITER ';;');", ok: false},
+		{source: "COPY users 'users.csv';", ok: false},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.source)
+		assert.Equal(t, test.ok, err == nil, test.source)
+	}
+}
+
+func TestCopyRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.csv")
+	out := filepath.Join(dir, "out.csv")
+
+	data := "id;name;active\n1;Phil;t\n2;\"semi;colon \"\"quoted\"\"\nline\";false\n"
+	assert.Nil(t, os.WriteFile(in, []byte(data), 0644))
+
+	mb := newDumpFixture(t)
+	n, err := execCopyTest(t, mb, "COPY users FROM '"+in+"' WITH (HEADER, DELIMITER ';');")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), n)
+	assert.Equal(t, 5, len(mb.tables["users"].rows))
+	assert.Equal(t, "semi;colon \"quoted\"\nline", mb.tables["users"].rows[4][1].AsText())
+
+	n, err = execCopyTest(t, mb, "COPY (SELECT id, name FROM users WHERE id > 1) TO '"+out+"' WITH (HEADER);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), n)
+
+	written, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n3,\n2,\"semi;colon \"\"quoted\"\"\nline\"\n", string(written))
+}
+
+func TestCopyFromInvalidData(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "in.csv")
+	assert.Nil(t, os.WriteFile(in, []byte("1,a,t\n2,b,f\nthree,c,t\n"), 0644))
+
+	mb := newDumpFixture(t)
+	_, err := execCopyTest(t, mb, "COPY users FROM '"+in+"';")
+	assert.True(t, errors.Is(err, ErrInvalidCopyData))
+	assert.True(t, strings.Contains(err.Error(), "line 3"), err.Error())
+
+	// Nothing is inserted when any line is invalid
+	assert.Equal(t, 3, len(mb.tables["users"].rows))
+}
@@ -0,0 +1,722 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dates are stored as the days since 1970-01-01, times as microseconds
+// since midnight and timestamps as microseconds since 1970-01-01 UTC.
+// Timestamps with a time zone are the same instant in UTC, which is
+// also the zone they are shown in.
+const (
+	microsPerSecond = int64(1000000)
+	microsPerMinute = 60 * microsPerSecond
+	microsPerHour   = 60 * microsPerMinute
+	microsPerDay    = 24 * microsPerHour
+)
+
+// interval is a span of months, days and microseconds, kept apart like
+// PostgreSQL does since months and days vary in length.
+type interval struct {
+	months int32
+	days   int32
+	micros int64
+}
+
+func isTemporalType(ct ColumnType) bool {
+	switch ct {
+	case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+		return true
+	}
+
+	return false
+}
+
+// isInstantType tells the types that are a point on the calendar.
+func isInstantType(ct ColumnType) bool {
+	return ct == DateType || ct == TimestampType || ct == TimestampTzType
+}
+
+func dateCell(days int32) MemoryCell {
+	return binary.BigEndian.AppendUint32(nil, uint32(days))
+}
+
+func timestampCell(t time.Time) MemoryCell {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixMicro()))
+}
+
+func timeCell(micros int64) MemoryCell {
+	return binary.BigEndian.AppendUint64(nil, uint64(micros))
+}
+
+func intervalCell(iv interval) MemoryCell {
+	b := binary.BigEndian.AppendUint32(nil, uint32(iv.months))
+	b = binary.BigEndian.AppendUint32(b, uint32(iv.days))
+	return binary.BigEndian.AppendUint64(b, uint64(iv.micros))
+}
+
+func (mc MemoryCell) asInterval() interval {
+	if len(mc) != 16 {
+		return interval{}
+	}
+
+	return interval{
+		months: int32(binary.BigEndian.Uint32(mc)),
+		days:   int32(binary.BigEndian.Uint32(mc[4:])),
+		micros: int64(binary.BigEndian.Uint64(mc[8:])),
+	}
+}
+
+// IntervalParts returns the months, days and microseconds an interval
+// cell is made of.
+func IntervalParts(c Cell) (months, days int32, micros int64) {
+	mc, ok := c.(MemoryCell)
+	if !ok {
+		return 0, 0, 0
+	}
+
+	iv := mc.asInterval()
+	return iv.months, iv.days, iv.micros
+}
+
+// daysOf returns the date of t as days since 1970-01-01.
+func daysOf(t time.Time) int32 {
+	y, m, d := t.Date()
+	return int32(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// microsOf returns the value of a date or timestamp cell as
+// microseconds since 1970-01-01.
+func microsOf(mc MemoryCell, ct ColumnType) int64 {
+	if ct == DateType {
+		return int64(mc.AsInt()) * microsPerDay
+	}
+
+	return mc.AsBigInt()
+}
+
+// span is the length of an interval with months of 30 days, which is
+// how intervals are compared.
+func (iv interval) span() int64 {
+	return (int64(iv.months)*30+int64(iv.days))*microsPerDay + iv.micros
+}
+
+func (iv interval) negate() interval {
+	return interval{-iv.months, -iv.days, -iv.micros}
+}
+
+// addInterval adds an interval to a time. Months are added first and
+// keep the day of the month where they can, so a month after January
+// 31st is the last day of February.
+func addInterval(t time.Time, iv interval) time.Time {
+	y, m, d := t.Date()
+	months := int(m) - 1 + int(iv.months)
+	y, m = y+months/12, time.Month(months%12+1)
+	if months < 0 && months%12 != 0 {
+		y, m = y-1, time.Month(months%12+13)
+	}
+
+	last := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	t = time.Date(y, m, min(d, last), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return t.AddDate(0, 0, int(iv.days)).Add(time.Duration(iv.micros) * time.Microsecond)
+}
+
+// parseClock reads a time such as 13:45, 13:45:30 or 13:45:30.25 from
+// the start of s and returns it as microseconds and what follows it.
+// Hours are not limited to a day since intervals are written this way
+// too.
+func parseClock(s string) (int64, string, bool) {
+	fields := [3]int64{}
+	i := 0
+	for n := range fields {
+		if n > 0 {
+			if i >= len(s) || s[i] != ':' {
+				if n == 1 {
+					return 0, s, false
+				}
+				break
+			}
+			i++
+		}
+
+		start := i
+		for i < len(s) && (n == 0 || i-start < 2) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == start || (n > 0 && i-start != 2) || i-start > 9 {
+			return 0, s, false
+		}
+
+		fields[n], _ = strconv.ParseInt(s[start:i], 10, 64)
+	}
+
+	micros := int64(0)
+	if i < len(s) && s[i] == '.' {
+		i++
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+
+		// Digits past microseconds are rounded away
+		fraction := (s[start:i] + "000000")[:6]
+		micros, _ = strconv.ParseInt(fraction, 10, 64)
+		if i-start > 6 && s[start+6] >= '5' {
+			micros++
+		}
+	}
+
+	if fields[1] > 59 || fields[2] > 59 {
+		return 0, s, false
+	}
+
+	return fields[0]*microsPerHour + fields[1]*microsPerMinute + fields[2]*microsPerSecond + micros, s[i:], true
+}
+
+// parseZone reads a UTC offset such as Z, UTC, +02, +02:00 or -0530 and
+// returns it in seconds.
+func parseZone(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	switch strings.ToUpper(s) {
+	case "", "Z", "UTC", "GMT":
+		return 0, true
+	}
+
+	if s[0] != '+' && s[0] != '-' {
+		return 0, false
+	}
+
+	digits := strings.ReplaceAll(s[1:], ":", "")
+	if len(digits) == 2 {
+		digits += "00"
+	}
+
+	if len(digits) != 4 || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+
+	hours, _ := strconv.Atoi(digits[:2])
+	minutes, _ := strconv.Atoi(digits[2:])
+	if hours > 15 || minutes > 59 {
+		return 0, false
+	}
+
+	offset := hours*3600 + minutes*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+
+	return offset, true
+}
+
+// parseDate reads a date written YYYY-MM-DD. The years of BC dates
+// count back from 1 BC, which is year 0.
+func parseDate(s string, bc bool) (time.Time, bool) {
+	digits := s[:4] + s[5:7] + s[8:]
+	if strings.Trim(digits, "0123456789") != "" {
+		return time.Time{}, false
+	}
+
+	year, _ := strconv.Atoi(s[:4])
+	month, _ := strconv.Atoi(s[5:7])
+	day, _ := strconv.Atoi(s[8:])
+	if year == 0 {
+		return time.Time{}, false
+	}
+
+	if bc {
+		year = 1 - year
+	}
+
+	// Days past the end of the month would roll over to the next one
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day {
+		return time.Time{}, false
+	}
+
+	return date, true
+}
+
+// formatDate writes the date of t as YYYY-MM-DD and returns the era
+// that ends the value, " BC" for years before 1 as PostgreSQL prints
+// them.
+func formatDate(t time.Time) (string, string) {
+	year, era := t.Year(), ""
+	if year <= 0 {
+		year, era = 1-year, " BC"
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d", year, t.Month(), t.Day()), era
+}
+
+// parseDateTime reads an ISO 8601 date, time or both, optionally
+// followed by a UTC offset. The date and time are separated by a space
+// or a T.
+func parseDateTime(s string) (date time.Time, hasDate bool, clock int64, hasClock bool, offset int, err error) {
+	s = strings.TrimSpace(s)
+
+	// Years before 1 are written as counted back from 1 BC
+	bc := len(s) > 3 && strings.EqualFold(s[len(s)-3:], " bc")
+	if bc {
+		s = strings.TrimSpace(s[:len(s)-3])
+	}
+
+	if len(s) >= 10 && s[4] == '-' && s[7] == '-' {
+		var ok bool
+		date, ok = parseDate(s[:10], bc)
+		if !ok {
+			return date, false, 0, false, 0, ErrInvalidDatetime
+		}
+
+		hasDate = true
+		s = s[10:]
+		if len(s) > 0 && (s[0] == 'T' || s[0] == 't' || s[0] == ' ') {
+			s = strings.TrimLeft(s[1:], " ")
+		}
+	}
+
+	if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		var ok bool
+		clock, s, ok = parseClock(s)
+		if !ok || clock >= microsPerDay {
+			return date, false, 0, false, 0, ErrInvalidDatetime
+		}
+
+		hasClock = true
+	}
+
+	if (!hasDate && !hasClock) || (bc && !hasDate) {
+		return date, false, 0, false, 0, ErrInvalidDatetime
+	}
+
+	offset, ok := parseZone(s)
+	if !ok {
+		return date, false, 0, false, 0, ErrInvalidDatetime
+	}
+
+	return date, hasDate, clock, hasClock, offset, nil
+}
+
+// intervalUnits are the units an interval can be written in, in
+// microseconds for units up to a day, days for weeks and months for
+// longer ones.
+var intervalUnits = map[string]struct {
+	months int64
+	days   int64
+	micros int64
+}{
+	"microsecond": {micros: 1},
+	"millisecond": {micros: 1000},
+	"second":      {micros: microsPerSecond},
+	"minute":      {micros: microsPerMinute},
+	"hour":        {micros: microsPerHour},
+	"day":         {days: 1},
+	"week":        {days: 7},
+	"month":       {months: 1},
+	"year":        {months: 12},
+	"decade":      {months: 120},
+	"century":     {months: 1200},
+	"millennium":  {months: 12000},
+}
+
+var intervalUnitAliases = map[string]string{
+	"us": "microsecond", "usec": "microsecond", "usecs": "microsecond", "microseconds": "microsecond",
+	"ms": "millisecond", "msec": "millisecond", "msecs": "millisecond", "milliseconds": "millisecond",
+	"s": "second", "sec": "second", "secs": "second", "seconds": "second",
+	"m": "minute", "min": "minute", "mins": "minute", "minutes": "minute",
+	"h": "hour", "hr": "hour", "hrs": "hour", "hours": "hour",
+	"d": "day", "days": "day",
+	"w": "week", "weeks": "week",
+	"mon": "month", "mons": "month", "months": "month",
+	"y": "year", "yr": "year", "yrs": "year", "years": "year",
+	"decades": "decade", "centuries": "century", "millennia": "millennium",
+}
+
+// parseInterval reads an interval the way PostgreSQL writes them, such
+// as 1 year 2 mons, 3 days 04:05:06 or 90 minutes ago. Fractions of a
+// unit carry over to the smaller ones, a month being 30 days.
+func parseInterval(s string) (interval, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return interval{}, ErrInvalidDatetime
+	}
+
+	months, days := new(big.Rat), new(big.Rat)
+	micros := new(big.Rat)
+
+	ago := fields[len(fields)-1] == "ago"
+	if ago {
+		fields = fields[:len(fields)-1]
+	}
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		// A time of day such as -04:05:06
+		if strings.Contains(field, ":") {
+			clock, rest, ok := parseClock(strings.TrimLeft(field, "+-"))
+			if !ok || rest != "" {
+				return interval{}, ErrInvalidDatetime
+			}
+
+			value := new(big.Rat).SetInt64(clock)
+			if field[0] == '-' {
+				value.Neg(value)
+			}
+			micros.Add(micros, value)
+			continue
+		}
+
+		// A number, followed by its unit in the same field or the next
+		number := strings.TrimRightFunc(field, func(r rune) bool { return r >= 'a' && r <= 'z' })
+		unit := field[len(number):]
+		if unit == "" && i+1 < len(fields) {
+			i++
+			unit = fields[i]
+		}
+
+		value, _, ok := parseDecimal(number)
+		if !ok {
+			return interval{}, ErrInvalidDatetime
+		}
+
+		if alias, ok := intervalUnitAliases[unit]; ok {
+			unit = alias
+		}
+
+		u, ok := intervalUnits[unit]
+		if !ok {
+			return interval{}, ErrInvalidDatetime
+		}
+
+		months.Add(months, new(big.Rat).Mul(value, big.NewRat(u.months, 1)))
+		days.Add(days, new(big.Rat).Mul(value, big.NewRat(u.days, 1)))
+		micros.Add(micros, new(big.Rat).Mul(value, big.NewRat(u.micros, 1)))
+	}
+
+	return newInterval(months, days, micros, ago)
+}
+
+// newInterval builds an interval from fractional parts, carrying the
+// fractions of months to days and of days to microseconds.
+func newInterval(months, days, micros *big.Rat, negate bool) (interval, error) {
+	if negate {
+		months, days, micros = new(big.Rat).Neg(months), new(big.Rat).Neg(days), new(big.Rat).Neg(micros)
+	}
+
+	whole := func(r *big.Rat) *big.Rat {
+		return new(big.Rat).SetInt(new(big.Int).Quo(r.Num(), r.Denom()))
+	}
+
+	m := whole(months)
+	days = new(big.Rat).Add(days, new(big.Rat).Mul(new(big.Rat).Sub(months, m), big.NewRat(30, 1)))
+	d := whole(days)
+	micros = new(big.Rat).Add(micros, new(big.Rat).Mul(new(big.Rat).Sub(days, d), big.NewRat(microsPerDay, 1)))
+
+	us, err := strconv.ParseInt(micros.FloatString(0), 10, 64)
+	if err != nil || !m.Num().IsInt64() || !d.Num().IsInt64() {
+		return interval{}, ErrNumericOutOfRange
+	}
+
+	mi, di := m.Num().Int64(), d.Num().Int64()
+	if !fitsInteger(mi, IntType) || !fitsInteger(di, IntType) {
+		return interval{}, ErrNumericOutOfRange
+	}
+
+	return interval{months: int32(mi), days: int32(di), micros: us}, nil
+}
+
+// parseTemporal converts text to a cell of a date, time, timestamp or
+// interval type.
+func parseTemporal(s string, ct ColumnType) (MemoryCell, error) {
+	if ct == IntervalType {
+		iv, err := parseInterval(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return intervalCell(iv), nil
+	}
+
+	date, hasDate, clock, hasClock, offset, err := parseDateTime(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ct {
+	case DateType:
+		if !hasDate {
+			return nil, ErrInvalidDatetime
+		}
+
+		return dateCell(daysOf(date)), nil
+
+	case TimeType:
+		if !hasClock {
+			return nil, ErrInvalidDatetime
+		}
+
+		return timeCell(clock), nil
+
+	case TimestampTzType:
+		if !hasDate {
+			return nil, ErrInvalidDatetime
+		}
+
+		t := date.Add(time.Duration(clock)*time.Microsecond - time.Duration(offset)*time.Second)
+		return timestampCell(t), nil
+
+	default:
+		if !hasDate {
+			return nil, ErrInvalidDatetime
+		}
+
+		return timestampCell(date.Add(time.Duration(clock) * time.Microsecond)), nil
+	}
+}
+
+func formatClock(micros int64) string {
+	s := fmt.Sprintf("%02d:%02d:%02d", micros/microsPerHour, micros/microsPerMinute%60, micros/microsPerSecond%60)
+	if fraction := micros % microsPerSecond; fraction != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", fraction), "0")
+	}
+
+	return s
+}
+
+func formatInterval(iv interval) string {
+	parts := []string{}
+	plural := func(n int64, unit string) {
+		if n == 0 {
+			return
+		}
+
+		s := fmt.Sprintf("%d %s", n, unit)
+		if n != 1 {
+			s += "s"
+		}
+		parts = append(parts, s)
+	}
+
+	plural(int64(iv.months/12), "year")
+	plural(int64(iv.months%12), "mon")
+	plural(int64(iv.days), "day")
+
+	if iv.micros != 0 || len(parts) == 0 {
+		clock := iv.micros
+		sign := ""
+		if clock < 0 {
+			sign, clock = "-", -clock
+		}
+		parts = append(parts, sign+formatClock(clock))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// formatTemporal formats a date, time, timestamp or interval cell the
+// way PostgreSQL prints it in the ISO style.
+func formatTemporal(mc Cell, ct ColumnType) string {
+	switch ct {
+	case DateType:
+		date, era := formatDate(mc.AsTime())
+		return date + era
+	case TimeType:
+		return formatClock(mc.AsBigInt())
+	case TimestampType, TimestampTzType:
+		t := mc.AsTime()
+		date, era := formatDate(t)
+		clock := int64(t.Hour())*microsPerHour + int64(t.Minute())*microsPerMinute + int64(t.Second())*microsPerSecond + int64(t.Nanosecond()/1000)
+		s := date + " " + formatClock(clock)
+		if ct == TimestampTzType {
+			s += "+00"
+		}
+		return s + era
+	default:
+		if m, ok := mc.(MemoryCell); ok {
+			return formatInterval(m.asInterval())
+		}
+		return mc.AsText()
+	}
+}
+
+// comparableTemporal tells the types compareTemporal can compare.
+func comparableTemporal(lt, rt ColumnType) bool {
+	return (isInstantType(lt) && isInstantType(rt)) || (lt == rt && (lt == TimeType || lt == IntervalType))
+}
+
+// compareTemporal compares two date, time, timestamp or interval cells,
+// returning -1, 0 or 1. Dates and timestamps can be compared with each
+// other. The bool is false if the types cannot be compared.
+func compareTemporal(l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (int, bool) {
+	if !comparableTemporal(lt, rt) {
+		return 0, false
+	}
+
+	var a, b int64
+	switch lt {
+	case TimeType:
+		a, b = l.AsBigInt(), r.AsBigInt()
+	case IntervalType:
+		a, b = l.asInterval().span(), r.asInterval().span()
+	default:
+		a, b = microsOf(l, lt), microsOf(r, rt)
+	}
+
+	switch {
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	}
+
+	return 0, true
+}
+
+// coerceTemporal reads a string literal compared with a date, time,
+// timestamp or interval as a value of that type, the way PostgreSQL
+// treats literals whose type is not known.
+func coerceTemporal(bexp *binaryExpression, l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (MemoryCell, ColumnType, MemoryCell, ColumnType, error) {
+	var err error
+	switch {
+	case isTemporalType(lt) && bexp.b.isStringLiteral():
+		r, err = parseTemporal(r.AsText(), lt)
+		if err != nil {
+			return nil, 0, nil, 0, newSourceError(err, bexp.b.literal)
+		}
+		rt = lt
+
+	case isTemporalType(rt) && bexp.a.isStringLiteral():
+		l, err = parseTemporal(l.AsText(), rt)
+		if err != nil {
+			return nil, 0, nil, 0, newSourceError(err, bexp.a.literal)
+		}
+		lt = rt
+	}
+
+	return l, lt, r, rt, nil
+}
+
+// temporalArithmetic applies + or - to operands of which at least one
+// is a date, time, timestamp or interval:
+//
+//	date ± int = date, date - date = int
+//	date ± interval, date + time = timestamp
+//	timestamp ± interval = timestamp, timestamp - timestamp = interval
+//	time ± interval = time, time - time = interval
+//	interval ± interval = interval
+func temporalArithmetic(op symbol, l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (MemoryCell, ColumnType, error) {
+	minus := op == minusSymbol
+	if swapOperands(op, lt, rt) {
+		l, lt, r, rt = r, rt, l, lt
+	}
+
+	switch {
+	case lt == DateType && isIntegerType(rt):
+		n := r.AsBigInt()
+		if minus {
+			n = -n
+		}
+
+		days := int64(l.AsInt()) + n
+		if !fitsInteger(days, IntType) {
+			return nil, 0, ErrNumericOutOfRange
+		}
+
+		return dateCell(int32(days)), DateType, nil
+
+	case lt == DateType && rt == DateType && minus:
+		return intCell(l.AsInt() - r.AsInt()), IntType, nil
+
+	case lt == DateType && rt == TimeType && !minus:
+		t := l.AsTime().Add(time.Duration(r.AsBigInt()) * time.Microsecond)
+		return timestampCell(t), TimestampType, nil
+
+	case isInstantType(lt) && rt == IntervalType:
+		iv := r.asInterval()
+		if minus {
+			iv = iv.negate()
+		}
+
+		ct := lt
+		if ct == DateType {
+			ct = TimestampType
+		}
+
+		return timestampCell(addInterval(l.AsTime(), iv)), ct, nil
+
+	case lt != DateType && rt != DateType && isInstantType(lt) && isInstantType(rt) && minus:
+		diff := l.AsBigInt() - r.AsBigInt()
+		return intervalCell(interval{days: int32(diff / microsPerDay), micros: diff % microsPerDay}), IntervalType, nil
+
+	case lt == TimeType && rt == IntervalType:
+		micros := r.asInterval().micros
+		if minus {
+			micros = -micros
+		}
+
+		clock := (l.AsBigInt() + micros%microsPerDay + microsPerDay) % microsPerDay
+		return timeCell(clock), TimeType, nil
+
+	case lt == TimeType && rt == TimeType && minus:
+		return intervalCell(interval{micros: l.AsBigInt() - r.AsBigInt()}), IntervalType, nil
+
+	case lt == IntervalType && rt == IntervalType:
+		a, b := l.asInterval(), r.asInterval()
+		if minus {
+			b = b.negate()
+		}
+
+		return intervalCell(interval{a.months + b.months, a.days + b.days, a.micros + b.micros}), IntervalType, nil
+	}
+
+	return nil, 0, ErrInvalidOperands
+}
+
+// swapOperands tells whether the operands of an addition are the other
+// way round from how temporalArithmetic expects them. Addition is the
+// same either way round.
+func swapOperands(op symbol, lt, rt ColumnType) bool {
+	if op == minusSymbol {
+		return false
+	}
+
+	return !isTemporalType(lt) || (lt == IntervalType && rt != IntervalType) || (lt == TimeType && rt == DateType)
+}
+
+// typeOfTemporalArithmetic is the type temporalArithmetic gives, and
+// false if it does not apply to the operands.
+func typeOfTemporalArithmetic(op symbol, lt, rt ColumnType) (ColumnType, bool) {
+	minus := op == minusSymbol
+	if swapOperands(op, lt, rt) {
+		lt, rt = rt, lt
+	}
+
+	switch {
+	case lt == DateType && isIntegerType(rt):
+		return DateType, true
+	case lt == DateType && rt == DateType && minus:
+		return IntType, true
+	case lt == DateType && rt == TimeType && !minus:
+		return TimestampType, true
+	case lt == DateType && rt == IntervalType:
+		return TimestampType, true
+	case isInstantType(lt) && rt == IntervalType:
+		return lt, true
+	case lt != DateType && rt != DateType && isInstantType(lt) && isInstantType(rt) && minus:
+		return IntervalType, true
+	case lt == TimeType && rt == IntervalType:
+		return TimeType, true
+	case lt == TimeType && rt == TimeType && minus:
+		return IntervalType, true
+	case lt == IntervalType && rt == IntervalType:
+		return IntervalType, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,419 @@
+package gosql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DB runs SQL against a backend. Statements are serialized so a DB is
+// safe for concurrent use even though backends are not. Settings
+// changed with SET apply to every user of the DB.
+type DB struct {
+	mu       sync.Mutex
+	backend  Backend
+	settings Settings
+}
+
+func NewDB(backend Backend) *DB {
+	return &DB{backend: backend}
+}
+
+type Result struct {
+	RowsAffected uint
+}
+
+// Stmt is a parsed script of one or more statements ready to be run
+// any number of times.
+type Stmt struct {
+	db  *DB
+	ast *Ast
+}
+
+// Prepare parses source once so that it can be run repeatedly with
+// different arguments.
+func (db *DB) Prepare(source string) (*Stmt, error) {
+	ast, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{db: db, ast: ast}, nil
+}
+
+// Exec runs every statement in source. Arguments are bound to the ?
+// and $n placeholders of the statements in order, each statement taking
+// as many as it uses.
+func (db *DB) Exec(source string, args ...any) (Result, error) {
+	return db.ExecContext(context.Background(), source, args...)
+}
+
+// ExecContext is Exec with statements canceled once ctx is done.
+func (db *DB) ExecContext(ctx context.Context, source string, args ...any) (Result, error) {
+	stmt, err := db.Prepare(source)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Query runs every statement in source like Exec and returns the rows
+// of the last SELECT.
+func (db *DB) Query(source string, args ...any) (*Rows, error) {
+	return db.QueryContext(context.Background(), source, args...)
+}
+
+// QueryContext is Query with statements, and reading their rows,
+// canceled once ctx is done.
+func (db *DB) QueryContext(ctx context.Context, source string, args ...any) (*Rows, error) {
+	stmt, err := db.Prepare(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.QueryContext(ctx, args...)
+}
+
+// NumParameters returns the number of arguments Exec and Query expect.
+func (s *Stmt) NumParameters() int {
+	n := 0
+	for _, stmt := range s.ast.Statements {
+		n += stmt.NumParameters()
+	}
+
+	return n
+}
+
+// ReadOnly reports whether running the statements leaves all tables
+// unchanged.
+func (s *Stmt) ReadOnly() bool {
+	for _, stmt := range s.ast.Statements {
+		if !stmt.ReadOnly() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (stmt *Statement) ReadOnly() bool {
+	switch stmt.Kind {
+	case SelectKind, SetKind:
+		return true
+	case CopyKind:
+		return !stmt.CopyStatement.from
+	}
+
+	return false
+}
+
+func (s *Stmt) bind(args []any) ([]*Statement, error) {
+	if len(args) != s.NumParameters() {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrParameterCount, s.NumParameters(), len(args))
+	}
+
+	bound := []*Statement{}
+	for _, stmt := range s.ast.Statements {
+		n := stmt.NumParameters()
+
+		b, err := stmt.Bind(args[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		args = args[n:]
+		bound = append(bound, b)
+	}
+
+	return bound, nil
+}
+
+// contextRows releases the context of a query along with its rows.
+type contextRows struct {
+	RowIterator
+	cancel context.CancelFunc
+}
+
+func (r *contextRows) Next() bool {
+	if !r.RowIterator.Next() {
+		r.cancel()
+		return false
+	}
+
+	return true
+}
+
+func (r *contextRows) Close() error {
+	r.cancel()
+	return r.RowIterator.Close()
+}
+
+func (s *Stmt) run(ctx context.Context, args []any) (Result, RowIterator, error) {
+	stmts, err := s.bind(args)
+	if err != nil {
+		return Result{}, nil, err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var result Result
+	var rows RowIterator
+
+	backend := WithContext(s.db.backend)
+	for _, stmt := range stmts {
+		if stmt.Kind == SetKind {
+			err = s.db.settings.Set(stmt.SetStatement)
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		sctx, cancel := s.db.settings.Context(ctx)
+
+		switch stmt.Kind {
+		case CreateTableKind:
+			err = backend.CreateTableContext(sctx, stmt.CreateTableStatement)
+
+		case InsertKind:
+			err = backend.InsertContext(sctx, stmt.InsertStatement)
+			if err == nil {
+				result.RowsAffected++
+			}
+
+		case CopyKind:
+			cb, ok := s.db.backend.(CopyBackend)
+			if !ok {
+				err = fmt.Errorf("%w: COPY", ErrNotSupported)
+				break
+			}
+
+			var n uint
+			n, err = cb.CopyContext(sctx, stmt.CopyStatement)
+			result.RowsAffected += n
+
+		case SelectKind:
+			if rows != nil {
+				rows.Close()
+			}
+
+			var it RowIterator
+			it, err = backend.QueryContext(sctx, stmt.SelectStatement)
+			if err == nil {
+				// The timeout keeps running while rows are read
+				rows = &contextRows{RowIterator: it, cancel: cancel}
+				continue
+			}
+			rows = nil
+		}
+
+		cancel()
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		if rows != nil {
+			rows.Close()
+		}
+
+		return Result{}, nil, err
+	}
+
+	return result, rows, nil
+}
+
+func (s *Stmt) Exec(args ...any) (Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+func (s *Stmt) ExecContext(ctx context.Context, args ...any) (Result, error) {
+	result, rows, err := s.run(ctx, args)
+	if rows != nil {
+		rows.Close()
+	}
+
+	return result, err
+}
+
+func (s *Stmt) Query(args ...any) (*Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+func (s *Stmt) QueryContext(ctx context.Context, args ...any) (*Rows, error) {
+	_, rows, err := s.run(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if rows == nil {
+		rows = &memoryRows{ctx: ctx, t: &table{}, slct: &SelectStatement{}, columns: []ResultColumn{}}
+	}
+
+	return &Rows{rows: rows}, nil
+}
+
+// Rows iterates over the results of a query. Rows are read from the
+// backend as Next is called.
+//
+//	rows, err := db.Query("SELECT id FROM users WHERE name = ?;", name)
+//	for rows.Next() {
+//		var id int32
+//		err = rows.Scan(&id)
+//	}
+type Rows struct {
+	rows RowIterator
+}
+
+func (r *Rows) Columns() []ResultColumn {
+	return r.rows.Columns()
+}
+
+// Next advances to the next row and reports whether there is one.
+func (r *Rows) Next() bool {
+	return r.rows.Next()
+}
+
+// Row returns the cells of the current row.
+func (r *Rows) Row() []Cell {
+	return r.rows.Row()
+}
+
+func (r *Rows) Err() error {
+	return r.rows.Err()
+}
+
+func (r *Rows) Close() error {
+	return r.rows.Close()
+}
+
+// Scan copies the current row into dest, one pointer per column. Int
+// columns scan into *int32, *int, *int64, text columns into *string and
+// boolean columns into *bool. Any column scans into *any.
+func (r *Rows) Scan(dest ...any) error {
+	row := r.Row()
+	if row == nil {
+		return ErrNoRow
+	}
+
+	if len(dest) != len(row) {
+		return fmt.Errorf("%w: expected %d destinations, got %d", ErrInvalidScan, len(row), len(dest))
+	}
+
+	columns := r.rows.Columns()
+	for i, cell := range row {
+		err := scanCell(cell, columns[i].Type, dest[i])
+		if err != nil {
+			return fmt.Errorf("%w, column %s", err, columns[i].Name)
+		}
+	}
+
+	return nil
+}
+
+func scanCell(cell Cell, ct ColumnType, dest any) error {
+	if d, ok := dest.(*any); ok {
+		*d = cellToValue(cell, ct)
+		return nil
+	}
+
+	switch ct {
+	case SmallIntType, IntType, BigIntType:
+		i := cell.AsBigInt()
+		switch d := dest.(type) {
+		case *int16:
+			if !fitsInteger(i, SmallIntType) {
+				return ErrInvalidScan
+			}
+			*d = int16(i)
+		case *int32:
+			if !fitsInteger(i, IntType) {
+				return ErrInvalidScan
+			}
+			*d = int32(i)
+		case *int:
+			*d = int(i)
+		case *uint:
+			if i < 0 {
+				return ErrInvalidScan
+			}
+			*d = uint(i)
+		case *int64:
+			*d = i
+		default:
+			return ErrInvalidScan
+		}
+
+	case RealType, FloatType:
+		switch d := dest.(type) {
+		case *float64:
+			*d = cell.AsFloat()
+		case *float32:
+			*d = float32(cell.AsFloat())
+		default:
+			return ErrInvalidScan
+		}
+
+	case NumericType:
+		switch d := dest.(type) {
+		case *string:
+			*d = cell.AsText()
+		case *float64:
+			f, err := strconv.ParseFloat(cell.AsText(), 64)
+			if err != nil {
+				return ErrInvalidScan
+			}
+			*d = f
+		default:
+			return ErrInvalidScan
+		}
+
+	case BoolType:
+		d, ok := dest.(*bool)
+		if !ok {
+			return ErrInvalidScan
+		}
+		*d = cell.AsBool()
+
+	case DateType, TimestampType, TimestampTzType:
+		switch d := dest.(type) {
+		case *time.Time:
+			*d = cell.AsTime()
+		case *string:
+			*d = formatTemporal(cell, ct)
+		default:
+			return ErrInvalidScan
+		}
+
+	case TimeType, IntervalType:
+		d, ok := dest.(*string)
+		if !ok {
+			return ErrInvalidScan
+		}
+		*d = formatTemporal(cell, ct)
+
+	case ByteaType:
+		switch d := dest.(type) {
+		case *[]byte:
+			*d = []byte(cell.AsText())
+		case *string:
+			*d = formatBytea(cell)
+		default:
+			return ErrInvalidScan
+		}
+
+	default:
+		d, ok := dest.(*string)
+		if !ok {
+			return ErrInvalidScan
+		}
+		*d = cell.AsText()
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package gosql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB(t *testing.T) {
+	db := NewDB(NewMemoryBackend())
+
+	_, err := db.Exec("CREATE TABLE users (id INT, name TEXT, active BOOLEAN);")
+	assert.Nil(t, err)
+
+	res, err := db.Exec("INSERT INTO users VALUES (?, ?, ?); INSERT INTO users VALUES ($1, $2, $3);", 1, "Phil", true, 2, "Kate", false)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), res.RowsAffected)
+
+	_, err = db.Exec("INSERT INTO users VALUES (?, ?, ?);", 3, "Lou")
+	assert.ErrorIs(t, err, ErrParameterCount)
+
+	_, err = db.Exec("INSERT INTO users VALUES (?, ?, ?);", 3, "Lou", uint64(1))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+
+	_, err = db.Exec("INSERT INTO users VALUES (?, ?, ?);", 3, "Lou", 1.5)
+	assert.ErrorIs(t, err, ErrInvalidDatatype)
+
+	rows, err := db.Query("SELECT id, name, active FROM users WHERE id = ?;", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []ResultColumn{{IntType, "id"}, {TextType, "name"}, {BoolType, "active"}}, rows.Columns())
+
+	assert.True(t, rows.Next())
+	var id int
+	var name string
+	var active bool
+	assert.Nil(t, rows.Scan(&id, &name, &active))
+	assert.Equal(t, 2, id)
+	assert.Equal(t, "Kate", name)
+	assert.False(t, active)
+
+	var wrong bool
+	assert.ErrorIs(t, rows.Scan(&wrong, &name, &active), ErrInvalidScan)
+	assert.ErrorIs(t, rows.Scan(&id), ErrInvalidScan)
+
+	assert.False(t, rows.Next())
+	assert.ErrorIs(t, rows.Scan(&id, &name, &active), ErrNoRow)
+	assert.Nil(t, rows.Close())
+
+	// A quote in an argument stays part of the value
+	rows, err = db.Query("SELECT id FROM users WHERE name = ?;", "x' OR 1 = 1; --")
+	assert.Nil(t, err)
+	assert.False(t, rows.Next())
+
+	stmt, err := db.Prepare("SELECT name FROM users WHERE id = ?;")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stmt.NumParameters())
+	assert.True(t, stmt.ReadOnly())
+
+	rows, err = stmt.Query(1)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	var value any
+	assert.Nil(t, rows.Scan(&value))
+	assert.Equal(t, "Phil", value)
+}
+
+func TestDBContext(t *testing.T) {
+	db := NewDB(NewMemoryBackend())
+
+	_, err := db.Exec("CREATE TABLE numbers (n INT);")
+	assert.Nil(t, err)
+
+	insert, err := db.Prepare("INSERT INTO numbers VALUES (?);")
+	assert.Nil(t, err)
+	for i := 0; i < 2*cancelCheckInterval; i++ {
+		_, err = insert.Exec(i)
+		assert.Nil(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.QueryContext(ctx, "SELECT n FROM numbers;")
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+
+	cancel()
+	for rows.Next() {
+	}
+	assert.ErrorIs(t, rows.Err(), ErrQueryCanceled)
+	assert.ErrorIs(t, rows.Err(), context.Canceled)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO numbers VALUES (1);")
+	assert.ErrorIs(t, err, ErrQueryCanceled)
+
+	_, err = db.Exec("SET statement_timeout = '5s';")
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, db.settings.StatementTimeout)
+
+	_, err = db.Exec("SET statement_timeout TO 250;")
+	assert.Nil(t, err)
+	assert.Equal(t, 250*time.Millisecond, db.settings.StatementTimeout)
+
+	_, err = db.Exec("SET statement_timeout = 1.5;")
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Millisecond, db.settings.StatementTimeout)
+
+	_, err = db.Exec("SET statement_timeout = '0.25s';")
+	assert.Nil(t, err)
+	assert.Equal(t, 250*time.Millisecond, db.settings.StatementTimeout)
+
+	_, err = db.Exec("SET statement_timeout = '1.2345 ms';")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Millisecond, db.settings.StatementTimeout)
+
+	_, err = db.Exec("SET statement_timeout = default;")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), db.settings.StatementTimeout)
+
+	for _, value := range []string{"'5 weeks'", "'-1'", "'NaN'", "'1e300'", "'0x10'", "'s'"} {
+		_, err = db.Exec("SET statement_timeout = " + value + ";")
+		assert.ErrorIs(t, err, ErrInvalidSetting, value)
+	}
+
+	_, err = db.Exec("SET work_mem = '4MB';")
+	assert.ErrorIs(t, err, ErrUnknownSetting)
+}
+
+// baseBackend has only the methods of Backend.
+type baseBackend struct {
+	mb *MemoryBackend
+}
+
+func (b baseBackend) CreateTable(crt *CreateTableStatement) error {
+	return b.mb.CreateTable(crt)
+}
+
+func (b baseBackend) Insert(inst *InsertStatement) error {
+	return b.mb.Insert(inst)
+}
+
+func (b baseBackend) Select(slct *SelectStatement) (*Results, error) {
+	return b.mb.Select(slct)
+}
+
+func TestDBBaseBackend(t *testing.T) {
+	db := NewDB(baseBackend{NewMemoryBackend()})
+
+	_, err := db.Exec("CREATE TABLE users (id INT, name TEXT); INSERT INTO users VALUES (?, ?);", 1, "Phil")
+	assert.Nil(t, err)
+
+	rows, err := db.Query("SELECT id, name FROM users;")
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	var id int32
+	var name string
+	assert.Nil(t, rows.Scan(&id, &name))
+	assert.Equal(t, int32(1), id)
+	assert.Equal(t, "Phil", name)
+	assert.False(t, rows.Next())
+
+	_, err = db.Exec("COPY users FROM '/tmp/users.csv';")
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.ExecContext(ctx, "INSERT INTO users VALUES (2, 'Kate');")
+	assert.ErrorIs(t, err, ErrQueryCanceled)
+}
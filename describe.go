@@ -0,0 +1,364 @@
+package gosql
+
+// typeOf statically determines the name and type of an expression
+// without evaluating it. Parameter types are looked up in params. A
+// parameter whose type is not known yet is inferred from the operator
+// or the other operand it is used with, and recorded in params. The
+// returned bool is false when the type could not be determined.
+func (t *table) typeOf(exp expression, params map[uint]ColumnType) (string, ColumnType, bool, error) {
+	switch exp.kind {
+	case literalKind:
+		lit := exp.literal
+		switch lit.kind {
+		case identifierKind:
+			for i, tableCol := range t.colums {
+				if tableCol == lit.value {
+					return tableCol, t.columnTypes[i], true, nil
+				}
+			}
+
+			return "", 0, false, newSourceError(ErrColumnDoesNotExist, lit)
+		case stringKind:
+			return "?column?", TextType, true, nil
+		case boolKind:
+			return "?column?", BoolType, true, nil
+		default:
+			_, ct, err := numericToMemoryCell(lit.value)
+			if err != nil {
+				return "", 0, false, newSourceError(err, lit)
+			}
+
+			return "?column?", ct, true, nil
+		}
+
+	case parameterKind:
+		ct, ok := params[exp.parameter.index]
+		return "?column?", ct, ok, nil
+
+	case binaryKind:
+		return t.typeOfBinary(exp.binary, params)
+
+	case castKind:
+		ct, ok := columnTypeOf(exp.cast.datatype.value)
+		if !ok {
+			return "", 0, false, newSourceError(ErrInvalidDatatype, &exp.cast.datatype)
+		}
+
+		// A parameter cast to a type is given as a value of it
+		inferParameter(exp.cast.exp, ct, params)
+
+		_, _, _, err := t.typeOf(exp.cast.exp, params)
+		if err != nil {
+			return "", 0, false, err
+		}
+
+		return exp.cast.datatype.value, ct, true, nil
+
+	case callKind:
+		return t.typeOfCall(exp.call, params)
+	}
+
+	return "", 0, false, ErrInvalidCell
+}
+
+func inferParameter(exp expression, ct ColumnType, params map[uint]ColumnType) {
+	if exp.kind == parameterKind {
+		if _, ok := params[exp.parameter.index]; !ok {
+			params[exp.parameter.index] = ct
+		}
+	}
+}
+
+// inferComparison gives a parameter compared with something of a known
+// type that type.
+func inferComparison(bexp *binaryExpression, lt ColumnType, lok bool, rt ColumnType, rok bool, params map[uint]ColumnType) {
+	if lok && !rok {
+		inferParameter(bexp.b, lt, params)
+	} else if rok && !lok {
+		inferParameter(bexp.a, rt, params)
+	}
+}
+
+func (t *table) typeOfBinary(bexp *binaryExpression, params map[uint]ColumnType) (string, ColumnType, bool, error) {
+	_, lt, lok, err := t.typeOf(bexp.a, params)
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	_, rt, rok, err := t.typeOf(bexp.b, params)
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	// Type both operands must have, if the operator requires one
+	var operand ColumnType
+	result := BoolType
+
+	switch bexp.op.kind {
+	case symbolKind:
+		switch symbol(bexp.op.value) {
+		case eqSymbol, neqSymbol, neqSymbol2:
+			inferComparison(bexp, lt, lok, rt, rok, params)
+			return "?column?", BoolType, true, nil
+
+		case gtSymbol, gteSymbol, ltSymbol, lteSymbol:
+			inferComparison(bexp, lt, lok, rt, rok, params)
+			numeric := isNumericType(lt) && isNumericType(rt)
+			text := isTextType(lt) && isTextType(rt)
+			temporal := comparableTemporal(lt, rt) || (isTemporalType(lt) && isTextType(rt)) || (isTextType(lt) && isTemporalType(rt))
+			binary := (lt == ByteaType && isTextType(rt)) || (isTextType(lt) && rt == ByteaType)
+			if lok && rok && ((lt != rt && !numeric && !text && !temporal && !binary) || lt == BoolType) {
+				return "", 0, false, ErrInvalidOperands
+			}
+
+			return "?column?", BoolType, true, nil
+
+		case concatSymbol:
+			inferParameter(bexp.a, TextType, params)
+			inferParameter(bexp.b, TextType, params)
+
+			if (lok && !isTextType(lt)) || (rok && !isTextType(rt)) {
+				return "", 0, false, ErrInvalidOperands
+			}
+
+			return "?column?", TextType, true, nil
+
+		case plusSymbol, minusSymbol:
+			return typeOfArithmetic(bexp, lt, lok, rt, rok, params)
+
+		case arrowSymbol, arrowTextSymbol, pathSymbol, pathTextSymbol, containsSymbol:
+			return typeOfJSONOperands(bexp, lt, lok, rt, rok, params)
+
+		default:
+			return "", 0, false, ErrInvalidCell
+		}
+
+	case keywordKind:
+		switch keyword(bexp.op.value) {
+		case andKeyword, orKeyword:
+			operand = BoolType
+
+		default:
+			return "", 0, false, ErrInvalidCell
+		}
+
+	default:
+		return "", 0, false, ErrInvalidCell
+	}
+
+	inferParameter(bexp.a, operand, params)
+	inferParameter(bexp.b, operand, params)
+
+	if (lok && lt != operand) || (rok && rt != operand) {
+		return "", 0, false, ErrInvalidOperands
+	}
+
+	return "?column?", result, true, nil
+}
+
+// typeOfArithmetic types + and -, which take numbers and give the wider
+// type of the two. Parameters take the type of the other operand, or
+// int.
+func typeOfArithmetic(bexp *binaryExpression, lt ColumnType, lok bool, rt ColumnType, rok bool, params map[uint]ColumnType) (string, ColumnType, bool, error) {
+	if (lok && isTemporalType(lt)) || (rok && isTemporalType(rt)) {
+		return typeOfTemporalOperands(bexp, lt, lok, rt, rok, params)
+	}
+
+	inferComparison(bexp, lt, lok, rt, rok, params)
+	inferParameter(bexp.a, IntType, params)
+	inferParameter(bexp.b, IntType, params)
+
+	if (lok && !isNumericType(lt)) || (rok && !isNumericType(rt)) {
+		return "", 0, false, ErrInvalidOperands
+	}
+
+	ct := IntType
+	switch {
+	case lok && rok:
+		ct = widerNumericType(lt, rt)
+	case lok:
+		ct = lt
+	case rok:
+		ct = rt
+	}
+
+	return "?column?", ct, true, nil
+}
+
+// typeOfTemporalOperands types + and - of dates, times, timestamps and
+// intervals. A parameter added to or subtracted from one is an
+// interval.
+func typeOfTemporalOperands(bexp *binaryExpression, lt ColumnType, lok bool, rt ColumnType, rok bool, params map[uint]ColumnType) (string, ColumnType, bool, error) {
+	if !lok {
+		inferParameter(bexp.a, IntervalType, params)
+		lt = IntervalType
+	}
+
+	if !rok {
+		inferParameter(bexp.b, IntervalType, params)
+		rt = IntervalType
+	}
+
+	ct, ok := typeOfTemporalArithmetic(symbol(bexp.op.value), lt, rt)
+	if !ok {
+		return "", 0, false, ErrInvalidOperands
+	}
+
+	return "?column?", ct, true, nil
+}
+
+// selectColumns returns the columns a SELECT produces.
+func (t *table) selectColumns(slct *SelectStatement, params map[uint]ColumnType) ([]ResultColumn, error) {
+	columns := []ResultColumn{}
+	if slct.item == nil {
+		return columns, nil
+	}
+
+	for _, col := range *slct.item {
+		if col.asteriks {
+			for j, tableCol := range t.colums {
+				columns = append(columns, ResultColumn{Type: t.columnTypes[j], Name: tableCol})
+			}
+			continue
+		}
+
+		name, ct, _, err := t.typeOf(*col.exp, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if col.as != nil {
+			name = col.as.value
+		}
+
+		columns = append(columns, ResultColumn{Type: ct, Name: name})
+	}
+
+	return columns, nil
+}
+
+func (mb *MemoryBackend) selectTable(slct *SelectStatement) (*table, error) {
+	if slct.from == nil || slct.from.table == nil {
+		return &table{}, nil
+	}
+
+	t, ok := mb.tables[slct.from.table.value]
+	if !ok {
+		return nil, newSourceError(ErrTableDoesNotExist, slct.from.table)
+	}
+
+	return t, nil
+}
+
+func (mb *MemoryBackend) describeSelect(slct *SelectStatement, params map[uint]ColumnType) ([]ResultColumn, error) {
+	t, err := mb.selectTable(slct)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parameters inferred late in the statement can help with ones used
+	// earlier, so repeat until nothing new is learned.
+	for {
+		known := len(params)
+
+		if slct.where != nil {
+			_, _, _, err = t.typeOf(*slct.where, params)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		columns, err := t.selectColumns(slct, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(params) == known {
+			return columns, nil
+		}
+	}
+}
+
+func (mb *MemoryBackend) describeInsert(inst *InsertStatement, params map[uint]ColumnType) error {
+	t, ok := mb.tables[inst.table.value]
+	if !ok {
+		return newSourceError(ErrTableDoesNotExist, &inst.table)
+	}
+
+	if inst.values == nil {
+		return nil
+	}
+
+	if len(*inst.values) != len(t.colums) {
+		return ErrMissingValues
+	}
+
+	emptyTable := &table{}
+	for i, value := range *inst.values {
+		inferParameter(*value, t.columnTypes[i], params)
+
+		_, _, _, err := emptyTable.typeOf(*value, params)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Describe infers the types of the parameters of a statement from the
+// context they are used in, defaulting to TextType, and reports the
+// columns a SELECT returns.
+func (mb *MemoryBackend) Describe(stmt *Statement) ([]ColumnType, []ResultColumn, error) {
+	params := map[uint]ColumnType{}
+	var columns []ResultColumn
+	var err error
+
+	switch stmt.Kind {
+	case SelectKind:
+		columns, err = mb.describeSelect(stmt.SelectStatement, params)
+	case InsertKind:
+		err = mb.describeInsert(stmt.InsertStatement, params)
+	case CopyKind:
+		if stmt.CopyStatement.query != nil {
+			_, err = mb.describeSelect(stmt.CopyStatement.query, params)
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	types := make([]ColumnType, stmt.NumParameters())
+	for i := range types {
+		ct, ok := params[uint(i)+1]
+		if !ok {
+			ct = TextType
+		}
+
+		types[i] = ct
+	}
+
+	return types, columns, nil
+}
+
+// typeOfJSONOperands types the JSON operators. Parameters are text,
+// which is read as JSON where a JSON value is needed.
+func typeOfJSONOperands(bexp *binaryExpression, lt ColumnType, lok bool, rt ColumnType, rok bool, params map[uint]ColumnType) (string, ColumnType, bool, error) {
+	if !lok {
+		inferParameter(bexp.a, TextType, params)
+		lt = TextType
+	}
+
+	if !rok {
+		inferParameter(bexp.b, TextType, params)
+		rt = TextType
+	}
+
+	ct, ok := typeOfJSONOperator(symbol(bexp.op.value), lt, rt)
+	if !ok {
+		return "", 0, false, ErrInvalidOperands
+	}
+
+	return "?column?", ct, true, nil
+}
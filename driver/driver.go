@@ -0,0 +1,429 @@
+// Package driver registers gosql with database/sql under the name
+// "gosql".
+//
+// The data source name selects where data lives. An empty DSN,
+// "memory" or ":memory:" opens a private in-memory database. Anything
+// else, optionally prefixed with "file:", is a path to a snapshot file
+// written by MemoryBackend.Dump. The snapshot is loaded when the
+// database is opened and rewritten after every statement that changes
+// data. Databases opened on the same file share one backend until the
+// last of them is closed, so that none overwrites the others' changes.
+//
+// Arguments are bound by position, named arguments are rejected.
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gosql"
+)
+
+var (
+	ErrTransactionsNotSupported = errors.New("gosql: transactions are not supported by the memory backend")
+	ErrLastInsertIdNotSupported = errors.New("gosql: LastInsertId is not supported")
+	ErrNamedArgsNotSupported    = errors.New("gosql: named arguments are not supported")
+)
+
+func init() {
+	sql.Register("gosql", &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The connection is the only user of its connector
+	return &conn{db: c.(*connector).db, owner: c.(*connector)}, nil
+}
+
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	switch dsn {
+	case "", "memory", ":memory:":
+		db := &database{backend: gosql.NewMemoryBackend()}
+		db.db = gosql.NewDB(db.backend)
+		return &connector{driver: d, db: db}, nil
+	}
+
+	db, err := openDatabase(strings.TrimPrefix(dsn, "file:"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &connector{driver: d, db: db}, nil
+}
+
+var (
+	databasesMu sync.Mutex
+	// databases holds the open file databases by absolute path
+	databases = map[string]*database{}
+)
+
+// openDatabase returns the database of a snapshot file, loading it
+// unless it is already open.
+func openDatabase(path string) (*database, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+
+	db, ok := databases[path]
+	if ok {
+		db.refs++
+		return db, nil
+	}
+
+	db = &database{path: path, refs: 1}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		db.backend = gosql.NewMemoryBackend()
+	} else if err != nil {
+		return nil, err
+	} else {
+		defer f.Close()
+
+		db.backend, err = gosql.Restore(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	db.db = gosql.NewDB(db.backend)
+	databases[path] = db
+	return db, nil
+}
+
+// database is shared by all connections of a connector, and by every
+// connector on the same file. MemoryBackend is not safe for concurrent
+// use so statements are serialized.
+type database struct {
+	mu      sync.Mutex
+	db      *gosql.DB
+	backend *gosql.MemoryBackend
+	path    string
+	// refs counts the open connectors of a file database
+	refs int
+}
+
+// release forgets a file database once its last connector is closed.
+func (db *database) release() {
+	if db.path == "" {
+		return
+	}
+
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+
+	db.refs--
+	if db.refs == 0 {
+		delete(databases, db.path)
+	}
+}
+
+// save rewrites the snapshot file, if any, through a temporary file so
+// that a crash never leaves a truncated snapshot behind.
+func (db *database) save() error {
+	if db.path == "" {
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(db.path), filepath.Base(db.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = db.backend.Dump(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), db.path)
+}
+
+func parameters(args []driver.NamedValue) []any {
+	params := []any{}
+	for _, arg := range args {
+		params = append(params, arg.Value)
+	}
+
+	return params
+}
+
+// saveAfter rewrites the snapshot once a statement that may have changed
+// data has run, even if it failed halfway.
+func (db *database) saveAfter(s *gosql.Stmt, err error) error {
+	if s.ReadOnly() {
+		return err
+	}
+
+	saveErr := db.save()
+	if err == nil {
+		err = saveErr
+	}
+
+	return err
+}
+
+func (db *database) exec(ctx context.Context, s *gosql.Stmt, args []driver.NamedValue) (gosql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := s.ExecContext(ctx, parameters(args)...)
+	return result, db.saveAfter(s, err)
+}
+
+func (db *database) query(ctx context.Context, s *gosql.Stmt, args []driver.NamedValue) (*gosql.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows, err := s.QueryContext(ctx, parameters(args)...)
+	return rows, db.saveAfter(s, err)
+}
+
+type connector struct {
+	driver *Driver
+	db     *database
+	closed sync.Once
+}
+
+// Close is called by sql.DB.Close.
+func (c *connector) Close() error {
+	c.closed.Do(c.db.release)
+	return nil
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+type conn struct {
+	db *database
+	// owner is the connector opened for this connection alone by
+	// Driver.Open
+	owner *connector
+}
+
+// CheckNamedValue rejects named arguments and leaves the others to the
+// default conversion.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nv.Name != "" {
+		return fmt.Errorf("%w: %s", ErrNamedArgsNotSupported, nv.Name)
+	}
+
+	return driver.ErrSkip
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	s, err := c.db.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{db: c.db, stmt: s}, nil
+}
+
+func (c *conn) Close() error {
+	if c.owner != nil {
+		return c.owner.Close()
+	}
+
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrTransactionsNotSupported
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.(*stmt).ExecContext(ctx, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	s, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.(*stmt).QueryContext(ctx, args)
+}
+
+type stmt struct {
+	db   *database
+	stmt *gosql.Stmt
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.stmt.NumParameters()
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	r, err := s.db.exec(ctx, s.stmt, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return result(r.RowsAffected), nil
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := s.db.query(ctx, s.stmt, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{rows: r}, nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := []driver.NamedValue{}
+	for i, arg := range args {
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+
+	return named
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+type result int64
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, ErrLastInsertIdNotSupported
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+type rows struct {
+	rows *gosql.Rows
+}
+
+func (r *rows) Columns() []string {
+	columns := []string{}
+	for _, col := range r.rows.Columns() {
+		columns = append(columns, col.Name)
+	}
+
+	return columns
+}
+
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		err := r.rows.Err()
+		if err != nil {
+			return err
+		}
+
+		return io.EOF
+	}
+
+	columns := r.rows.Columns()
+	for i, cell := range r.rows.Row() {
+		switch columns[i].Type {
+		case gosql.SmallIntType, gosql.IntType, gosql.BigIntType:
+			dest[i] = cell.AsBigInt()
+		case gosql.RealType, gosql.FloatType:
+			dest[i] = cell.AsFloat()
+		case gosql.BoolType:
+			dest[i] = cell.AsBool()
+		case gosql.DateType, gosql.TimestampType, gosql.TimestampTzType:
+			dest[i] = cell.AsTime()
+		case gosql.TimeType, gosql.IntervalType:
+			dest[i] = gosql.FormatCell(cell, columns[i].Type)
+		case gosql.ByteaType:
+			dest[i] = []byte(cell.AsText())
+		default:
+			dest[i] = cell.AsText()
+		}
+	}
+
+	return nil
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	switch r.rows.Columns()[index].Type {
+	case gosql.IntType:
+		return "INT"
+	case gosql.BoolType:
+		return "BOOLEAN"
+	case gosql.FloatType:
+		return "FLOAT"
+	case gosql.SmallIntType:
+		return "SMALLINT"
+	case gosql.BigIntType:
+		return "BIGINT"
+	case gosql.RealType:
+		return "REAL"
+	case gosql.NumericType:
+		return "NUMERIC"
+	case gosql.VarcharType:
+		return "VARCHAR"
+	case gosql.CharType:
+		return "CHAR"
+	case gosql.DateType:
+		return "DATE"
+	case gosql.TimeType:
+		return "TIME"
+	case gosql.TimestampType:
+		return "TIMESTAMP"
+	case gosql.TimestampTzType:
+		return "TIMESTAMPTZ"
+	case gosql.IntervalType:
+		return "INTERVAL"
+	case gosql.JsonType:
+		return "JSON"
+	case gosql.JsonbType:
+		return "JSONB"
+	case gosql.ByteaType:
+		return "BYTEA"
+	default:
+		return "TEXT"
+	}
+}
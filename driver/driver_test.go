@@ -0,0 +1,136 @@
+package driver
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriverMemory(t *testing.T) {
+	db, err := sql.Open("gosql", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE users (id INT, name TEXT, active BOOLEAN);")
+	assert.Nil(t, err)
+
+	res, err := db.Exec("INSERT INTO users VALUES (1, 'Phil', true); INSERT INTO users VALUES (2, 'Kate', false);")
+	assert.Nil(t, err)
+
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	stmt, err := db.Prepare("SELECT id, name, active FROM users;")
+	assert.Nil(t, err)
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	assert.Nil(t, err)
+
+	cols, err := rows.Columns()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name", "active"}, cols)
+
+	types, err := rows.ColumnTypes()
+	assert.Nil(t, err)
+	assert.Equal(t, "INT", types[0].DatabaseTypeName())
+
+	var ids []int32
+	var names []string
+	var actives []bool
+	for rows.Next() {
+		var id int32
+		var name string
+		var active bool
+		assert.Nil(t, rows.Scan(&id, &name, &active))
+
+		ids = append(ids, id)
+		names = append(names, name)
+		actives = append(actives, active)
+	}
+	assert.Nil(t, rows.Err())
+
+	assert.Equal(t, []int32{1, 2}, ids)
+	assert.Equal(t, []string{"Phil", "Kate"}, names)
+	assert.Equal(t, []bool{true, false}, actives)
+
+	_, err = db.Begin()
+	assert.Equal(t, ErrTransactionsNotSupported, err)
+}
+
+func TestDriverFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("gosql", "file:"+path)
+	assert.Nil(t, err)
+
+	_, err = db.Exec("CREATE TABLE users (id INT); INSERT INTO users VALUES (42);")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db, err = sql.Open("gosql", path)
+	assert.Nil(t, err)
+
+	var id int
+	assert.Nil(t, db.QueryRow("SELECT id FROM users;").Scan(&id))
+	assert.Equal(t, 42, id)
+
+	// A second database on the same file shares the data, so neither
+	// overwrites the rows of the other when saving
+	other, err := sql.Open("gosql", "file:"+path)
+	assert.Nil(t, err)
+
+	_, err = db.Exec("INSERT INTO users VALUES (1);")
+	assert.Nil(t, err)
+	_, err = other.Exec("INSERT INTO users VALUES (2);")
+	assert.Nil(t, err)
+	assert.Nil(t, other.Close())
+	assert.Nil(t, db.Close())
+
+	db, err = sql.Open("gosql", path)
+	assert.Nil(t, err)
+
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id FROM users;")
+	assert.Nil(t, err)
+
+	ids := []int{}
+	for rows.Next() {
+		assert.Nil(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []int{42, 1, 2}, ids)
+}
+
+func TestDriverArguments(t *testing.T) {
+	db, err := sql.Open("gosql", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE users (id INT, name TEXT);")
+	assert.Nil(t, err)
+
+	stmt, err := db.Prepare("INSERT INTO users VALUES (?, ?);")
+	assert.Nil(t, err)
+	defer stmt.Close()
+
+	for i, name := range []string{"Phil", "Kate"} {
+		_, err = stmt.Exec(i+1, name)
+		assert.Nil(t, err)
+	}
+
+	_, err = stmt.Exec(3)
+	assert.NotNil(t, err)
+
+	var name string
+	assert.Nil(t, db.QueryRow("SELECT name FROM users WHERE id = $1;", 2).Scan(&name))
+	assert.Equal(t, "Kate", name)
+
+	err = db.QueryRow("SELECT name FROM users WHERE id = ?;", sql.Named("id", 2)).Scan(&name)
+	assert.ErrorIs(t, err, ErrNamedArgsNotSupported)
+}
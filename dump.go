@@ -0,0 +1,443 @@
+package gosql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Binary snapshots start with dumpMagic followed by a single version
+// byte. Bump dumpVersion whenever the layout below changes. Version 1
+// dumps, which have no type modifiers, can still be restored.
+const (
+	dumpMagic   = "GOSQL"
+	dumpVersion = byte(2)
+
+	// maxPreallocated bounds the buffer allocated for a length read
+	// from a dump before the bytes are seen.
+	maxPreallocated = 64 << 10
+)
+
+func (mb *MemoryBackend) tableNames() []string {
+	names := []string{}
+	for name := range mb.tables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func writeUvarint(w *bufio.Writer, i uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, i)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+func writeBytes(w *bufio.Writer, b []byte) error {
+	err := writeUvarint(w, uint64(len(b)))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// writeCell stores the length plus one so that a nil cell (false
+// booleans) can be told apart from an empty one (empty text).
+func writeCell(w *bufio.Writer, mc MemoryCell) error {
+	if mc == nil {
+		return writeUvarint(w, 0)
+	}
+
+	err := writeUvarint(w, uint64(len(mc))+1)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(mc)
+	return err
+}
+
+// Dump writes every table of the backend to w in a versioned binary
+// format that can be loaded back with Restore.
+func (mb *MemoryBackend) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	_, err := bw.WriteString(dumpMagic)
+	if err != nil {
+		return err
+	}
+
+	err = bw.WriteByte(dumpVersion)
+	if err != nil {
+		return err
+	}
+
+	names := mb.tableNames()
+	err = writeUvarint(bw, uint64(len(names)))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		t := mb.tables[name]
+
+		err = writeBytes(bw, []byte(name))
+		if err != nil {
+			return err
+		}
+
+		err = writeUvarint(bw, uint64(len(t.colums)))
+		if err != nil {
+			return err
+		}
+
+		for i, col := range t.colums {
+			err = writeBytes(bw, []byte(col))
+			if err != nil {
+				return err
+			}
+
+			err = writeUvarint(bw, uint64(t.columnTypes[i]))
+			if err != nil {
+				return err
+			}
+
+			mod := t.modifier(i)
+			for _, n := range []uint{mod.length, mod.precision, mod.scale} {
+				err = writeUvarint(bw, uint64(n))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = writeUvarint(bw, uint64(len(t.rows)))
+		if err != nil {
+			return err
+		}
+
+		for _, row := range t.rows {
+			for _, cell := range row {
+				err = writeCell(bw, cell)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func readUvarint(r *bufio.Reader) (uint64, error) {
+	i, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return 0, ErrInvalidDump
+	}
+
+	return i, err
+}
+
+// readBytes reads n bytes. The buffer only grows as they arrive, so a
+// corrupt length fails at the end of the input instead of allocating
+// whatever it says up front.
+func readBytes(r *bufio.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, ErrInvalidDump
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, min(n, maxPreallocated)))
+	_, err := io.CopyN(buf, r, int64(n))
+	if err == io.EOF {
+		return nil, ErrInvalidDump
+	}
+
+	return buf.Bytes(), err
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	n, err := readUvarint(r)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := readBytes(r, n)
+	return string(b), err
+}
+
+func readCell(r *bufio.Reader) (MemoryCell, error) {
+	n, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, nil
+	}
+
+	b, err := readBytes(r, n-1)
+	return MemoryCell(b), err
+}
+
+// Restore loads a backend previously written by Dump.
+func Restore(r io.Reader) (*MemoryBackend, error) {
+	br := bufio.NewReader(r)
+
+	header, err := readBytes(br, uint64(len(dumpMagic)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header[:len(dumpMagic)]) != dumpMagic {
+		return nil, ErrInvalidDump
+	}
+
+	version := header[len(dumpMagic)]
+	if version < 1 || version > dumpVersion {
+		return nil, ErrUnsupportedDumpVersion
+	}
+
+	mb := NewMemoryBackend()
+
+	tableCount, err := readUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+
+	for ; tableCount > 0; tableCount-- {
+		name, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+
+		t := &table{}
+
+		colCount, err := readUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := uint64(0); i < colCount; i++ {
+			col, err := readString(br)
+			if err != nil {
+				return nil, err
+			}
+
+			dt, err := readUvarint(br)
+			if err != nil {
+				return nil, err
+			}
+
+			if ColumnType(dt) > ByteaType {
+				return nil, ErrInvalidDatatype
+			}
+
+			mod := typeModifier{}
+			if version >= 2 {
+				mods := [3]uint64{}
+				for j := range mods {
+					mods[j], err = readUvarint(br)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				mod = typeModifier{length: uint(mods[0]), precision: uint(mods[1]), scale: uint(mods[2])}
+			}
+
+			t.colums = append(t.colums, col)
+			t.columnTypes = append(t.columnTypes, ColumnType(dt))
+			t.modifiers = append(t.modifiers, mod)
+		}
+
+		rowCount, err := readUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+
+		for ; rowCount > 0; rowCount-- {
+			row := []MemoryCell{}
+			for i := uint64(0); i < colCount; i++ {
+				cell, err := readCell(br)
+				if err != nil {
+					return nil, err
+				}
+
+				row = append(row, cell)
+			}
+
+			t.rows = append(t.rows, row)
+		}
+
+		mb.tables[name] = t
+	}
+
+	return mb, nil
+}
+
+func columnTypeName(ct ColumnType, mod typeModifier) string {
+	switch ct {
+	case IntType:
+		return string(intKeyword)
+	case BoolType:
+		return string(boolKeyword)
+	case FloatType:
+		return string(floatKeyword)
+	case SmallIntType:
+		return string(smallintKeyword)
+	case BigIntType:
+		return string(bigintKeyword)
+	case RealType:
+		return string(realKeyword)
+	case NumericType:
+		if mod.precision > 0 {
+			return fmt.Sprintf("%s(%d,%d)", numericKeyword, mod.precision, mod.scale)
+		}
+		return string(numericKeyword)
+	case VarcharType:
+		if mod.length > 0 {
+			return fmt.Sprintf("%s(%d)", varcharKeyword, mod.length)
+		}
+		return string(varcharKeyword)
+	case CharType:
+		return fmt.Sprintf("%s(%d)", charKeyword, max(mod.length, 1))
+	case DateType:
+		return string(dateKeyword)
+	case TimeType:
+		return string(timeKeyword)
+	case TimestampType:
+		return string(timestampKeyword)
+	case TimestampTzType:
+		return string(timestamptzKeyword)
+	case IntervalType:
+		return string(intervalKeyword)
+	case JsonType:
+		return string(jsonKeyword)
+	case JsonbType:
+		return string(jsonbKeyword)
+	case ByteaType:
+		return string(byteaKeyword)
+	default:
+		return string(textKeyword)
+	}
+}
+
+// quoteIdentifier leaves plain lowercase identifiers alone and wraps
+// everything else, including reserved keywords, in double quotes.
+func quoteIdentifier(id string) string {
+	plain := id != ""
+	for i, c := range id {
+		isAlphabetical := c >= 'a' && c <= 'z'
+		isNumeric := c >= '0' && c <= '9'
+		if !isAlphabetical && (i == 0 || (!isNumeric && c != '$' && c != '_')) {
+			plain = false
+			break
+		}
+	}
+
+	// Reserved keywords cannot be used as plain identifiers
+	if plain && keywordTrie.longestMatch(id) == id && keyword(id).reserved() {
+		plain = false
+	}
+
+	if plain {
+		return id
+	}
+
+	return `"` + strings.ReplaceAll(id, `"`, `""`) + `"`
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func memoryCellToLiteral(mc MemoryCell, ct ColumnType) string {
+	switch ct {
+	case SmallIntType, IntType, BigIntType:
+		return fmt.Sprintf("%d", mc.AsBigInt())
+	case RealType, FloatType:
+		f := mc.AsFloat()
+		if s, ok := nonFiniteLiteral(f, ct); ok {
+			return s
+		}
+
+		if ct == RealType {
+			return formatFloat(f, 32)
+		}
+		return floatLiteral(f)
+	case NumericType:
+		return mc.AsText()
+	case BoolType:
+		if mc.AsBool() {
+			return string(trueKeyword)
+		}
+		return string(falseKeyword)
+	case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+		return quoteString(formatTemporal(mc, ct))
+	case ByteaType:
+		return quoteString(formatBytea(mc))
+	default:
+		return quoteString(mc.AsText())
+	}
+}
+
+// nonFiniteLiteral writes NaN and the infinities, which have no numeric
+// literal, as casts of their text.
+func nonFiniteLiteral(f float64, ct ColumnType) (string, bool) {
+	var s string
+	switch {
+	case math.IsNaN(f):
+		s = "NaN"
+	case math.IsInf(f, 1):
+		s = "Infinity"
+	case math.IsInf(f, -1):
+		s = "-Infinity"
+	default:
+		return "", false
+	}
+
+	return quoteString(s) + "::" + columnTypeName(ct, typeModifier{}), true
+}
+
+// DumpSQL writes every table of the backend to w as CREATE TABLE and
+// INSERT statements that Parse can replay.
+func (mb *MemoryBackend) DumpSQL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, name := range mb.tableNames() {
+		t := mb.tables[name]
+
+		cols := []string{}
+		for i, col := range t.colums {
+			cols = append(cols, quoteIdentifier(col)+" "+columnTypeName(t.columnTypes[i], t.modifier(i)))
+		}
+
+		_, err := fmt.Fprintf(bw, "CREATE TABLE %s (%s);\n", quoteIdentifier(name), strings.Join(cols, ", "))
+		if err != nil {
+			return err
+		}
+
+		for _, row := range t.rows {
+			values := []string{}
+			for i, cell := range row {
+				values = append(values, memoryCellToLiteral(cell, t.columnTypes[i]))
+			}
+
+			_, err = fmt.Fprintf(bw, "INSERT INTO %s VALUES (%s);\n", quoteIdentifier(name), strings.Join(values, ", "))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
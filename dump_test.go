@@ -0,0 +1,168 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDumpFixture(t *testing.T) *MemoryBackend {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE users (id INT, name TEXT, active BOOLEAN);
+INSERT INTO users VALUES (1, 'Phil', true);
+INSERT INTO users VALUES (-2, 'O''Brien', false);
+INSERT INTO users VALUES (3, '', true);
+CREATE TABLE empty (id INT);`)
+	assert.Nil(t, err)
+
+	for _, stmt := range ast.Statements {
+		switch stmt.Kind {
+		case CreateTableKind:
+			assert.Nil(t, mb.CreateTable(stmt.CreateTableStatement))
+		case InsertKind:
+			assert.Nil(t, mb.Insert(stmt.InsertStatement))
+		}
+	}
+
+	return mb
+}
+
+func TestDumpRestore(t *testing.T) {
+	mb := newDumpFixture(t)
+
+	var buf bytes.Buffer
+	assert.Nil(t, mb.Dump(&buf))
+
+	restored, err := Restore(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, mb.tables, restored.tables)
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	_, err := Restore(bytes.NewBufferString("NOTGOSQL"))
+	assert.Equal(t, ErrInvalidDump, err)
+
+	_, err = Restore(bytes.NewBufferString(dumpMagic + "\x03"))
+	assert.Equal(t, ErrUnsupportedDumpVersion, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, newDumpFixture(t).Dump(&buf))
+	_, err = Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	assert.Equal(t, ErrInvalidDump, err)
+
+	// A table name claiming to be far longer than the dump
+	corrupt := append([]byte(dumpMagic), dumpVersion, 1)
+	corrupt = binary.AppendUvarint(corrupt, math.MaxUint64)
+	_, err = Restore(bytes.NewReader(append(corrupt, "users"...)))
+	assert.Equal(t, ErrInvalidDump, err)
+
+	corrupt = append([]byte(dumpMagic), dumpVersion, 1)
+	corrupt = binary.AppendUvarint(corrupt, 1<<40)
+	_, err = Restore(bytes.NewReader(append(corrupt, "users"...)))
+	assert.Equal(t, ErrInvalidDump, err)
+}
+
+func TestDumpSQL(t *testing.T) {
+	mb := newDumpFixture(t)
+
+	var buf bytes.Buffer
+	assert.Nil(t, mb.DumpSQL(&buf))
+
+	assert.Equal(t, `CREATE TABLE empty (id int);
+CREATE TABLE users (id int, name text, active boolean);
+INSERT INTO users VALUES (1, 'Phil', true);
+INSERT INTO users VALUES (-2, 'O''Brien', false);
+INSERT INTO users VALUES (3, '', true);
+`, buf.String())
+
+	ast, err := Parse(buf.String())
+	assert.Nil(t, err)
+
+	replayed := NewMemoryBackend()
+	for _, stmt := range ast.Statements {
+		switch stmt.Kind {
+		case CreateTableKind:
+			assert.Nil(t, replayed.CreateTable(stmt.CreateTableStatement))
+		case InsertKind:
+			assert.Nil(t, replayed.Insert(stmt.InsertStatement))
+		}
+	}
+
+	assert.Equal(t, mb.tables, replayed.tables)
+}
+
+func TestDumpColumnTypes(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE prices (id BIGINT, qty SMALLINT, weight REAL, amount NUMERIC(8, 2), total NUMERIC, code CHAR(2), name VARCHAR(10));
+INSERT INTO prices VALUES (9000000000, -3, 0.1, 12.5, 123456789012345678901234567890.5, 'a', 'Tea   ');`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+
+	var buf bytes.Buffer
+	assert.Nil(t, mb.Dump(&buf))
+
+	restored, err := Restore(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, mb.tables, restored.tables)
+
+	buf.Reset()
+	assert.Nil(t, mb.DumpSQL(&buf))
+	assert.Equal(t, `CREATE TABLE prices (id bigint, qty smallint, weight real, amount numeric(8,2), total numeric, code char(2), name varchar(10));
+INSERT INTO prices VALUES (9000000000, -3, 0.1, 12.50, 123456789012345678901234567890.5, 'a ', 'Tea   ');
+`, buf.String())
+
+	ast, err = Parse(buf.String())
+	assert.Nil(t, err)
+
+	replayed := NewMemoryBackend()
+	assert.Nil(t, replayed.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, replayed.Insert(ast.Statements[1].InsertStatement))
+	assert.Equal(t, mb.tables, replayed.tables)
+
+	// NaN and the infinities have no literal and are dumped as casts
+	mb = NewMemoryBackend()
+	ast, err = Parse(`CREATE TABLE points (x REAL, y FLOAT);
+INSERT INTO points VALUES ('NaN'::real, 'Infinity'::float);
+INSERT INTO points VALUES ('-Infinity'::real, '-Infinity'::float);`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[2].InsertStatement))
+
+	buf.Reset()
+	assert.Nil(t, mb.DumpSQL(&buf))
+	assert.Equal(t, `CREATE TABLE points (x real, y float);
+INSERT INTO points VALUES ('NaN'::real, 'Infinity'::float);
+INSERT INTO points VALUES ('-Infinity'::real, '-Infinity'::float);
+`, buf.String())
+
+	ast, err = Parse(buf.String())
+	assert.Nil(t, err)
+
+	replayed = NewMemoryBackend()
+	assert.Nil(t, replayed.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, replayed.Insert(ast.Statements[1].InsertStatement))
+	assert.Nil(t, replayed.Insert(ast.Statements[2].InsertStatement))
+	assert.Equal(t, mb.tables, replayed.tables)
+
+	// Version 1 dumps have no type modifiers
+	restored, err = Restore(bytes.NewBufferString(dumpMagic + "\x01\x01\x01t\x01\x02id\x01\x01\x05\x00\x00\x00\x07"))
+	assert.Nil(t, err)
+	assert.Equal(t, []typeModifier{{}}, restored.tables["t"].modifiers)
+	assert.Equal(t, int32(7), restored.tables["t"].rows[0][0].AsInt())
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "users", quoteIdentifier("users"))
+	assert.Equal(t, "selected", quoteIdentifier("selected"))
+	assert.Equal(t, "text", quoteIdentifier("text"))
+	assert.Equal(t, `"from"`, quoteIdentifier("from"))
+	assert.Equal(t, `"Users"`, quoteIdentifier("Users"))
+	assert.Equal(t, `"a""b"`, quoteIdentifier(`a"b`))
+}
@@ -1,13 +1,131 @@
 package gosql
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
-	ErrTableDoesNotExist  = errors.New("Table does not exist")
-	ErrColumnDoesNotExist = errors.New("Column does not exist")
-	ErrInvalidSelectItem  = errors.New("Select item is not valid")
-	ErrInvalidDatatype    = errors.New("Invalid datatype")
-	ErrMissingValues      = errors.New("Missing values")
-	ErrInvalidCell        = errors.New("Cell is invalid")
-	ErrInvalidOperands    = errors.New("Operands are invalid")
+	ErrTableDoesNotExist    = errors.New("Table does not exist")
+	ErrColumnDoesNotExist   = errors.New("Column does not exist")
+	ErrInvalidSelectItem    = errors.New("Select item is not valid")
+	ErrInvalidDatatype      = errors.New("Invalid datatype")
+	ErrMissingValues        = errors.New("Missing values")
+	ErrInvalidCell          = errors.New("Cell is invalid")
+	ErrInvalidOperands      = errors.New("Operands are invalid")
+	ErrInvalidDump          = errors.New("Dump is invalid")
+	ErrInvalidCopyData      = errors.New("Invalid COPY data")
+	ErrInvalidJSONData      = errors.New("Invalid JSON data")
+	ErrParameterCount       = errors.New("Wrong number of parameters")
+	ErrInvalidParameter     = errors.New("Parameter value is invalid")
+	ErrUnboundParameter     = errors.New("Parameter is not bound")
+	ErrNoRow                = errors.New("No current row")
+	ErrInvalidScan          = errors.New("Cannot scan into destination")
+	ErrQueryCanceled        = errors.New("Query canceled")
+	ErrUnknownSetting       = errors.New("Unrecognized setting")
+	ErrInvalidSetting       = errors.New("Invalid value for setting")
+	ErrNumericOutOfRange    = errors.New("Numeric value out of range")
+	ErrValueTooLong         = errors.New("Value too long for type")
+	ErrInvalidModifier      = errors.New("Invalid type modifier")
+	ErrInvalidDatetime      = errors.New("Invalid date/time value")
+	ErrInvalidUnit          = errors.New("Unit is not recognized")
+	ErrFunctionDoesNotExist = errors.New("Function does not exist")
+	ErrNotJSONArray         = errors.New("JSON value is not an array")
+	ErrInvalidBytea         = errors.New("Invalid bytea value")
+	ErrInvalidEncoding      = errors.New("Encoding is not recognized")
+	ErrInvalidInput         = errors.New("Invalid input syntax for type")
+	ErrCannotCast           = errors.New("Cannot cast type")
+	ErrNotSupported         = errors.New("Not supported by the backend")
+
+	ErrUnsupportedDumpVersion = errors.New("Dump version is not supported")
 )
+
+// ParseError is a syntax error found while lexing or parsing. Line and
+// Column are the 0-based position of the offending token, Column
+// counting bytes. Offset and End are the byte offsets of the token and
+// just past it in the source. Token is empty at the end of the source.
+type ParseError struct {
+	Line     uint
+	Column   uint
+	Offset   uint
+	End      uint
+	Token    string
+	Expected []string
+	Msg      string
+}
+
+// shift makes the error relative to the source a fragment is in.
+func (e *ParseError) shift(start location) *ParseError {
+	loc := location{line: e.Line, col: e.Column, offset: e.Offset}.shift(start)
+	e.Line, e.Column, e.Offset = loc.line, loc.col, loc.offset
+	e.End += start.offset
+	return e
+}
+
+func (e *ParseError) Error() string {
+	got := e.Token
+	if got == "" {
+		got = "end of input"
+	}
+
+	msg := fmt.Sprintf("[%d,%d]: %s, got: %s", e.Line, e.Column, e.Msg, got)
+	if len(e.Expected) > 1 {
+		msg += fmt.Sprintf(" (expected one of %s)", strings.Join(e.Expected, ", "))
+	}
+
+	return msg
+}
+
+// ParseErrors are the syntax errors found by ParseRecover, in source
+// order.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := []string{}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := []error{}
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// SourceError is an error executing a statement that was caused by one
+// of its tokens, such as the name of a missing column.
+// Positions are those of a ParseError.
+type SourceError struct {
+	Err    error
+	Line   uint
+	Column uint
+	Offset uint
+	End    uint
+	Token  string
+}
+
+func newSourceError(err error, t *token) *SourceError {
+	return &SourceError{
+		Err:    err,
+		Line:   t.loc.line,
+		Column: t.loc.col,
+		Offset: t.loc.offset,
+		End:    t.end.offset,
+		Token:  t.value,
+	}
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,535 @@
+package gosql
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// function is a built-in function. returns gives the type it returns
+// for arguments of the given types, and false if it does not take
+// them. params are the types given to parameters passed to it.
+type function struct {
+	params  []ColumnType
+	returns func(args []ColumnType) (ColumnType, bool)
+	call    func(t *table, args []MemoryCell, types []ColumnType) (MemoryCell, error)
+}
+
+var functions = map[string]function{
+	"now": {
+		returns: returnsIfNoArgs(TimestampTzType),
+		call:    callNow,
+	},
+	"current_timestamp": {
+		returns: returnsIfNoArgs(TimestampTzType),
+		call:    callNow,
+	},
+	"localtimestamp": {
+		returns: returnsIfNoArgs(TimestampType),
+		call:    callNow,
+	},
+	"current_date": {
+		returns: returnsIfNoArgs(DateType),
+		call: func(t *table, _ []MemoryCell, _ []ColumnType) (MemoryCell, error) {
+			return dateCell(daysOf(t.now.UTC())), nil
+		},
+	},
+	"date_trunc": {
+		params: []ColumnType{TextType, TimestampTzType},
+		returns: func(args []ColumnType) (ColumnType, bool) {
+			if len(args) != 2 || !isTextType(args[0]) || !isInstantType(args[1]) {
+				return 0, false
+			}
+
+			// Dates are truncated as the midnight they start at
+			if args[1] == DateType {
+				return TimestampTzType, true
+			}
+
+			return args[1], true
+		},
+		call: callDateTrunc,
+	},
+	"extract": {
+		params:  []ColumnType{TextType, TimestampTzType},
+		returns: returnsIfFieldOf(NumericType),
+		call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			r, scale, err := extractField(textValue(args[0], types[0]), args[1], types[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return decimalCell(r, scale), nil
+		},
+	},
+	"date_part": {
+		params:  []ColumnType{TextType, TimestampTzType},
+		returns: returnsIfFieldOf(FloatType),
+		call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			r, _, err := extractField(textValue(args[0], types[0]), args[1], types[1])
+			if err != nil {
+				return nil, err
+			}
+
+			f, _ := r.Float64()
+			return floatCell(f), nil
+		},
+	},
+	"length": {
+		params: []ColumnType{TextType},
+		returns: func(args []ColumnType) (ColumnType, bool) {
+			return IntType, len(args) == 1 && (isTextType(args[0]) || args[0] == ByteaType)
+		},
+		call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			if types[0] == ByteaType {
+				return intCell(int32(len(args[0]))), nil
+			}
+
+			return intCell(int32(utf8.RuneCountInString(textValue(args[0], types[0])))), nil
+		},
+	},
+	"substring": {
+		params: []ColumnType{TextType, IntType, IntType},
+		returns: func(args []ColumnType) (ColumnType, bool) {
+			if len(args) < 2 || len(args) > 3 || (!isTextType(args[0]) && args[0] != ByteaType) {
+				return 0, false
+			}
+
+			for _, arg := range args[1:] {
+				if !isIntegerType(arg) {
+					return 0, false
+				}
+			}
+
+			if args[0] == ByteaType {
+				return ByteaType, true
+			}
+
+			return TextType, true
+		},
+		call: callSubstring,
+	},
+	"encode": {
+		params: []ColumnType{ByteaType, TextType},
+		returns: func(args []ColumnType) (ColumnType, bool) {
+			return TextType, len(args) == 2 && args[0] == ByteaType && isTextType(args[1])
+		},
+		call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			s, err := encodeBytes(args[0], textValue(args[1], types[1]))
+			if err != nil {
+				return nil, err
+			}
+
+			return MemoryCell(s), nil
+		},
+	},
+	"decode": {
+		params: []ColumnType{TextType, TextType},
+		returns: func(args []ColumnType) (ColumnType, bool) {
+			return ByteaType, len(args) == 2 && isTextType(args[0]) && isTextType(args[1])
+		},
+		call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			return decodeBytes(textValue(args[0], types[0]), textValue(args[1], types[1]))
+		},
+	},
+}
+
+func init() {
+	for _, prefix := range []string{"json", "jsonb"} {
+		ct := JsonType
+		if prefix == "jsonb" {
+			ct = JsonbType
+		}
+
+		functions[prefix+"_extract_path"] = function{
+			params:  []ColumnType{ct, TextType},
+			returns: returnsIfPathOf(ct),
+			call:    callExtractPath(false),
+		}
+		functions[prefix+"_extract_path_text"] = function{
+			params:  []ColumnType{ct, TextType},
+			returns: returnsIfPathOf(TextType),
+			call:    callExtractPath(true),
+		}
+		functions[prefix+"_array_length"] = function{
+			params:  []ColumnType{ct},
+			returns: returnsIfJSON(IntType),
+			call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+				doc, err := jsonOperand(args[0], types[0])
+				if err != nil {
+					return nil, err
+				}
+
+				arr := []json.RawMessage{}
+				if jsonTypeOf(doc) != "array" || json.Unmarshal(doc, &arr) != nil {
+					return nil, ErrNotJSONArray
+				}
+
+				return intCell(int32(len(arr))), nil
+			},
+		}
+		functions[prefix+"_typeof"] = function{
+			params:  []ColumnType{ct},
+			returns: returnsIfJSON(TextType),
+			call: func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+				doc, err := jsonOperand(args[0], types[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return MemoryCell(jsonTypeOf(doc)), nil
+			},
+		}
+	}
+}
+
+func returnsIfNoArgs(ct ColumnType) func([]ColumnType) (ColumnType, bool) {
+	return func(args []ColumnType) (ColumnType, bool) {
+		return ct, len(args) == 0
+	}
+}
+
+// returnsIfFieldOf types functions taking a field name and a date,
+// time, timestamp or interval.
+func returnsIfFieldOf(ct ColumnType) func([]ColumnType) (ColumnType, bool) {
+	return func(args []ColumnType) (ColumnType, bool) {
+		return ct, len(args) == 2 && isTextType(args[0]) && isTemporalType(args[1])
+	}
+}
+
+// returnsIfJSON types functions taking a single JSON value, which can
+// also be given as text.
+func returnsIfJSON(ct ColumnType) func([]ColumnType) (ColumnType, bool) {
+	return func(args []ColumnType) (ColumnType, bool) {
+		return ct, len(args) == 1 && (isJSONType(args[0]) || isTextType(args[0]))
+	}
+}
+
+// returnsIfPathOf types functions taking a JSON value and the keys and
+// indexes of a path into it.
+func returnsIfPathOf(ct ColumnType) func([]ColumnType) (ColumnType, bool) {
+	return func(args []ColumnType) (ColumnType, bool) {
+		if len(args) == 0 || (!isJSONType(args[0]) && !isTextType(args[0])) {
+			return 0, false
+		}
+
+		for _, arg := range args[1:] {
+			if !isTextType(arg) {
+				return 0, false
+			}
+		}
+
+		return ct, true
+	}
+}
+
+// callExtractPath follows a path into a JSON value like #> does, or
+// like #>> if text is true.
+func callExtractPath(text bool) func(*table, []MemoryCell, []ColumnType) (MemoryCell, error) {
+	return func(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+		doc, err := jsonOperand(args[0], types[0])
+		if err != nil {
+			return nil, err
+		}
+
+		path := []string{}
+		for i, arg := range args[1:] {
+			path = append(path, textValue(arg, types[i+1]))
+		}
+
+		value, ok := jsonPath(doc, path)
+		if !ok {
+			value = json.RawMessage("null")
+		}
+
+		if text {
+			return MemoryCell(jsonText(value)), nil
+		}
+
+		return MemoryCell(value), nil
+	}
+}
+
+// callSubstring returns count characters of text, or bytes of bytea,
+// from the 1-based start on. Like PostgreSQL the ones a start before 1
+// counts are taken out of the count, and no count means the rest.
+func callSubstring(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+	var runes []rune
+	n := int64(len(args[0]))
+	if types[0] != ByteaType {
+		runes = []rune(textValue(args[0], types[0]))
+		n = int64(len(runes))
+	}
+
+	start := args[1].AsBigInt()
+	end := n + 1
+	if len(args) == 3 {
+		count := args[2].AsBigInt()
+		if count < 0 {
+			return nil, ErrInvalidOperands
+		}
+
+		end = min(start+count, end)
+	}
+
+	start = min(max(start, 1), n+1)
+	end = max(end, start)
+
+	if types[0] == ByteaType {
+		return args[0][start-1 : end-1], nil
+	}
+
+	return MemoryCell(string(runes[start-1 : end-1])), nil
+}
+
+func callNow(t *table, _ []MemoryCell, _ []ColumnType) (MemoryCell, error) {
+	return timestampCell(t.now), nil
+}
+
+func callDateTrunc(_ *table, args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+	field := fieldName(textValue(args[0], types[0]))
+	t := args[1].AsTime()
+	y, m, d := t.Date()
+
+	switch field {
+	case "microsecond":
+	case "millisecond":
+		t = t.Truncate(time.Millisecond)
+	case "second":
+		t = t.Truncate(time.Second)
+	case "minute":
+		t = t.Truncate(time.Minute)
+	case "hour":
+		t = t.Truncate(time.Hour)
+	case "day":
+		t = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case "week":
+		// Weeks start on Monday
+		t = time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case "month":
+		t = time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		t = time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		t = time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	case "decade":
+		t = time.Date(y-floorMod(y, 10), 1, 1, 0, 0, 0, 0, time.UTC)
+	case "century":
+		// Centuries and millennia start at years ending in 1
+		t = time.Date(y-floorMod(y-1, 100), 1, 1, 0, 0, 0, 0, time.UTC)
+	case "millennium":
+		t = time.Date(y-floorMod(y-1, 1000), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return nil, ErrInvalidUnit
+	}
+
+	return timestampCell(t), nil
+}
+
+func floorMod(a, b int) int {
+	return ((a % b) + b) % b
+}
+
+// fieldName returns the singular name of a field of a date or time,
+// which can be written like the units of an interval.
+func fieldName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if alias, ok := intervalUnitAliases[s]; ok {
+		return alias
+	}
+
+	return s
+}
+
+// extractField returns a field of a date, time, timestamp or interval,
+// such as the year or the second, and the scale it is shown with.
+// Seconds have a fraction of up to microseconds.
+func extractField(name string, mc MemoryCell, ct ColumnType) (*big.Rat, int, error) {
+	field := fieldName(name)
+	whole := func(i int) (*big.Rat, int, error) {
+		return new(big.Rat).SetInt64(int64(i)), 0, nil
+	}
+
+	var micros int64
+	switch ct {
+	case IntervalType:
+		return extractIntervalField(field, mc.asInterval())
+	case TimeType:
+		micros = mc.AsBigInt()
+	case DateType:
+		micros = -1
+	default:
+		micros = int64(floorMod(int(mc.AsBigInt()%microsPerDay), int(microsPerDay)))
+	}
+
+	// Fields of the time of day, which dates do not have
+	if micros >= 0 {
+		switch field {
+		case "hour":
+			return whole(int(micros / microsPerHour))
+		case "minute":
+			return whole(int(micros / microsPerMinute % 60))
+		case "second", "millisecond", "microsecond":
+			return secondsField(field, micros%microsPerMinute)
+		case "epoch":
+			if ct == TimeType {
+				return big.NewRat(micros, microsPerSecond), 6, nil
+			}
+
+			return big.NewRat(mc.AsBigInt(), microsPerSecond), 6, nil
+		}
+	}
+
+	if ct == TimeType {
+		return nil, 0, ErrInvalidUnit
+	}
+
+	t := mc.AsTime()
+	y := t.Year()
+	switch field {
+	case "day":
+		return whole(t.Day())
+	case "month":
+		return whole(int(t.Month()))
+	case "quarter":
+		return whole((int(t.Month())-1)/3 + 1)
+	case "year":
+		return whole(y)
+	case "decade":
+		return whole((y - floorMod(y, 10)) / 10)
+	case "century":
+		return whole((y-floorMod(y-1, 100))/100 + 1)
+	case "millennium":
+		return whole((y-floorMod(y-1, 1000))/1000 + 1)
+	case "dow":
+		return whole(int(t.Weekday()))
+	case "isodow":
+		return whole((int(t.Weekday())+6)%7 + 1)
+	case "doy":
+		return whole(t.YearDay())
+	case "week":
+		_, week := t.ISOWeek()
+		return whole(week)
+	case "isoyear":
+		year, _ := t.ISOWeek()
+		return whole(year)
+	case "epoch":
+		return whole(int(t.Unix()))
+	}
+
+	return nil, 0, ErrInvalidUnit
+}
+
+// secondsField returns the seconds, milliseconds or microseconds of a
+// number of microseconds less than a minute.
+func secondsField(field string, micros int64) (*big.Rat, int, error) {
+	switch field {
+	case "second":
+		return big.NewRat(micros, microsPerSecond), 6, nil
+	case "millisecond":
+		return big.NewRat(micros, 1000), 3, nil
+	}
+
+	return new(big.Rat).SetInt64(micros), 0, nil
+}
+
+// extractIntervalField returns a field of an interval. Its epoch counts
+// years as 365.25 days and months as 30 days like PostgreSQL does.
+func extractIntervalField(field string, iv interval) (*big.Rat, int, error) {
+	whole := func(i int64) (*big.Rat, int, error) {
+		return new(big.Rat).SetInt64(i), 0, nil
+	}
+
+	switch field {
+	case "year":
+		return whole(int64(iv.months / 12))
+	case "month":
+		return whole(int64(iv.months % 12))
+	case "day":
+		return whole(int64(iv.days))
+	case "hour":
+		return whole(iv.micros / microsPerHour)
+	case "minute":
+		return whole(iv.micros / microsPerMinute % 60)
+	case "second", "millisecond", "microsecond":
+		return secondsField(field, iv.micros%microsPerMinute)
+	case "epoch":
+		days := new(big.Rat).Mul(big.NewRat(int64(iv.months/12), 1), big.NewRat(36525, 100))
+		days.Add(days, big.NewRat(int64(iv.months%12)*30+int64(iv.days), 1))
+		seconds := days.Mul(days, big.NewRat(86400, 1))
+		return seconds.Add(seconds, big.NewRat(iv.micros, microsPerSecond)), 6, nil
+	}
+
+	return nil, 0, ErrInvalidUnit
+}
+
+// evaluateCallCell calls a built-in function, naming the column after
+// it.
+func (t *table) evaluateCallCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
+	call := exp.call
+
+	fn, ok := functions[call.name.value]
+	if !ok {
+		return nil, "", 0, newSourceError(ErrFunctionDoesNotExist, &call.name)
+	}
+
+	args := []MemoryCell{}
+	types := []ColumnType{}
+	for _, arg := range call.args {
+		mc, _, ct, err := t.evaluateCell(rowIndex, arg)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		args = append(args, mc)
+		types = append(types, ct)
+	}
+
+	ct, ok := fn.returns(types)
+	if !ok {
+		return nil, "", 0, newSourceError(ErrFunctionDoesNotExist, &call.name)
+	}
+
+	mc, err := fn.call(t, args, types)
+	if err != nil {
+		return nil, "", 0, newSourceError(err, &call.name)
+	}
+
+	return mc, call.name.value, ct, nil
+}
+
+// typeOfCall is the type a function returns for the arguments of a
+// call. Parameters passed to it get the type it takes there.
+func (t *table) typeOfCall(call *callExpression, params map[uint]ColumnType) (string, ColumnType, bool, error) {
+	fn, ok := functions[call.name.value]
+	if !ok {
+		return "", 0, false, newSourceError(ErrFunctionDoesNotExist, &call.name)
+	}
+
+	types := []ColumnType{}
+	for i, arg := range call.args {
+		if i < len(fn.params) {
+			inferParameter(arg, fn.params[i], params)
+		}
+
+		_, ct, ok, err := t.typeOf(arg, params)
+		if err != nil {
+			return "", 0, false, err
+		}
+
+		if !ok {
+			return call.name.value, 0, false, nil
+		}
+
+		types = append(types, ct)
+	}
+
+	ct, ok := fn.returns(types)
+	if !ok {
+		return "", 0, false, newSourceError(ErrFunctionDoesNotExist, &call.name)
+	}
+
+	return call.name.value, ct, true, nil
+}
@@ -0,0 +1,214 @@
+package gosql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// cellToValue converts a cell to the Go value of its type. Dates and
+// timestamps are a time.Time in UTC, and times, intervals and bytea
+// their text.
+func cellToValue(c Cell, ct ColumnType) any {
+	switch ct {
+	case SmallIntType, IntType:
+		return c.AsInt()
+	case BigIntType:
+		return c.AsBigInt()
+	case RealType:
+		return float32(c.AsFloat())
+	case FloatType:
+		return c.AsFloat()
+	case NumericType:
+		return json.Number(c.AsText())
+	case BoolType:
+		return c.AsBool()
+	case DateType, TimestampType, TimestampTzType:
+		return c.AsTime()
+	case TimeType, IntervalType:
+		return formatTemporal(c, ct)
+	case JsonType, JsonbType:
+		return json.RawMessage(c.AsText())
+	case ByteaType:
+		return formatBytea(c)
+	default:
+		return c.AsText()
+	}
+}
+
+// marshalRow encodes a row as a JSON object keeping the keys in column
+// order, which a map would not.
+func (r *Results) marshalRow(row []Cell) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, col := range r.Columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(col.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(cellToValue(row[i], col.Type))
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// WriteJSON writes the results to w as a JSON array with one object per
+// row, keyed by column name.
+func (r *Results) WriteJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+
+	for i, row := range r.Rows {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+
+		obj, err := r.marshalRow(row)
+		if err != nil {
+			return err
+		}
+
+		bw.Write(obj)
+	}
+
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// WriteNDJSON writes the results to w as newline-delimited JSON, one
+// object per row.
+func (r *Results) WriteNDJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, row := range r.Rows {
+		obj, err := r.marshalRow(row)
+		if err != nil {
+			return err
+		}
+
+		bw.Write(obj)
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
+
+func jsonToMemoryCell(value any, ct ColumnType) (MemoryCell, error) {
+	switch {
+	case isNumericType(ct):
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, ErrInvalidDatatype
+		}
+
+		return parseNumeric(n.String(), ct)
+
+	case ct == BoolType:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, ErrInvalidDatatype
+		}
+
+		if b {
+			return trueMemoryCell, nil
+		}
+		return falseMemoryCell, nil
+
+	case isTemporalType(ct):
+		s, ok := value.(string)
+		if !ok {
+			return nil, ErrInvalidDatatype
+		}
+
+		return parseTemporal(s, ct)
+
+	case isJSONType(ct):
+		// Any JSON value goes, written out again the way jsonb is
+		return parseJSON(formatJSON(value), ct)
+
+	case ct == ByteaType:
+		s, ok := value.(string)
+		if !ok {
+			return nil, ErrInvalidDatatype
+		}
+
+		return parseBytea(s)
+
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return nil, ErrInvalidDatatype
+		}
+
+		return MemoryCell(s), nil
+	}
+}
+
+// LoadNDJSON inserts one row per JSON object read from r into the named
+// table. Every column must be present with a value of the matching type
+// and unknown keys are rejected. No rows are inserted if any object is
+// invalid.
+func (mb *MemoryBackend) LoadNDJSON(tableName string, r io.Reader) (uint, error) {
+	t, ok := mb.tables[tableName]
+	if !ok {
+		return 0, ErrTableDoesNotExist
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	rows := [][]MemoryCell{}
+	for n := 1; ; n++ {
+		obj := map[string]any{}
+		err := dec.Decode(&obj)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w, object %d: %s", ErrInvalidJSONData, n, err)
+		}
+
+		if len(obj) != len(t.colums) {
+			return 0, fmt.Errorf("%w, object %d: expected %d keys, got %d", ErrInvalidJSONData, n, len(t.colums), len(obj))
+		}
+
+		row := []MemoryCell{}
+		for i, col := range t.colums {
+			value, ok := obj[col]
+			if !ok {
+				return 0, fmt.Errorf("%w, object %d: missing key %q", ErrInvalidJSONData, n, col)
+			}
+
+			cell, err := jsonToMemoryCell(value, t.columnTypes[i])
+			if err == nil {
+				cell, err = t.storeCell(i, cell, t.columnTypes[i])
+			}
+			if err != nil {
+				return 0, fmt.Errorf("%w, object %d: invalid value %v for %s", ErrInvalidJSONData, n, value, col)
+			}
+
+			row = append(row, cell)
+		}
+
+		rows = append(rows, row)
+	}
+
+	t.rows = append(t.rows, rows...)
+	return uint(len(rows)), nil
+}
@@ -0,0 +1,68 @@
+package gosql
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultsJSON(t *testing.T) {
+	mb := newDumpFixture(t)
+
+	ast, err := Parse("SELECT id, name, active FROM users;")
+	assert.Nil(t, err)
+
+	results, err := mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, results.WriteJSON(&buf))
+	assert.Equal(t, `[{"id":1,"name":"Phil","active":true},{"id":-2,"name":"O'Brien","active":false},{"id":3,"name":"","active":true}]`+"\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, results.WriteNDJSON(&buf))
+	assert.Equal(t, `{"id":1,"name":"Phil","active":true}
+{"id":-2,"name":"O'Brien","active":false}
+{"id":3,"name":"","active":true}
+`, buf.String())
+
+	loaded := NewMemoryBackend()
+	loaded.tables["users"] = &table{
+		colums:      mb.tables["users"].colums,
+		columnTypes: mb.tables["users"].columnTypes,
+	}
+
+	n, err := loaded.LoadNDJSON("users", &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), n)
+	assert.Equal(t, mb.tables["users"].rows, loaded.tables["users"].rows)
+}
+
+func TestLoadNDJSONInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{input: `{"id":1,"name":"a","active":true}` + "\n" + `{"id":"2","name":"b","active":true}`, msg: "object 2"},
+		{input: `{"id":1.5,"name":"a","active":true}`, msg: "invalid value 1.5 for id"},
+		{input: `{"id":3000000000,"name":"a","active":true}`, msg: "invalid value"},
+		{input: `{"id":1,"name":"a"}`, msg: "expected 3 keys"},
+		{input: `{"id":1,"name":"a","enabled":true}`, msg: `missing key "active"`},
+		{input: `{"id":1,"name":"a","active":1}`, msg: "for active"},
+		{input: `{"id":1,`, msg: "object 1"},
+	}
+
+	for _, test := range tests {
+		mb := newDumpFixture(t)
+		_, err := mb.LoadNDJSON("users", strings.NewReader(test.input))
+		assert.True(t, errors.Is(err, ErrInvalidJSONData), test.input)
+		assert.Contains(t, err.Error(), test.msg, test.input)
+		assert.Equal(t, 3, len(mb.tables["users"].rows), test.input)
+	}
+
+	_, err := NewMemoryBackend().LoadNDJSON("users", strings.NewReader(""))
+	assert.Equal(t, ErrTableDoesNotExist, err)
+}
@@ -0,0 +1,410 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON values are stored as their text. json keeps the text it was
+// given while jsonb is normalized the way PostgreSQL prints it, with
+// object keys sorted and only the last of duplicate keys kept.
+
+func isJSONType(ct ColumnType) bool {
+	return ct == JsonType || ct == JsonbType
+}
+
+// decodeJSON parses a single JSON value, keeping numbers exact.
+func decodeJSON(s string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, ErrInvalidJSONData
+	}
+
+	_, err = dec.Token()
+	if err != io.EOF {
+		return nil, ErrInvalidJSONData
+	}
+
+	return v, nil
+}
+
+// parseJSON checks that s is JSON and converts it to a cell of the
+// json or jsonb type ct.
+func parseJSON(s string, ct ColumnType) (MemoryCell, error) {
+	v, err := decodeJSON(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if ct == JsonType {
+		return MemoryCell(s), nil
+	}
+
+	return MemoryCell(formatJSON(v)), nil
+}
+
+// formatJSON formats a decoded JSON value the way PostgreSQL prints
+// jsonb. Keys are sorted shortest first and numbers are written like
+// numeric values.
+func formatJSON(v any) string {
+	var sb strings.Builder
+	writeJSON(&sb, v)
+	return sb.String()
+}
+
+func writeJSON(sb *strings.Builder, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := []string{}
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+
+		sb.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+
+			sb.WriteString(jsonString(k))
+			sb.WriteString(": ")
+			writeJSON(sb, v[k])
+		}
+		sb.WriteByte('}')
+
+	case []any:
+		sb.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+
+			writeJSON(sb, e)
+		}
+		sb.WriteByte(']')
+
+	case string:
+		sb.WriteString(jsonString(v))
+
+	case json.Number:
+		r, scale, ok := parseDecimal(v.String())
+		if !ok {
+			sb.WriteString(v.String())
+			return
+		}
+
+		sb.WriteString(decimalCell(r, scale).AsText())
+
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+
+	default:
+		sb.WriteString("null")
+	}
+}
+
+// jsonString quotes s as a JSON string, leaving characters such as <
+// that encoding/json escapes for HTML alone.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonText returns the text of a JSON value as ->> does: strings
+// without their quotes and anything else as JSON. There being no NULL,
+// JSON null is the empty string.
+func jsonText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	if string(raw) == "null" {
+		return ""
+	}
+
+	return string(raw)
+}
+
+// jsonTypeOf names the type of a JSON value as json_typeof does.
+func jsonTypeOf(raw json.RawMessage) string {
+	switch raw[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+
+	return "number"
+}
+
+// jsonField returns the value of a key of a JSON object, the last one
+// if the key is repeated. The bool is false if there is no such key or
+// raw is not an object.
+func jsonField(raw json.RawMessage, key string) (json.RawMessage, bool) {
+	obj := map[string]json.RawMessage{}
+	if raw[0] != '{' || json.Unmarshal(raw, &obj) != nil {
+		return nil, false
+	}
+
+	v, ok := obj[key]
+	return bytes.TrimSpace(v), ok
+}
+
+// jsonIndex returns an element of a JSON array. Negative indexes count
+// from the end.
+func jsonIndex(raw json.RawMessage, i int) (json.RawMessage, bool) {
+	arr := []json.RawMessage{}
+	if raw[0] != '[' || json.Unmarshal(raw, &arr) != nil {
+		return nil, false
+	}
+
+	if i < 0 {
+		i += len(arr)
+	}
+
+	if i < 0 || i >= len(arr) {
+		return nil, false
+	}
+
+	return bytes.TrimSpace(arr[i]), true
+}
+
+// jsonPath follows a path of keys and array indexes into a JSON value.
+func jsonPath(raw json.RawMessage, path []string) (json.RawMessage, bool) {
+	for _, step := range path {
+		var ok bool
+		if raw[0] == '[' {
+			i, err := strconv.Atoi(step)
+			if err != nil {
+				return nil, false
+			}
+
+			raw, ok = jsonIndex(raw, i)
+		} else {
+			raw, ok = jsonField(raw, step)
+		}
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return raw, true
+}
+
+// parsePath reads a path written as a text array, such as {a,b,0}.
+func parsePath(s string) ([]string, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, false
+	}
+
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return []string{}, true
+	}
+
+	path := []string{}
+	for _, step := range strings.Split(s, ",") {
+		step = strings.TrimSpace(step)
+		if len(step) >= 2 && step[0] == '"' && step[len(step)-1] == '"' {
+			step = step[1 : len(step)-1]
+		}
+
+		path = append(path, step)
+	}
+
+	return path, true
+}
+
+// jsonContains tells whether a contains b the way @> does: objects
+// contain objects whose keys they all have with contained values,
+// arrays contain arrays whose elements they all contain as well as the
+// scalars among their elements, and other values contain equal ones.
+func jsonContains(a, b any, top bool) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		bo, ok := b.(map[string]any)
+		if !ok {
+			return false
+		}
+
+		for k, bv := range bo {
+			av, ok := a[k]
+			if !ok || !jsonContains(av, bv, false) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		ba, ok := b.([]any)
+		if !ok {
+			if _, ok := b.(map[string]any); ok || !top {
+				return false
+			}
+
+			// A top level array contains the scalars it has
+			ba = []any{b}
+		}
+
+		for _, bv := range ba {
+			found := false
+			for _, av := range a {
+				if jsonContains(av, bv, false) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+		return true
+
+	case json.Number:
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+
+		x, _, xok := parseDecimal(a.String())
+		y, _, yok := parseDecimal(bn.String())
+		return xok && yok && x.Cmp(y) == 0
+	}
+
+	return a == b
+}
+
+// jsonOperand returns the text of a JSON cell, or of text that must be
+// JSON.
+func jsonOperand(mc MemoryCell, ct ColumnType) (json.RawMessage, error) {
+	if isJSONType(ct) {
+		return bytes.TrimSpace(mc), nil
+	}
+
+	s := textValue(mc, ct)
+	_, err := decodeJSON(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace([]byte(s)), nil
+}
+
+// jsonOperator applies ->, ->>, #>, #>> or @> to a json or jsonb value
+// and a key, index, path or other value. A key that is not there gives
+// JSON null, or the empty string for the operators that give text.
+func jsonOperator(op symbol, l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (MemoryCell, ColumnType, error) {
+	ct, ok := typeOfJSONOperator(op, lt, rt)
+	if !ok {
+		return nil, 0, ErrInvalidOperands
+	}
+
+	doc, err := jsonOperand(l, lt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if op == containsSymbol {
+		other, err := jsonOperand(r, rt)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		a, _ := decodeJSON(string(doc))
+		b, _ := decodeJSON(string(other))
+		if jsonContains(a, b, true) {
+			return trueMemoryCell, BoolType, nil
+		}
+		return falseMemoryCell, BoolType, nil
+	}
+
+	var value json.RawMessage
+	switch {
+	case op == pathSymbol || op == pathTextSymbol:
+		path, ok := parsePath(textValue(r, rt))
+		if !ok {
+			return nil, 0, ErrInvalidOperands
+		}
+
+		value, ok = jsonPath(doc, path)
+	case isIntegerType(rt):
+		value, ok = jsonIndex(doc, int(r.AsBigInt()))
+	default:
+		value, ok = jsonField(doc, textValue(r, rt))
+	}
+
+	if !ok {
+		value = json.RawMessage("null")
+	}
+
+	if ct == TextType {
+		return MemoryCell(jsonText(value)), ct, nil
+	}
+
+	return MemoryCell(value), ct, nil
+}
+
+// typeOfJSONOperator is the type jsonOperator gives, and false if it
+// does not apply to the operands. Text on the left is read as json,
+// except by @>, which takes jsonb on the left.
+func typeOfJSONOperator(op symbol, lt, rt ColumnType) (ColumnType, bool) {
+	if !isJSONType(lt) && !isTextType(lt) {
+		return 0, false
+	}
+
+	result := JsonType
+	if lt == JsonbType {
+		result = JsonbType
+	}
+
+	switch op {
+	case arrowSymbol, arrowTextSymbol:
+		if !isTextType(rt) && !isIntegerType(rt) {
+			return 0, false
+		}
+	case pathSymbol, pathTextSymbol:
+		if !isTextType(rt) {
+			return 0, false
+		}
+	case containsSymbol:
+		// Only jsonb has containment; text stands in for a literal
+		ok := lt == JsonbType && (rt == JsonbType || isTextType(rt))
+		return BoolType, ok
+	default:
+		return 0, false
+	}
+
+	if op == arrowTextSymbol || op == pathTextSymbol {
+		return TextType, true
+	}
+
+	return result, true
+}
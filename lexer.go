@@ -1,13 +1,50 @@
 package gosql
 
 import (
-	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// location is a position in the source. line and col are 0-based and
+// col counts bytes from the start of the line. offset counts bytes from
+// the start of the source. "\r\n" and a lone "\r" end a line like "\n".
 type location struct {
-	line uint
-	col  uint
+	line   uint
+	col    uint
+	offset uint
+}
+
+// advance returns the location of the byte at pointer, which must not
+// be before loc.
+func (loc location) advance(source string, pointer uint) location {
+	for loc.offset < pointer {
+		c := source[loc.offset]
+		loc.offset++
+
+		isCRLF := c == '\r' && loc.offset < uint(len(source)) && source[loc.offset] == '\n'
+		if (c == '\n' || c == '\r') && !isCRLF {
+			loc.line++
+			loc.col = 0
+			continue
+		}
+
+		loc.col++
+	}
+
+	return loc
+}
+
+// shift makes a location in a fragment of a source relative to the
+// source, given where the fragment starts.
+func (loc location) shift(start location) location {
+	if loc.line == 0 {
+		loc.col += start.col
+	}
+
+	loc.line += start.line
+	loc.offset += start.offset
+	return loc
 }
 
 type keyword string
@@ -15,7 +52,9 @@ type keyword string
 const (
 	selectKeyword keyword = "select"
 	fromKeyword   keyword = "from"
+	forKeyword    keyword = "for"
 	asKeyword     keyword = "as"
+	castKeyword   keyword = "cast"
 	tableKeyword  keyword = "table"
 	createKeyword keyword = "create"
 	insertKeyword keyword = "insert"
@@ -24,11 +63,46 @@ const (
 	intKeyword    keyword = "int"
 	textKeyword   keyword = "text"
 	boolKeyword   keyword = "boolean"
+	floatKeyword  keyword = "float"
 	whereKeyword  keyword = "where"
 	andKeyword    keyword = "and"
 	orKeyword     keyword = "or"
-	// trueKeyword   keyword = "true"
-	// falseKeyword  keyword = "false"
+	trueKeyword   keyword = "true"
+	falseKeyword  keyword = "false"
+	copyKeyword   keyword = "copy"
+	toKeyword     keyword = "to"
+	withKeyword   keyword = "with"
+	setKeyword    keyword = "set"
+
+	headerKeyword    keyword = "header"
+	delimiterKeyword keyword = "delimiter"
+
+	smallintKeyword  keyword = "smallint"
+	bigintKeyword    keyword = "bigint"
+	realKeyword      keyword = "real"
+	doubleKeyword    keyword = "double"
+	precisionKeyword keyword = "precision"
+	numericKeyword   keyword = "numeric"
+	decimalKeyword   keyword = "decimal"
+	varcharKeyword   keyword = "varchar"
+	charKeyword      keyword = "char"
+	characterKeyword keyword = "character"
+	varyingKeyword   keyword = "varying"
+	jsonKeyword      keyword = "json"
+	jsonbKeyword     keyword = "jsonb"
+	byteaKeyword     keyword = "bytea"
+
+	dateKeyword        keyword = "date"
+	timeKeyword        keyword = "time"
+	timestampKeyword   keyword = "timestamp"
+	timestamptzKeyword keyword = "timestamptz"
+	intervalKeyword    keyword = "interval"
+	zoneKeyword        keyword = "zone"
+	withoutKeyword     keyword = "without"
+
+	currentDateKeyword      keyword = "current_date"
+	currentTimestampKeyword keyword = "current_timestamp"
+	localtimestampKeyword   keyword = "localtimestamp"
 )
 
 type symbol string
@@ -49,8 +123,143 @@ const (
 	plusSymbol       symbol = "+"
 	minusSymbol      symbol = "-"
 	asteriskSymbol   symbol = "*"
+	arrowSymbol      symbol = "->"
+	arrowTextSymbol  symbol = "->>"
+	pathSymbol       symbol = "#>"
+	pathTextSymbol   symbol = "#>>"
+	containsSymbol   symbol = "@>"
+	castSymbol       symbol = "::"
+)
+
+var keywords = []keyword{
+	selectKeyword,
+	insertKeyword,
+	valuesKeyword,
+	tableKeyword,
+	createKeyword,
+	whereKeyword,
+	orKeyword,
+	andKeyword,
+	fromKeyword,
+	forKeyword,
+	intoKeyword,
+	textKeyword,
+	intKeyword,
+	boolKeyword,
+	floatKeyword,
+	trueKeyword,
+	falseKeyword,
+	copyKeyword,
+	toKeyword,
+	withKeyword,
+	setKeyword,
+	headerKeyword,
+	delimiterKeyword,
+	asKeyword,
+	castKeyword,
+	smallintKeyword,
+	bigintKeyword,
+	realKeyword,
+	doubleKeyword,
+	precisionKeyword,
+	numericKeyword,
+	decimalKeyword,
+	varcharKeyword,
+	charKeyword,
+	characterKeyword,
+	varyingKeyword,
+	jsonKeyword,
+	jsonbKeyword,
+	byteaKeyword,
+	dateKeyword,
+	timeKeyword,
+	timestampKeyword,
+	timestamptzKeyword,
+	intervalKeyword,
+	zoneKeyword,
+	withoutKeyword,
+	currentDateKeyword,
+	currentTimestampKeyword,
+	localtimestampKeyword,
+}
+
+// unreservedKeywords can also be used as table, column and setting
+// names.
+var unreservedKeywords = map[keyword]bool{
+	textKeyword:        true,
+	intKeyword:         true,
+	boolKeyword:        true,
+	floatKeyword:       true,
+	copyKeyword:        true,
+	setKeyword:         true,
+	headerKeyword:      true,
+	delimiterKeyword:   true,
+	smallintKeyword:    true,
+	bigintKeyword:      true,
+	realKeyword:        true,
+	doubleKeyword:      true,
+	precisionKeyword:   true,
+	numericKeyword:     true,
+	decimalKeyword:     true,
+	varcharKeyword:     true,
+	charKeyword:        true,
+	characterKeyword:   true,
+	varyingKeyword:     true,
+	jsonKeyword:        true,
+	jsonbKeyword:       true,
+	byteaKeyword:       true,
+	dateKeyword:        true,
+	timeKeyword:        true,
+	timestampKeyword:   true,
+	timestamptzKeyword: true,
+	intervalKeyword:    true,
+	zoneKeyword:        true,
+	withoutKeyword:     true,
+}
+
+func (k keyword) reserved() bool {
+	return !unreservedKeywords[k]
+}
+
+var symbols = []symbol{
+	commaSymbol,
+	leftParenSymbol,
+	rightParenSymbol,
+	semicolonSymbol,
+	eqSymbol,
+	neqSymbol,
+	neqSymbol2,
+	ltSymbol,
+	lteSymbol,
+	gtSymbol,
+	gteSymbol,
+	plusSymbol,
+	minusSymbol,
+	concatSymbol,
+	asteriskSymbol,
+	arrowSymbol,
+	arrowTextSymbol,
+	pathSymbol,
+	pathTextSymbol,
+	containsSymbol,
+	castSymbol,
+}
+
+// The tries are built once so lexing a token only allocates the token.
+var (
+	keywordTrie = newTrie(wordsOf(keywords))
+	symbolTrie  = newTrie(wordsOf(symbols))
 )
 
+func wordsOf[T ~string](words []T) []string {
+	strs := make([]string, len(words))
+	for i, w := range words {
+		strs[i] = string(w)
+	}
+
+	return strs
+}
+
 type tokenKind uint
 
 const (
@@ -60,12 +269,26 @@ const (
 	stringKind
 	numericKind
 	boolKind
+	placeholderKind
+	// commentKind tokens are only kept when asked for, the parser never
+	// sees them
+	commentKind
+	// spaceKind tokens stand for runs of whitespace and are never kept
+	spaceKind
 )
 
+// tokenSlabSize is how many tokens lexTokens allocates at once.
+const tokenSlabSize = 256
+
 type token struct {
 	value string
 	kind  tokenKind
-	loc   location
+	// quoted is set for double-quoted identifiers, whose value is
+	// kept as written rather than lowercased
+	quoted bool
+	// loc is where the token starts and end just past its last byte
+	loc location
+	end location
 }
 
 type cursor struct {
@@ -77,78 +300,135 @@ func (t *token) equals(other *token) bool {
 	return t.value == other.value && t.kind == other.kind
 }
 
-type lexer func(string, cursor) (*token, cursor, bool)
+// trie finds the longest of a set of lowercase words that prefixes the
+// source. ASCII letters in the source match case-insensitively.
+type trie struct {
+	edges []trieEdge
+	// word is set when the path to this node spells one
+	word string
+}
 
-func longestMatch(source string, ic cursor, options []string) string {
-	var value []byte
-	var skipList []int
-	var match string
+type trieEdge struct {
+	c    byte
+	next *trie
+}
 
-	cur := ic
+func newTrie(words []string) *trie {
+	root := &trie{}
+	for _, w := range words {
+		node := root
+		for i := 0; i < len(w); i++ {
+			next := node.child(w[i])
+			if next == nil {
+				next = &trie{}
+				node.edges = append(node.edges, trieEdge{w[i], next})
+			}
 
-	for cur.pointer < uint(len(source)) {
-		value = append(value, strings.ToLower(string(source[cur.pointer]))...)
-		cur.pointer++
+			node = next
+		}
 
-	match:
-		for i, option := range options {
-			for _, skip := range skipList {
-				if i == skip {
-					continue match
-				}
-			}
+		node.word = w
+	}
 
-			// Deal with cases like INT vs INTO
-			if option == string(value) {
-				skipList = append(skipList, i)
-				if len(option) > len(match) {
-					match = option
-				}
-				continue
-			}
+	return root
+}
 
-			sharesPrefix := string(value) == option[:cur.pointer-ic.pointer]
-			tooLong := len(value) > len(option)
-			if tooLong || !sharesPrefix {
-				skipList = append(skipList, i)
-			}
+func (t *trie) child(c byte) *trie {
+	for _, e := range t.edges {
+		if e.c == c {
+			return e.next
+		}
+	}
+
+	return nil
+}
+
+func (t *trie) longestMatch(source string) string {
+	var match string
+
+	node := t
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
 		}
 
-		if len(skipList) == len(options) {
+		node = node.child(c)
+		if node == nil {
 			break
 		}
+
+		if node.word != "" {
+			match = node.word
+		}
 	}
 
 	return match
 }
 
-func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
+func lexHex(source string, ic cursor) (token, cursor, bool) {
+	cur := ic
+
+	rest := source[cur.pointer:]
+	if len(rest) < 3 || rest[0] != '0' || (rest[1] != 'x' && rest[1] != 'X') {
+		return token{}, ic, false
+	}
+	cur.pointer += 2
+
+	for ; cur.pointer < uint(len(source)); cur.pointer++ {
+		c := source[cur.pointer]
+		isHexDigit := (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+		if !isHexDigit {
+			break
+		}
+	}
+
+	// Must be followed by at least one digit
+	if cur.pointer == ic.pointer+2 {
+		return token{}, ic, false
+	}
+
+	return token{
+		value: source[ic.pointer:cur.pointer],
+		loc:   ic.loc,
+		kind:  numericKind,
+	}, cur, true
+}
+
+func lexNumeric(source string, ic cursor) (token, cursor, bool) {
+	if token, cur, ok := lexHex(source, ic); ok {
+		return token, cur, true
+	}
+
 	cur := ic
 
 	periodFound := false
 	expMarkerFound := false
+	// A number needs digits, and so does its exponent if it has one
+	digitFound := false
+	expDigitFound := false
 
 	for ; cur.pointer < uint(len(source)); cur.pointer++ {
 		c := source[cur.pointer]
-		cur.loc.col++
 
 		isDigit := c >= '0' && c <= '9'
 		isPeriod := c == '.'
-		isExpMarker := c == 'e'
+		isExpMarker := c == 'e' || c == 'E'
 
 		// Must start with a digit or period
 		if cur.pointer == ic.pointer {
 			if !isDigit && !isPeriod {
-				return nil, ic, false
+				return token{}, ic, false
 			}
 
 			periodFound = isPeriod
+			digitFound = isDigit
 			continue
 		}
 
 		if isPeriod {
 			if periodFound {
-				return nil, ic, false
+				return token{}, ic, false
 			}
 
 			periodFound = true
@@ -157,7 +437,7 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 
 		if isExpMarker {
 			if expMarkerFound {
-				return nil, ic, false
+				return token{}, ic, false
 			}
 
 			// No periods allowed after expMarker
@@ -166,13 +446,12 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 
 			// expMarker must be followed by digits
 			if cur.pointer == uint(len(source)-1) {
-				return nil, ic, false
+				return token{}, ic, false
 			}
 
 			cNext := source[cur.pointer+1]
 			if cNext == '-' || cNext == '+' {
 				cur.pointer++
-				cur.loc.col++
 			}
 
 			continue
@@ -181,243 +460,417 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 		if !isDigit {
 			break
 		}
+
+		if expMarkerFound {
+			expDigitFound = true
+		} else {
+			digitFound = true
+		}
 	}
 
 	// No characters accumulated
-	if cur.pointer == ic.pointer {
-		return nil, ic, false
+	if cur.pointer == ic.pointer || !digitFound || (expMarkerFound && !expDigitFound) {
+		return token{}, ic, false
 	}
 
-	return &token{
+	return token{
 		value: source[ic.pointer:cur.pointer],
 		loc:   ic.loc,
 		kind:  numericKind,
 	}, cur, true
 }
 
-func lexCharacterDelimited(source string, ic cursor, delimiter byte) (*token, cursor, bool) {
+// malformedNumberLength is the length of what looks like a number at
+// the start of source, so that an error can point at all of it.
+func malformedNumberLength(source string) int {
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		isSign := (c == '+' || c == '-') && i > 0 && (source[i-1] == 'e' || source[i-1] == 'E')
+		if !isSign && c != '.' && !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') {
+			break
+		}
+		i++
+	}
+
+	return i
+}
+
+func lexCharacterDelimited(source string, ic cursor, delimiter byte) (token, cursor, bool) {
 	cur := ic
 
-	if len(source[cur.pointer:]) == 0 {
-		return nil, ic, false
+	if cur.pointer >= uint(len(source)) || source[cur.pointer] != delimiter {
+		return token{}, ic, false
 	}
 
-	if source[cur.pointer] != delimiter {
-		return nil, ic, false
+	cur.pointer++
+	escaped := false
+	for ; cur.pointer < uint(len(source)); cur.pointer++ {
+		if source[cur.pointer] != delimiter {
+			continue
+		}
+
+		// SQL escapes are via double characters, not backslash.
+		if cur.pointer+1 < uint(len(source)) && source[cur.pointer+1] == delimiter {
+			escaped = true
+			cur.pointer++
+			continue
+		}
+
+		// The value is a slice of the source unless it has escapes
+		value := source[ic.pointer+1 : cur.pointer]
+		if escaped {
+			d := string(delimiter)
+			value = strings.ReplaceAll(value, d+d, d)
+		}
+
+		cur.pointer++
+		return token{
+			value: value,
+			loc:   ic.loc,
+			kind:  stringKind,
+		}, cur, true
 	}
 
-	cur.loc.col++
-	cur.pointer++
+	return token{}, ic, false
+}
 
-	var value []byte
-	for ; cur.pointer < uint(len(source)); cur.pointer++ {
-		c := source[cur.pointer]
+// lexComment lexes a -- comment up to the end of the line or a /* */
+// comment, which may be nested.
+func lexComment(source string, ic cursor) (token, cursor, bool) {
+	cur := ic
+	rest := source[cur.pointer:]
 
-		if c == delimiter {
-			// SQL escapes are via double characters, not backslash.
-			if cur.pointer+1 >= uint(len(source)) || source[cur.pointer+1] != delimiter {
-				cur.pointer++
-				cur.loc.col++
-				return &token{
-					value: string(value),
-					loc:   ic.loc,
-					kind:  stringKind,
-				}, cur, true
-			} else {
-				value = append(value, delimiter)
-				cur.pointer++
-				cur.loc.col++
+	if strings.HasPrefix(rest, "--") {
+		end := strings.IndexAny(rest, "\r\n")
+		if end == -1 {
+			end = len(rest)
+		}
+
+		cur.pointer += uint(end)
+		return token{value: rest[:end], kind: commentKind, loc: ic.loc}, cur, true
+	}
+
+	if !strings.HasPrefix(rest, "/*") {
+		return token{}, ic, false
+	}
+
+	depth := 0
+	for cur.pointer < uint(len(source)) {
+		switch {
+		case strings.HasPrefix(source[cur.pointer:], "/*"):
+			depth++
+			cur.pointer += 2
+			continue
+
+		case strings.HasPrefix(source[cur.pointer:], "*/"):
+			depth--
+			cur.pointer += 2
+			if depth == 0 {
+				return token{value: source[ic.pointer:cur.pointer], kind: commentKind, loc: ic.loc}, cur, true
 			}
+			continue
 		}
 
-		value = append(value, c)
-		cur.loc.col++
+		cur.pointer++
 	}
 
-	return nil, ic, false
+	// Unterminated
+	return token{}, ic, false
 }
 
-func lexString(source string, ic cursor) (*token, cursor, bool) {
+func lexString(source string, ic cursor) (token, cursor, bool) {
 	return lexCharacterDelimited(source, ic, '\'')
 }
 
-func lexSymbol(source string, ic cursor) (*token, cursor, bool) {
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func lexSymbol(source string, ic cursor) (token, cursor, bool) {
 	c := source[ic.pointer]
 	cur := ic
 	// Will get overwritten later if not an ignored syntax
 	cur.pointer++
-	cur.loc.col++
 
-	switch c {
-	// Syntax that should be thrown away
-	case '\n':
-		cur.loc.line++
-		cur.loc.col = 0
-		fallthrough
-	case '\t':
-		fallthrough
-	case ' ':
-		return nil, cur, true
+	// Syntax that should be thrown away, a whole run at once
+	if isSpace(c) {
+		for cur.pointer < uint(len(source)) && isSpace(source[cur.pointer]) {
+			cur.pointer++
+		}
+
+		return token{kind: spaceKind}, cur, true
 	}
 
 	// Arrow exception
 	if ic.pointer+1 < uint(len(source)) {
 		arrowSymbol := source[ic.pointer : ic.pointer+2]
 		if arrowSymbol == "=>" {
-			return nil, ic, false
+			return token{}, ic, false
 		}
 	}
 
-	// Syntax that should be kept
-	symbols := []symbol{
-		commaSymbol,
-		leftParenSymbol,
-		rightParenSymbol,
-		semicolonSymbol,
-		eqSymbol,
-		neqSymbol,
-		neqSymbol2,
-		ltSymbol,
-		lteSymbol,
-		gtSymbol,
-		gteSymbol,
-		plusSymbol,
-		minusSymbol,
-		concatSymbol,
-		asteriskSymbol,
-	}
-
-	var options []string
-	for _, s := range symbols {
-		options = append(options, string(s))
-	}
-
-	// Use `ic`, not `cur`
-	match := longestMatch(source, ic, options)
+	match := symbolTrie.longestMatch(source[ic.pointer:])
 	// Unknown character
 	if match == "" {
-		return nil, ic, false
+		return token{}, ic, false
 	}
 
 	cur.pointer = ic.pointer + uint(len(match))
-	cur.loc.col = ic.loc.col + uint(len(match))
 
-	return &token{
+	return token{
 		value: match,
 		loc:   ic.loc,
 		kind:  symbolKind,
 	}, cur, true
 }
 
-func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
+func lexKeyword(source string, ic cursor) (token, cursor, bool) {
 	cur := ic
-	keywords := []keyword{
-		selectKeyword,
-		insertKeyword,
-		valuesKeyword,
-		tableKeyword,
-		createKeyword,
-		whereKeyword,
-		orKeyword,
-		andKeyword,
-		fromKeyword,
-		intoKeyword,
-		textKeyword,
-		intKeyword,
-		asKeyword,
-	}
-
-	var options []string
-	for _, k := range keywords {
-		options = append(options, string(k))
-	}
-
-	match := longestMatch(source, ic, options)
+
+	match := keywordTrie.longestMatch(source[ic.pointer:])
 	if match == "" {
-		return nil, ic, false
+		return token{}, ic, false
+	}
+
+	// Keywords only match whole words, so selected is an identifier
+	if rest := source[ic.pointer+uint(len(match)):]; rest != "" {
+		r, _ := utf8.DecodeRuneInString(rest)
+		if isIdentifierPart(r) {
+			return token{}, ic, false
+		}
 	}
 
 	cur.pointer = ic.pointer + uint(len(match))
-	cur.loc.col = ic.loc.col + uint(len(match))
 
-	return &token{
+	kind := keywordKind
+	if match == string(trueKeyword) || match == string(falseKeyword) {
+		kind = boolKind
+	}
+
+	return token{
 		value: match,
-		kind:  keywordKind,
+		kind:  kind,
 		loc:   ic.loc,
 	}, cur, true
 }
 
-func lexIdentifier(source string, ic cursor) (*token, cursor, bool) {
-	// Handle separately if is a double-quoted identifier
-	if token, newCursor, ok := lexCharacterDelimited(source, ic, '"'); ok {
-		return token, newCursor, true
-	}
-
+func lexPlaceholder(source string, ic cursor) (token, cursor, bool) {
 	cur := ic
 
-	c := source[cur.pointer]
-	// Other characters count too, big ignoring non-ascii for now
-	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-	if !isAlphabetical {
-		return nil, ic, false
+	// Positional ? placeholders are numbered by the parser
+	if source[cur.pointer] == '?' {
+		cur.pointer++
+
+		return token{
+			value: "?",
+			loc:   ic.loc,
+			kind:  placeholderKind,
+		}, cur, true
+	}
+
+	if source[cur.pointer] != '$' {
+		return token{}, ic, false
 	}
 	cur.pointer++
-	cur.loc.col++
 
-	value := []byte{c}
 	for ; cur.pointer < uint(len(source)); cur.pointer++ {
-		c = source[cur.pointer]
-
-		// Other characters count too, big ignoring non-ascii for now
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
-			value = append(value, c)
-			cur.loc.col++
-			continue
+		c := source[cur.pointer]
+		if c < '0' || c > '9' {
+			break
+		}
+	}
+
+	// Must be followed by at least one digit
+	if cur.pointer == ic.pointer+1 {
+		return token{}, ic, false
+	}
+
+	return token{
+		value: source[ic.pointer:cur.pointer],
+		loc:   ic.loc,
+		kind:  placeholderKind,
+	}, cur, true
+}
+
+// isIdentifierStart and isIdentifierPart follow the SQL standard:
+// identifiers start with a letter and continue with letters, marks,
+// digits, connectors like underscore and, as in PostgreSQL, dollar
+// signs.
+func isIdentifierStart(r rune) bool {
+	if r < utf8.RuneSelf {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}
+
+	return unicode.In(r, unicode.L, unicode.Nl)
+}
+
+func isIdentifierPart(r rune) bool {
+	if r < utf8.RuneSelf {
+		return isIdentifierStart(r) || (r >= '0' && r <= '9') || r == '_' || r == '$'
+	}
+
+	return isIdentifierStart(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc)
+}
+
+func lexIdentifier(source string, ic cursor) (token, cursor, bool) {
+	// Handle separately if is a double-quoted identifier
+	if t, newCursor, ok := lexCharacterDelimited(source, ic, '"'); ok {
+		// Quoted identifiers keep their case and cannot be empty
+		if t.value == "" {
+			return token{}, ic, false
 		}
 
-		break
+		t.kind = identifierKind
+		t.quoted = true
+		return t, newCursor, true
+	}
+
+	cur := ic
+
+	r, size := utf8.DecodeRuneInString(source[cur.pointer:])
+	if !isIdentifierStart(r) {
+		return token{}, ic, false
 	}
+	cur.pointer += uint(size)
+
+	for cur.pointer < uint(len(source)) {
+		r, size = rune(source[cur.pointer]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(source[cur.pointer:])
+		}
+
+		if !isIdentifierPart(r) {
+			break
+		}
 
-	if len(value) == 0 {
-		return nil, ic, false
+		cur.pointer += uint(size)
 	}
 
-	return &token{
-		// Unquoted dentifiers are case-insensitive
-		value: strings.ToLower(string(value)),
+	return token{
+		// Unquoted identifiers are case-insensitive
+		value: strings.ToLower(source[ic.pointer:cur.pointer]),
 		loc:   ic.loc,
 		kind:  identifierKind,
 	}, cur, true
 }
 
 func lex(source string) ([]*token, error) {
+	tokens, errs := lexTokens(source, false, false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return tokens, nil
+}
+
+// lexNext picks the lexer for the next token from its first
+// character, trying keywords before identifiers.
+func lexNext(source string, ic cursor) (token, cursor, bool) {
+	c := source[ic.pointer]
+	var next byte
+	if ic.pointer+1 < uint(len(source)) {
+		next = source[ic.pointer+1]
+	}
+
+	switch {
+	case c == '-' && next == '-', c == '/' && next == '*':
+		return lexComment(source, ic)
+	case c == '\'':
+		return lexString(source, ic)
+	case c == '"':
+		return lexIdentifier(source, ic)
+	case (c >= '0' && c <= '9') || c == '.':
+		return lexNumeric(source, ic)
+	case c == '?', c == '$':
+		return lexPlaceholder(source, ic)
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= utf8.RuneSelf:
+		if token, cur, ok := lexKeyword(source, ic); ok {
+			return token, cur, true
+		}
+
+		return lexIdentifier(source, ic)
+	}
+
+	return lexSymbol(source, ic)
+}
+
+// lexTokens stops at the first character that cannot be lexed unless
+// recovering, in which case the statement it is in is dropped and
+// lexing resumes after the next semicolon. Comments are dropped unless
+// keepComments is set.
+func lexTokens(source string, recovering, keepComments bool) ([]*token, ParseErrors) {
 	tokens := []*token{}
+	errs := ParseErrors{}
 	cur := cursor{}
+	semicolonToken := tokenFromSymbol(semicolonSymbol)
 
-lex:
-	for cur.pointer < uint(len(source)) {
-		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexNumeric, lexIdentifier}
-		for _, l := range lexers {
-			if token, newCursor, ok := l(source, cur); ok {
-				cur = newCursor
+	// Tokens are allocated a slab at a time rather than one by one
+	var slab []token
 
-				// Omit nil tokens for valid, but empty syntax like newlines
-				if token != nil {
-					tokens = append(tokens, token)
-				}
+	for cur.pointer < uint(len(source)) {
+		if t, newCursor, ok := lexNext(source, cur); ok {
+			// Lexers only move the pointer
+			newCursor.loc = cur.loc.advance(source, newCursor.pointer)
+			t.loc = cur.loc
+			t.end = newCursor.loc
+			cur = newCursor
+
+			// Omit valid but empty syntax like newlines, and comments
+			// unless asked for
+			if t.kind == spaceKind || (t.kind == commentKind && !keepComments) {
+				continue
+			}
 
-				continue lex
+			if len(slab) == cap(slab) {
+				slab = make([]token, 0, tokenSlabSize)
 			}
+			slab = append(slab, t)
+			tokens = append(tokens, &slab[len(slab)-1])
+			continue
 		}
 
-		hint := ""
+		msg := "Unable to lex token"
 		if len(tokens) > 0 {
-			hint = " after " + tokens[len(tokens)-1].value
+			msg += " after " + tokens[len(tokens)-1].value
+		}
+
+		_, size := utf8.DecodeRuneInString(source[cur.pointer:])
+		switch c := source[cur.pointer]; {
+		case strings.HasPrefix(source[cur.pointer:], "/*"):
+			msg = "Unterminated block comment"
+		case strings.HasPrefix(source[cur.pointer:], `""`):
+			msg = "Zero-length quoted identifier"
+		case (c >= '0' && c <= '9') || c == '.':
+			msg = "Invalid number"
+			size = malformedNumberLength(source[cur.pointer:])
 		}
-		return nil, fmt.Errorf("Unable to lex token%s, at %d:%d", hint, cur.loc.line, cur.loc.col)
+
+		errs = append(errs, &ParseError{
+			Line:   cur.loc.line,
+			Column: cur.loc.col,
+			Offset: cur.pointer,
+			End:    cur.pointer + uint(size),
+			Token:  source[cur.pointer : cur.pointer+uint(size)],
+			Msg:    msg,
+		})
+
+		if !recovering {
+			return nil, errs
+		}
+
+		for len(tokens) > 0 && !tokens[len(tokens)-1].equals(&semicolonToken) {
+			tokens = tokens[:len(tokens)-1]
+		}
+
+		end := strings.IndexByte(source[cur.pointer:], ';')
+		if end == -1 {
+			end = len(source[cur.pointer:]) - 1
+		}
+
+		cur.pointer += uint(end) + 1
+		cur.loc = cur.loc.advance(source, cur.pointer)
 	}
 
-	return tokens, nil
+	return tokens, errs
 }
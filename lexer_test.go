@@ -1,6 +1,8 @@
 package gosql
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,6 +37,18 @@ func TestLexNumeric(t *testing.T) {
 			number: true,
 			value:  "1.e21",
 		},
+		{
+			number: true,
+			value:  "1E-5",
+		},
+		{
+			number: true,
+			value:  "0x1F",
+		},
+		{
+			number: true,
+			value:  "0XaB ",
+		},
 		{
 			number: true,
 			value:  "1.1e2",
@@ -186,9 +200,25 @@ func TestTokenLexSymbol(t *testing.T) {
 			value:  " ",
 		},
 		{
-			symbol: false,
+			symbol: true,
 			value:  "->",
 		},
+		{
+			symbol: true,
+			value:  "->>",
+		},
+		{
+			symbol: true,
+			value:  "#>",
+		},
+		{
+			symbol: true,
+			value:  "@>",
+		},
+		{
+			symbol: true,
+			value:  "::",
+		},
 		{
 			symbol: true,
 			value:  "+",
@@ -249,10 +279,38 @@ func TestTokenLexIdentifier(t *testing.T) {
 			input:      `"userName"`,
 			value:      "userName",
 		},
+		{
+			Identifier: true,
+			input:      `"say ""hi"""`,
+			value:      `say "hi"`,
+		},
+		{
+			Identifier: true,
+			input:      "Straße_1",
+			value:      "straße_1",
+		},
+		{
+			Identifier: true,
+			input:      "日本語 ",
+			value:      "日本語",
+		},
+		{
+			Identifier: true,
+			input:      "ÉCOLE",
+			value:      "école",
+		},
 		{
 			Identifier: false,
 			input:      `"`,
 		},
+		{
+			Identifier: false,
+			input:      `""`,
+		},
+		{
+			Identifier: false,
+			input:      "٣abc",
+		},
 		{
 			Identifier: false,
 			input:      "_sadsfa",
@@ -274,7 +332,11 @@ func TestTokenLexIdentifier(t *testing.T) {
 			t.Errorf("lexIdentifier() failed test case %v", tt.input)
 		}
 
-		if token != nil && token.value != tt.value {
+		if result && token.value != tt.value {
+			t.Errorf("lexIdentifier() failed test case %v", tt.input)
+		}
+
+		if result && (token.kind != identifierKind || token.quoted != strings.HasPrefix(tt.input, `"`)) {
 			t.Errorf("lexIdentifier() failed test case %v", tt.input)
 		}
 	}
@@ -291,12 +353,14 @@ func TestLex(t *testing.T) {
 			input: "select a",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: string(selectKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
+					loc:   location{line: 0, col: 7, offset: 7},
+					end:   location{line: 0, col: 8, offset: 8},
 					value: "a",
 					kind:  identifierKind,
 				},
@@ -323,12 +387,14 @@ func TestLex(t *testing.T) {
 			input: "select 1",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: string(selectKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
+					loc:   location{line: 0, col: 7, offset: 7},
+					end:   location{line: 0, col: 8, offset: 8},
 					value: "1",
 					kind:  numericKind,
 				},
@@ -372,52 +438,62 @@ func TestLex(t *testing.T) {
 			input: "CREATE TABLE u (id INT, name TEXT)",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: string(createKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
+					loc:   location{line: 0, col: 7, offset: 7},
+					end:   location{line: 0, col: 12, offset: 12},
 					value: string(tableKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 13, line: 0},
+					loc:   location{line: 0, col: 13, offset: 13},
+					end:   location{line: 0, col: 14, offset: 14},
 					value: "u",
 					kind:  identifierKind,
 				},
 				{
-					loc:   location{col: 15, line: 0},
+					loc:   location{line: 0, col: 15, offset: 15},
+					end:   location{line: 0, col: 16, offset: 16},
 					value: "(",
 					kind:  symbolKind,
 				},
 				{
-					loc:   location{col: 16, line: 0},
+					loc:   location{line: 0, col: 16, offset: 16},
+					end:   location{line: 0, col: 18, offset: 18},
 					value: "id",
 					kind:  identifierKind,
 				},
 				{
-					loc:   location{col: 19, line: 0},
+					loc:   location{line: 0, col: 19, offset: 19},
+					end:   location{line: 0, col: 22, offset: 22},
 					value: "int",
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 22, line: 0},
+					loc:   location{line: 0, col: 22, offset: 22},
+					end:   location{line: 0, col: 23, offset: 23},
 					value: ",",
 					kind:  symbolKind,
 				},
 				{
-					loc:   location{col: 24, line: 0},
+					loc:   location{line: 0, col: 24, offset: 24},
+					end:   location{line: 0, col: 28, offset: 28},
 					value: "name",
 					kind:  identifierKind,
 				},
 				{
-					loc:   location{col: 29, line: 0},
+					loc:   location{line: 0, col: 29, offset: 29},
+					end:   location{line: 0, col: 33, offset: 33},
 					value: "text",
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 33, line: 0},
+					loc:   location{line: 0, col: 33, offset: 33},
+					end:   location{line: 0, col: 34, offset: 34},
 					value: ")",
 					kind:  symbolKind,
 				},
@@ -427,47 +503,56 @@ func TestLex(t *testing.T) {
 			input: "insert into users Values (105, 233)",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: string(insertKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
+					loc:   location{line: 0, col: 7, offset: 7},
+					end:   location{line: 0, col: 11, offset: 11},
 					value: string(intoKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 12, line: 0},
+					loc:   location{line: 0, col: 12, offset: 12},
+					end:   location{line: 0, col: 17, offset: 17},
 					value: "users",
 					kind:  identifierKind,
 				},
 				{
-					loc:   location{col: 18, line: 0},
+					loc:   location{line: 0, col: 18, offset: 18},
+					end:   location{line: 0, col: 24, offset: 24},
 					value: string(valuesKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 25, line: 0},
+					loc:   location{line: 0, col: 25, offset: 25},
+					end:   location{line: 0, col: 26, offset: 26},
 					value: "(",
 					kind:  symbolKind,
 				},
 				{
-					loc:   location{col: 26, line: 0},
+					loc:   location{line: 0, col: 26, offset: 26},
+					end:   location{line: 0, col: 29, offset: 29},
 					value: "105",
 					kind:  numericKind,
 				},
 				{
-					loc:   location{col: 30, line: 0},
+					loc:   location{line: 0, col: 29, offset: 29},
+					end:   location{line: 0, col: 30, offset: 30},
 					value: ",",
 					kind:  symbolKind,
 				},
 				{
-					loc:   location{col: 32, line: 0},
+					loc:   location{line: 0, col: 31, offset: 31},
+					end:   location{line: 0, col: 34, offset: 34},
 					value: "233",
 					kind:  numericKind,
 				},
 				{
-					loc:   location{col: 36, line: 0},
+					loc:   location{line: 0, col: 34, offset: 34},
+					end:   location{line: 0, col: 35, offset: 35},
 					value: ")",
 					kind:  symbolKind,
 				},
@@ -478,27 +563,32 @@ func TestLex(t *testing.T) {
 			input: "SELECT id FROM users;",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: string(selectKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
+					loc:   location{line: 0, col: 7, offset: 7},
+					end:   location{line: 0, col: 9, offset: 9},
 					value: "id",
 					kind:  identifierKind,
 				},
 				{
-					loc:   location{col: 10, line: 0},
+					loc:   location{line: 0, col: 10, offset: 10},
+					end:   location{line: 0, col: 14, offset: 14},
 					value: string(fromKeyword),
 					kind:  keywordKind,
 				},
 				{
-					loc:   location{col: 15, line: 0},
+					loc:   location{line: 0, col: 15, offset: 15},
+					end:   location{line: 0, col: 20, offset: 20},
 					value: "users",
 					kind:  identifierKind,
 				},
 				{
-					loc:   location{col: 20, line: 0},
+					loc:   location{line: 0, col: 20, offset: 20},
+					end:   location{line: 0, col: 21, offset: 21},
 					value: ";",
 					kind:  symbolKind,
 				},
@@ -509,7 +599,8 @@ func TestLex(t *testing.T) {
 			input: "INT",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 3, offset: 3},
 					value: "int",
 					kind:  keywordKind,
 				},
@@ -519,7 +610,8 @@ func TestLex(t *testing.T) {
 			input: "SELECT",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: "select",
 					kind:  keywordKind,
 				},
@@ -529,7 +621,8 @@ func TestLex(t *testing.T) {
 			input: "FROM",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 4, offset: 4},
 					value: "from",
 					kind:  keywordKind,
 				},
@@ -539,7 +632,8 @@ func TestLex(t *testing.T) {
 			input: "AS",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 2, offset: 2},
 					value: "as",
 					kind:  keywordKind,
 				},
@@ -549,7 +643,8 @@ func TestLex(t *testing.T) {
 			input: "TABLE",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 5, offset: 5},
 					value: "table",
 					kind:  keywordKind,
 				},
@@ -559,7 +654,8 @@ func TestLex(t *testing.T) {
 			input: "CREATE",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: "create",
 					kind:  keywordKind,
 				},
@@ -569,7 +665,8 @@ func TestLex(t *testing.T) {
 			input: "INSERT",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: "insert",
 					kind:  keywordKind,
 				},
@@ -579,7 +676,8 @@ func TestLex(t *testing.T) {
 			input: "INTO",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 4, offset: 4},
 					value: "into",
 					kind:  keywordKind,
 				},
@@ -589,7 +687,8 @@ func TestLex(t *testing.T) {
 			input: "VALUES",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: "values",
 					kind:  keywordKind,
 				},
@@ -599,7 +698,8 @@ func TestLex(t *testing.T) {
 			input: "TEXT",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 4, offset: 4},
 					value: "text",
 					kind:  keywordKind,
 				},
@@ -609,17 +709,20 @@ func TestLex(t *testing.T) {
 			input: "('300')",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 1, offset: 1},
 					value: "(",
 					kind:  symbolKind,
 				},
 				{
-					loc:   location{col: 1, line: 0},
+					loc:   location{line: 0, col: 1, offset: 1},
+					end:   location{line: 0, col: 6, offset: 6},
 					value: "300",
 					kind:  stringKind,
 				},
 				{
-					loc:   location{col: 6, line: 0},
+					loc:   location{line: 0, col: 6, offset: 6},
+					end:   location{line: 0, col: 7, offset: 7},
 					value: ")",
 					kind:  symbolKind,
 				},
@@ -629,7 +732,8 @@ func TestLex(t *testing.T) {
 			input: "WHERE",
 			Tokens: []token{
 				{
-					loc:   location{col: 0, line: 0},
+					loc:   location{line: 0, col: 0, offset: 0},
+					end:   location{line: 0, col: 5, offset: 5},
 					value: "where",
 					kind:  keywordKind,
 				},
@@ -648,6 +752,39 @@ func TestLex(t *testing.T) {
 	}
 }
 
+func TestLexWordBoundaries(t *testing.T) {
+	tests := []struct {
+		input string
+		kinds []tokenKind
+		value []string
+	}{
+		{"selected", []tokenKind{identifierKind}, []string{"selected"}},
+		{"fromage", []tokenKind{identifierKind}, []string{"fromage"}},
+		{"order_id", []tokenKind{identifierKind}, []string{"order_id"}},
+		{"integer", []tokenKind{identifierKind}, []string{"integer"}},
+		{"into1", []tokenKind{identifierKind}, []string{"into1"}},
+		{"as$", []tokenKind{identifierKind}, []string{"as$"}},
+		{"truely", []tokenKind{identifierKind}, []string{"truely"}},
+		{"selectÉ", []tokenKind{identifierKind}, []string{"selecté"}},
+		{"INTO", []tokenKind{keywordKind}, []string{"into"}},
+		{"int,", []tokenKind{keywordKind, symbolKind}, []string{"int", ","}},
+		{"true)", []tokenKind{boolKind, symbolKind}, []string{"true", ")"}},
+		{"select*", []tokenKind{keywordKind, symbolKind}, []string{"select", "*"}},
+		{"from'a'", []tokenKind{keywordKind, stringKind}, []string{"from", "a"}},
+	}
+
+	for _, test := range tests {
+		tokens, err := lex(test.input)
+		assert.Nil(t, err, test.input)
+		assert.Equal(t, len(test.kinds), len(tokens), test.input)
+
+		for i, tok := range tokens {
+			assert.Equal(t, test.kinds[i], tok.kind, test.input)
+			assert.Equal(t, test.value[i], tok.value, test.input)
+		}
+	}
+}
+
 func TestLongestMatch(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -713,10 +850,100 @@ func TestLongestMatch(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := longestMatch(tt.source, cursor{}, tt.options)
+			result := newTrie(tt.options).longestMatch(tt.source)
 			if result != tt.expected {
 				t.Errorf("longestMatch() = %v, want %v", result, tt.expected)
 			}
 		})
 	}
+
+	// The prebuilt keyword trie stops at the longest keyword
+	assert.Equal(t, string(intoKeyword), keywordTrie.longestMatch("into users"))
+	assert.Equal(t, string(intKeyword), keywordTrie.longestMatch("int)"))
+}
+
+func TestLexComments(t *testing.T) {
+	source := "select -- pick\n/* a /* nested */\ncomment */ a /**/;"
+
+	tokens, err := lex(source)
+	assert.Nil(t, err)
+	assert.Equal(t, []*token{
+		{loc: location{line: 0, col: 0, offset: 0}, end: location{line: 0, col: 6, offset: 6}, value: string(selectKeyword), kind: keywordKind},
+		{loc: location{line: 2, col: 11, offset: 44}, end: location{line: 2, col: 12, offset: 45}, value: "a", kind: identifierKind},
+		{loc: location{line: 2, col: 17, offset: 50}, end: location{line: 2, col: 18, offset: 51}, value: ";", kind: symbolKind},
+	}, tokens)
+
+	trivia, errs := lexTokens(source, false, true)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, &token{loc: location{line: 0, col: 7, offset: 7}, end: location{line: 0, col: 14, offset: 14}, value: "-- pick", kind: commentKind}, trivia[1])
+	assert.Equal(t, &token{loc: location{line: 1, col: 0, offset: 15}, end: location{line: 2, col: 10, offset: 43}, value: "/* a /* nested */\ncomment */", kind: commentKind}, trivia[2])
+	assert.Equal(t, &token{loc: location{line: 2, col: 13, offset: 46}, end: location{line: 2, col: 17, offset: 50}, value: "/**/", kind: commentKind}, trivia[4])
+
+	_, err = lex("select 1 /* /* */")
+	assert.Equal(t, &ParseError{Line: 0, Column: 9, Offset: 9, End: 10, Token: "/", Msg: "Unterminated block comment"}, err)
+
+	ast, err := Parse("-- create\nCREATE TABLE t (id INT); -- done")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ast.Statements))
+}
+
+func TestLexPositions(t *testing.T) {
+	source := "SELECT 'a\nb',\r\n  12 FROM\rt"
+
+	tokens, err := lex(source)
+	assert.Nil(t, err)
+	assert.Equal(t, []*token{
+		{loc: location{line: 0, col: 0, offset: 0}, end: location{line: 0, col: 6, offset: 6}, value: string(selectKeyword), kind: keywordKind},
+		{loc: location{line: 0, col: 7, offset: 7}, end: location{line: 1, col: 2, offset: 12}, value: "a\nb", kind: stringKind},
+		{loc: location{line: 1, col: 2, offset: 12}, end: location{line: 1, col: 3, offset: 13}, value: ",", kind: symbolKind},
+		{loc: location{line: 2, col: 2, offset: 17}, end: location{line: 2, col: 4, offset: 19}, value: "12", kind: numericKind},
+		{loc: location{line: 2, col: 5, offset: 20}, end: location{line: 2, col: 9, offset: 24}, value: string(fromKeyword), kind: keywordKind},
+		{loc: location{line: 3, col: 0, offset: 25}, end: location{line: 3, col: 1, offset: 26}, value: "t", kind: identifierKind},
+	}, tokens)
+
+	_, err = Parse("SELECT 1\r\nFROM")
+	assert.Equal(t, &ParseError{Line: 1, Column: 4, Offset: 14, End: 14, Msg: "Expected table name after FROM"}, err)
+}
+
+func TestLexAllocations(t *testing.T) {
+	source := "SELECT id, name AS n FROM users WHERE (id >= 10) AND (name <> 'x'); -- done"
+	tokens, err := lex(source)
+	assert.Nil(t, err)
+
+	// One allocation per token plus growing the token slice
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = lex(source)
+	})
+	assert.LessOrEqual(t, allocs, float64(len(tokens)+6))
+}
+
+func benchmarkSource() string {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "INSERT INTO Users VALUES (%d, 'user %d', true); -- seed\n", i, i)
+		fmt.Fprintf(&sb, "SELECT id, name AS fullname FROM users WHERE (id >= %d) AND (name <> 'it''s');\n", i)
+	}
+
+	return sb.String()
+}
+
+func BenchmarkLex(b *testing.B) {
+	source := benchmarkSource()
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_, err := lex(source)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLexKeyword(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		lexKeyword("delimiter", cursor{})
+	}
 }
@@ -2,22 +2,37 @@ package gosql
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type MemoryCell []byte
 
+// AsInt decodes a smallint or int cell.
 func (mc MemoryCell) AsInt() int32 {
-	var i int32
-	err := binary.Read(bytes.NewBuffer(mc), binary.BigEndian, &i)
-	if err != nil {
-		fmt.Printf("Corrupted data [%s]: %s\n", mc, err)
-		return 0
+	switch len(mc) {
+	case 2:
+		return int32(int16(binary.BigEndian.Uint16(mc)))
+	case 4:
+		return int32(binary.BigEndian.Uint32(mc))
+	}
+
+	return 0
+}
+
+// AsBigInt decodes a smallint, int or bigint cell.
+func (mc MemoryCell) AsBigInt() int64 {
+	if len(mc) == 8 {
+		return int64(binary.BigEndian.Uint64(mc))
 	}
 
-	return i
+	return int64(mc.AsInt())
 }
 
 func (mc MemoryCell) AsText() string {
@@ -36,51 +51,103 @@ func (mc MemoryCell) equals(b MemoryCell) bool {
 	return bytes.Compare(mc, b) == 0
 }
 
-func literalToMemoryCell(t *token) MemoryCell {
-	if t.kind == numericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.value)
-		if err != nil {
-			fmt.Printf("Corrupted data [%s]: %s\n", t.value, err)
-			return MemoryCell(nil)
-		}
+// AsTime decodes a date or timestamp cell. Times of day are on
+// 1970-01-01.
+func (mc MemoryCell) AsTime() time.Time {
+	switch len(mc) {
+	case 4:
+		return time.Unix(int64(mc.AsInt())*86400, 0).UTC()
+	case 8:
+		return time.UnixMicro(mc.AsBigInt()).UTC()
+	}
 
-		err = binary.Write(buf, binary.BigEndian, int32(i))
-		if err != nil {
-			fmt.Printf("Corrupted data [%s]: %s\n", string(buf.Bytes()), err)
-			return MemoryCell(nil)
-		}
+	return time.Time{}
+}
 
-		return MemoryCell(buf.Bytes())
+// AsFloat decodes a real or double precision cell.
+func (mc MemoryCell) AsFloat() float64 {
+	switch len(mc) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(mc)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(mc))
 	}
 
-	if t.kind == stringKind {
-		return MemoryCell(t.value)
-	}
+	return 0
+}
 
-	if t.kind == boolKind {
-		if t.value == "true" {
-			return MemoryCell([]byte{1})
-		} else {
-			return MemoryCell(nil)
+func literalToMemoryCell(t *token) (MemoryCell, ColumnType, error) {
+	switch t.kind {
+	case numericKind:
+		return numericToMemoryCell(t.value)
+	case boolKind:
+		if t.value == string(trueKeyword) {
+			return trueMemoryCell, BoolType, nil
 		}
-	}
 
-	return nil
+		return falseMemoryCell, BoolType, nil
+	default:
+		return MemoryCell(t.value), TextType, nil
+	}
 }
 
 var (
-	trueToken  = token{kind: boolKind, value: "true"}
-	falseToken = token{kind: boolKind, value: "false"}
-
-	trueMemoryCell  = literalToMemoryCell(&trueToken)
-	falseMemoryCell = literalToMemoryCell(&falseToken)
+	trueMemoryCell  = MemoryCell([]byte{1})
+	falseMemoryCell = MemoryCell(nil)
 )
 
+// FormatFloat formats a float the way PostgreSQL prints a double
+// precision value, switching to exponent notation for very large or
+// small magnitudes.
+func FormatFloat(f float64) string {
+	return formatFloat(f, 64)
+}
+
+// formatFloat formats f with the fewest digits that read back as the
+// same value at the given bit size, 32 for real values.
+func formatFloat(f float64, bitSize int) string {
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-4 || abs >= 1e15) {
+		return strconv.FormatFloat(f, 'e', -1, bitSize)
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, bitSize)
+}
+
+// FormatCell formats a cell of the given type the way PostgreSQL prints
+// it as text.
+func FormatCell(c Cell, ct ColumnType) string {
+	return memoryCellToText(c, ct)
+}
+
+// floatLiteral formats a float as a decimal literal.
+func floatLiteral(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+
+	return s
+}
+
 type table struct {
 	colums      []string
 	columnTypes []ColumnType
+	modifiers   []typeModifier
 	rows        [][]MemoryCell
+	// now is the time the statement started, which now() and
+	// current_date give throughout it
+	now time.Time
+}
+
+// modifier returns the type modifier of column i, which tables built
+// without any have none of.
+func (t *table) modifier(i int) typeModifier {
+	if i >= len(t.modifiers) {
+		return typeModifier{}
+	}
+
+	return t.modifiers[i]
 }
 
 func (t *table) evaluateLiteralCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
@@ -96,17 +163,15 @@ func (t *table) evaluateLiteralCell(rowIndex uint, exp expression) (MemoryCell,
 			}
 		}
 
-		return nil, "", 0, ErrColumnDoesNotExist
+		return nil, "", 0, newSourceError(ErrColumnDoesNotExist, lit)
 	}
 
-	columnType := IntType
-	if lit.kind == stringKind {
-		columnType = TextType
-	} else if lit.kind == boolKind {
-		columnType = BoolType
+	mc, columnType, err := literalToMemoryCell(lit)
+	if err != nil {
+		return nil, "", 0, newSourceError(err, lit)
 	}
 
-	return literalToMemoryCell(lit), "?column?", columnType, nil
+	return mc, "?column?", columnType, nil
 
 }
 
@@ -127,16 +192,41 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 		return nil, "", 0, err
 	}
 
+	switch symbol(bexp.op.value) {
+	case eqSymbol, neqSymbol, neqSymbol2, gtSymbol, gteSymbol, ltSymbol, lteSymbol:
+		l, lt, r, rt, err = coerceTemporal(bexp, l, lt, r, rt)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		l, lt, r, rt, err = coerceBytea(bexp, l, lt, r, rt)
+		if err != nil {
+			return nil, "", 0, err
+		}
+	}
+
 	switch bexp.op.kind {
 	case symbolKind:
 		switch symbol(bexp.op.value) {
 		case eqSymbol:
 			eq := l.equals(r)
-			if lt == TextType && rt == TextType && eq {
+			if isTextType(lt) && isTextType(rt) && textValue(l, lt) == textValue(r, rt) {
 				return trueMemoryCell, "?column?", BoolType, nil
 			}
 
-			if lt == IntType && rt == IntType && eq {
+			if isNumericType(lt) && isNumericType(rt) && compareNumeric(l, lt, r, rt) == 0 {
+				return trueMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareTemporal(l, lt, r, rt); ok && c == 0 {
+				return trueMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareBytea(l, lt, r, rt); ok && c == 0 {
+				return trueMemoryCell, "?column?", BoolType, nil
+			}
+
+			if lt == BoolType && rt == BoolType && l.AsBool() == r.AsBool() {
 				return trueMemoryCell, "?column?", BoolType, nil
 			}
 
@@ -147,15 +237,29 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return falseMemoryCell, "?column?", BoolType, nil
 
 		case gtSymbol:
-			if lt == IntType && rt == IntType {
-				if l.AsInt() > r.AsInt() {
+			if isNumericType(lt) && isNumericType(rt) {
+				if compareNumeric(l, lt, r, rt) > 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareTemporal(l, lt, r, rt); ok {
+				if c > 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareBytea(l, lt, r, rt); ok {
+				if c > 0 {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
 			}
 
-			if lt == TextType && rt == TextType {
-				if l.AsText() > r.AsText() {
+			if isTextType(lt) && isTextType(rt) {
+				if textValue(l, lt) > textValue(r, rt) {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
@@ -164,15 +268,29 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return nil, "", 0, ErrInvalidOperands
 
 		case gteSymbol:
-			if lt == IntType && rt == IntType {
-				if l.AsInt() >= r.AsInt() {
+			if isNumericType(lt) && isNumericType(rt) {
+				if compareNumeric(l, lt, r, rt) >= 0 {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
 			}
 
-			if lt == TextType && rt == TextType {
-				if l.AsText() >= r.AsText() {
+			if c, ok := compareTemporal(l, lt, r, rt); ok {
+				if c >= 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareBytea(l, lt, r, rt); ok {
+				if c >= 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if isTextType(lt) && isTextType(rt) {
+				if textValue(l, lt) >= textValue(r, rt) {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
@@ -181,15 +299,29 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return nil, "", 0, ErrInvalidOperands
 
 		case ltSymbol:
-			if lt == IntType && rt == IntType {
-				if l.AsInt() < r.AsInt() {
+			if isNumericType(lt) && isNumericType(rt) {
+				if compareNumeric(l, lt, r, rt) < 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareTemporal(l, lt, r, rt); ok {
+				if c < 0 {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
 			}
 
-			if lt == TextType && rt == TextType {
-				if l.AsText() < r.AsText() {
+			if c, ok := compareBytea(l, lt, r, rt); ok {
+				if c < 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if isTextType(lt) && isTextType(rt) {
+				if textValue(l, lt) < textValue(r, rt) {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
@@ -198,15 +330,29 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return nil, "", 0, ErrInvalidOperands
 
 		case lteSymbol:
-			if lt == IntType && rt == IntType {
-				if l.AsInt() <= r.AsInt() {
+			if isNumericType(lt) && isNumericType(rt) {
+				if compareNumeric(l, lt, r, rt) <= 0 {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
 			}
 
-			if lt == TextType && rt == TextType {
-				if l.AsText() <= r.AsText() {
+			if c, ok := compareTemporal(l, lt, r, rt); ok {
+				if c <= 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareBytea(l, lt, r, rt); ok {
+				if c <= 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if isTextType(lt) && isTextType(rt) {
+				if textValue(l, lt) <= textValue(r, rt) {
 					return trueMemoryCell, "?column?", BoolType, nil
 				}
 				return falseMemoryCell, "?column?", BoolType, nil
@@ -215,6 +361,41 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return nil, "", 0, ErrInvalidOperands
 
 		case neqSymbol:
+			if isNumericType(lt) && isNumericType(rt) {
+				if compareNumeric(l, lt, r, rt) != 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareTemporal(l, lt, r, rt); ok {
+				if c != 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareBytea(l, lt, r, rt); ok {
+				if c != 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if isTextType(lt) && isTextType(rt) {
+				if textValue(l, lt) != textValue(r, rt) {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if lt == BoolType && rt == BoolType {
+				if l.AsBool() != r.AsBool() {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
 			if lt != rt || !l.equals(r) {
 				return trueMemoryCell, "?column?", BoolType, nil
 			}
@@ -222,6 +403,41 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return falseMemoryCell, "?column?", BoolType, nil
 
 		case neqSymbol2:
+			if isNumericType(lt) && isNumericType(rt) {
+				if compareNumeric(l, lt, r, rt) != 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareTemporal(l, lt, r, rt); ok {
+				if c != 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if c, ok := compareBytea(l, lt, r, rt); ok {
+				if c != 0 {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if isTextType(lt) && isTextType(rt) {
+				if textValue(l, lt) != textValue(r, rt) {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if lt == BoolType && rt == BoolType {
+				if l.AsBool() != r.AsBool() {
+					return trueMemoryCell, "?column?", BoolType, nil
+				}
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
 			if lt != rt || !l.equals(r) {
 				return trueMemoryCell, "?column?", BoolType, nil
 			}
@@ -229,27 +445,40 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 			return falseMemoryCell, "?column?", BoolType, nil
 
 		case concatSymbol:
-			if lt != TextType || rt != TextType {
+			if !isTextType(lt) || !isTextType(rt) {
 				return nil, "", 0, ErrInvalidOperands
 			}
 
-			return literalToMemoryCell(&token{kind: stringKind, value: l.AsText() + r.AsText()}), "?column?", TextType, nil
+			return MemoryCell(textValue(l, lt) + textValue(r, rt)), "?column?", TextType, nil
+
+		case plusSymbol, minusSymbol:
+			if isTemporalType(lt) || isTemporalType(rt) {
+				res, ct, err := temporalArithmetic(symbol(bexp.op.value), l, lt, r, rt)
+				if err != nil {
+					return nil, "", 0, err
+				}
+
+				return res, "?column?", ct, nil
+			}
 
-		case plusSymbol:
-			if lt != IntType || rt != IntType {
+			if !isNumericType(lt) || !isNumericType(rt) {
 				return nil, "", 0, ErrInvalidOperands
 			}
 
-			iValue := int(l.AsInt() + r.AsInt())
-			return literalToMemoryCell(&token{kind: numericKind, value: strconv.Itoa(iValue)}), "?column?", IntType, nil
+			res, ct, err := arithmetic(symbol(bexp.op.value), l, lt, r, rt)
+			if err != nil {
+				return nil, "", 0, err
+			}
 
-		case minusSymbol:
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
+			return res, "?column?", ct, nil
+
+		case arrowSymbol, arrowTextSymbol, pathSymbol, pathTextSymbol, containsSymbol:
+			res, ct, err := jsonOperator(symbol(bexp.op.value), l, lt, r, rt)
+			if err != nil {
+				return nil, "", 0, err
 			}
 
-			iValue := int(l.AsInt() - r.AsInt())
-			return literalToMemoryCell(&token{kind: numericKind, value: strconv.Itoa(iValue)}), "?column?", IntType, nil
+			return res, "?column?", ct, nil
 
 		default:
 			// TODO
@@ -292,12 +521,55 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 
 }
 
+// evaluateCastCell converts the value of an expression to a type. Text
+// is read as a value of the type, as it is in a typed literal.
+func (t *table) evaluateCastCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
+	cast := exp.cast
+
+	mc, _, from, err := t.evaluateCell(rowIndex, cast.exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	to, ok := columnTypeOf(cast.datatype.value)
+	if !ok {
+		return nil, "", 0, newSourceError(ErrInvalidDatatype, &cast.datatype)
+	}
+
+	mod, err := typeModifierOf(to, cast.modifiers)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	res, err := castCell(mc, from, to, mod)
+	if errors.Is(err, ErrInvalidDatatype) {
+		// Say what could not be cast rather than just that it could not
+		if isTextType(from) {
+			err = fmt.Errorf("%w %s: %q", ErrInvalidInput, cast.datatype.value, textValue(mc, from))
+		} else {
+			err = fmt.Errorf("%w %s to %s", ErrCannotCast, columnTypeName(from, typeModifier{}), cast.datatype.value)
+		}
+	}
+
+	if err != nil {
+		return nil, "", 0, newSourceError(err, cast.exp.sourceToken())
+	}
+
+	return res, cast.datatype.value, to, nil
+}
+
 func (t *table) evaluateCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
 	switch exp.kind {
 	case literalKind:
 		return t.evaluateLiteralCell(rowIndex, exp)
 	case binaryKind:
 		return t.evaluateBinaryCell(rowIndex, exp)
+	case castKind:
+		return t.evaluateCastCell(rowIndex, exp)
+	case callKind:
+		return t.evaluateCallCell(rowIndex, exp)
+	case parameterKind:
+		return nil, "", 0, ErrUnboundParameter
 	default:
 		return nil, "", 0, ErrInvalidCell
 	}
@@ -307,40 +579,49 @@ type MemoryBackend struct {
 	tables map[string]*table
 }
 
-func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
-	t := &table{}
+// cancelCheckInterval is how many rows are read between checks for a
+// canceled context.
+const cancelCheckInterval = 1024
+
+// memoryRows scans, filters and projects the rows of a table one at a
+// time. It holds on to the rows the table had when the query started so
+// later inserts are not seen.
+type memoryRows struct {
+	ctx     context.Context
+	t       *table
+	slct    *SelectStatement
+	columns []ResultColumn
+	index   int
+	row     []Cell
+	err     error
+}
 
-	if slct.from != nil && slct.from.table != nil {
-		var ok bool
-		t, ok = mb.tables[slct.from.table.value]
-		if !ok {
-			return nil, ErrTableDoesNotExist
-		}
-	}
+func (r *memoryRows) Columns() []ResultColumn {
+	return r.columns
+}
 
-	if slct.item == nil || len(*slct.item) == 0 {
-		return &Results{}, nil
+func (r *memoryRows) Next() bool {
+	r.row = nil
+	if r.err != nil {
+		return false
 	}
 
-	results := [][]Cell{}
-	columns := []struct {
-		Type ColumnType
-		Name string
-	}{}
-
-	if slct.from == nil {
-		t = &table{}
-		t.rows = [][]MemoryCell{{}}
-	}
+	for r.index < len(r.t.rows) {
+		if r.index%cancelCheckInterval == 0 {
+			r.err = checkContext(r.ctx)
+			if r.err != nil {
+				return false
+			}
+		}
 
-	for i := range t.rows {
-		result := []Cell{}
-		isFirstRow := len(results) == 0
+		i := uint(r.index)
+		r.index++
 
-		if slct.where != nil {
-			val, _, _, err := t.evaluateCell(uint(i), *slct.where)
+		if r.slct.where != nil {
+			val, _, _, err := r.t.evaluateCell(i, *r.slct.where)
 			if err != nil {
-				return nil, err
+				r.err = err
+				return false
 			}
 
 			if !val.AsBool() {
@@ -348,53 +629,104 @@ func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
 			}
 		}
 
-		for _, col := range *slct.item {
+		row := []Cell{}
+		for _, col := range *r.slct.item {
 			if col.asteriks {
-				for j, tableCol := range t.colums {
-					value := t.rows[i][j]
-					if isFirstRow {
-						columns = append(columns, struct {
-							Type ColumnType
-							Name string
-						}{Type: t.columnTypes[j], Name: tableCol})
-					}
-					result = append(result, value)
+				for j := range r.t.colums {
+					row = append(row, r.t.rows[i][j])
 				}
 				continue
 			}
 
-			value, columnName, columnType, err := t.evaluateCell(uint(i), *col.exp)
-
+			value, _, _, err := r.t.evaluateCell(i, *col.exp)
 			if err != nil {
-				return nil, err
+				r.err = err
+				return false
 			}
 
-			if isFirstRow {
-				columns = append(columns, struct {
-					Type ColumnType
-					Name string
-				}{Type: columnType, Name: columnName})
+			row = append(row, value)
+		}
 
-			}
+		r.row = row
+		return true
+	}
 
-			result = append(result, value)
+	return false
+}
 
-		}
+func (r *memoryRows) Row() []Cell {
+	return r.row
+}
+
+func (r *memoryRows) Err() error {
+	return r.err
+}
+
+func (r *memoryRows) Close() error {
+	r.row = nil
+	r.index = len(r.t.rows)
+	return nil
+}
 
-		results = append(results, result)
+func (mb *MemoryBackend) Query(slct *SelectStatement) (RowIterator, error) {
+	return mb.QueryContext(context.Background(), slct)
+}
+
+// QueryContext starts a query whose rows stop with ErrQueryCanceled once
+// ctx is done.
+func (mb *MemoryBackend) QueryContext(ctx context.Context, slct *SelectStatement) (RowIterator, error) {
+	err := checkContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Results{
-		Columns: columns,
-		Rows:    results,
-	}, nil
+	t, err := mb.selectTable(slct)
+	if err != nil {
+		return nil, err
+	}
+
+	if slct.item == nil || len(*slct.item) == 0 {
+		return &memoryRows{ctx: ctx, t: &table{}, slct: slct, columns: []ResultColumn{}}, nil
+	}
+
+	if slct.from == nil {
+		t = &table{}
+		t.rows = [][]MemoryCell{{}}
+	}
+
+	columns, err := t.selectColumns(slct, map[uint]ColumnType{})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &table{colums: t.colums, columnTypes: t.columnTypes, modifiers: t.modifiers, rows: t.rows, now: time.Now()}
+	return &memoryRows{ctx: ctx, t: snapshot, slct: slct, columns: columns}, nil
+}
+
+// Select runs a query and collects every row.
+func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
+	rows, err := mb.Query(slct)
+	if err != nil {
+		return nil, err
+	}
+
+	return Collect(rows)
+}
+
+func (mb *MemoryBackend) InsertContext(ctx context.Context, inst *InsertStatement) error {
+	err := checkContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return mb.Insert(inst)
 }
 
 func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
 	t, ok := mb.tables[inst.table.value]
 
 	if !ok {
-		return ErrTableDoesNotExist
+		return newSourceError(ErrTableDoesNotExist, &inst.table)
 	}
 
 	if inst.values == nil {
@@ -407,48 +739,60 @@ func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
 		return ErrMissingValues
 	}
 
+	emptyTable := &table{now: time.Now()}
 	for _, value := range *inst.values {
-		if value.kind != literalKind {
-			fmt.Println("Skipping non-literal")
-			continue
+		cell, _, ct, err := emptyTable.evaluateCell(0, *value)
+		if err != nil {
+			return err
 		}
 
-		emptyTable := &table{}
-		value, _, _, err := emptyTable.evaluateCell(0, *value)
+		// Values are stored as the type of their column
+		cell, err = t.storeCell(len(row), cell, ct)
 		if err != nil {
-			return err
+			return newSourceError(err, value.sourceToken())
 		}
-		row = append(row, value)
+
+		row = append(row, cell)
 	}
 
 	t.rows = append(t.rows, row)
 	return nil
 }
 
+func (mb *MemoryBackend) CreateTableContext(ctx context.Context, crt *CreateTableStatement) error {
+	err := checkContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return mb.CreateTable(crt)
+}
+
 func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
 	t := table{}
-	mb.tables[crt.name.value] = &t
-
 	if crt.cols == nil {
+		mb.tables[crt.name.value] = &t
 		return nil
 	}
 
+	// The table is only stored once every column is valid
 	for _, col := range *crt.cols {
-		t.colums = append(t.colums, col.name.value)
-
-		var dt ColumnType
-		switch col.datatype.value {
-		case "int":
-			dt = IntType
-		case "text":
-			dt = TextType
-		default:
+		dt, ok := columnTypeOf(col.datatype.value)
+		if !ok {
 			return ErrInvalidDatatype
 		}
 
+		mod, err := typeModifierOf(dt, col.modifiers)
+		if err != nil {
+			return err
+		}
+
+		t.colums = append(t.colums, col.name.value)
 		t.columnTypes = append(t.columnTypes, dt)
+		t.modifiers = append(t.modifiers, mod)
 	}
 
+	mb.tables[crt.name.value] = &t
 	return nil
 }
 
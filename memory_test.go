@@ -0,0 +1,494 @@
+package gosql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	mb := newDumpFixture(t)
+
+	ast, err := Parse("SELECT id, name FROM users WHERE id > 0; INSERT INTO users VALUES (4, 'Lou', true); SELECT id FROM users WHERE name > 1;")
+	assert.Nil(t, err)
+
+	rows, err := mb.Query(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, []ResultColumn{{IntType, "id"}, {TextType, "name"}}, rows.Columns())
+
+	assert.True(t, rows.Next())
+	assert.Equal(t, int32(1), rows.Row()[0].AsInt())
+
+	// Rows inserted after the query started are not seen
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+
+	results, err := Collect(rows)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results.Rows))
+	assert.Equal(t, "", results.Rows[0][1].AsText())
+	assert.False(t, rows.Next())
+
+	// Errors surface when the row is reached
+	rows, err = mb.Query(ast.Statements[2].SelectStatement)
+	assert.Nil(t, err)
+	assert.False(t, rows.Next())
+	assert.Equal(t, ErrInvalidOperands, rows.Err())
+
+	_, err = mb.Select(ast.Statements[2].SelectStatement)
+	assert.Equal(t, ErrInvalidOperands, err)
+
+	// Select stays on the interface for backends that collect rows
+	var backend Backend = mb
+	results, err = backend.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results.Rows))
+}
+
+func TestQuotedIdentifiers(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE "MyTable" ("Id" INT, "first name" TEXT); CREATE TABLE mytable (naïve INT);
+INSERT INTO "MyTable" VALUES (1, 'Ada'); INSERT INTO MyTable VALUES (2);
+SELECT "Id", "first name" FROM "MyTable"; SELECT NAÏVE FROM "mytable"; SELECT id FROM "MyTable";`)
+	assert.Nil(t, err)
+
+	for _, stmt := range ast.Statements[:2] {
+		assert.Nil(t, mb.CreateTable(stmt.CreateTableStatement))
+	}
+	for _, stmt := range ast.Statements[2:4] {
+		assert.Nil(t, mb.Insert(stmt.InsertStatement))
+	}
+
+	results, err := mb.Select(ast.Statements[4].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, []ResultColumn{{IntType, "Id"}, {TextType, "first name"}}, results.Columns)
+	assert.Equal(t, int32(1), results.Rows[0][0].AsInt())
+	assert.Equal(t, "Ada", results.Rows[0][1].AsText())
+
+	results, err = mb.Select(ast.Statements[5].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, []ResultColumn{{IntType, "naïve"}}, results.Columns)
+	assert.Equal(t, int32(2), results.Rows[0][0].AsInt())
+
+	_, err = mb.Select(ast.Statements[6].SelectStatement)
+	assert.ErrorIs(t, err, ErrColumnDoesNotExist)
+}
+
+func TestNumericLiterals(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse("SELECT 1.5, 2e3, 0x1F, -0x10, 1 + 0.5, 3 - 1, 1 = 1.0, 2 > 1.5, 1e2 <> 100;")
+	assert.Nil(t, err)
+
+	results, err := mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+
+	types := []ColumnType{NumericType, NumericType, IntType, IntType, NumericType, IntType, BoolType, BoolType, BoolType}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+	}
+
+	row := results.Rows[0]
+	assert.Equal(t, "1.5", row[0].AsText())
+	assert.Equal(t, "2000", row[1].AsText())
+	assert.Equal(t, int32(31), row[2].AsInt())
+	assert.Equal(t, int32(-16), row[3].AsInt())
+	assert.Equal(t, "1.5", row[4].AsText())
+	assert.Equal(t, int32(2), row[5].AsInt())
+	assert.True(t, row[6].AsBool())
+	assert.True(t, row[7].AsBool())
+	assert.False(t, row[8].AsBool())
+
+	for _, source := range []string{
+		"SELECT 0x8000000000000000;",
+		"SELECT 2147483647 + 1;",
+		"SELECT 9223372036854775807 + 1;",
+		"SELECT 1e200000;",
+	} {
+		ast, err = Parse(source)
+		assert.Nil(t, err)
+
+		_, err = mb.Select(ast.Statements[0].SelectStatement)
+		assert.ErrorIs(t, err, ErrNumericOutOfRange, source)
+	}
+
+	ast, err = Parse("CREATE TABLE n (i INT, f FLOAT); INSERT INTO n VALUES (2.5, 3); INSERT INTO n VALUES (3e9, 1); SELECT i, f FROM n;")
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+	assert.ErrorIs(t, mb.Insert(ast.Statements[2].InsertStatement), ErrNumericOutOfRange)
+
+	results, err = mb.Select(ast.Statements[3].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), results.Rows[0][0].AsInt())
+	assert.Equal(t, 3.0, results.Rows[0][1].AsFloat())
+
+	assert.Equal(t, "1.5", FormatFloat(1.5))
+	assert.Equal(t, "10000000000", FormatFloat(1e10))
+	assert.Equal(t, "1e+15", FormatFloat(1e15))
+	assert.Equal(t, "1e-05", FormatFloat(0.00001))
+	assert.Equal(t, "1e+10", floatLiteral(1e10))
+	assert.Equal(t, "3.0", floatLiteral(3))
+}
+
+func TestColumnTypes(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE accounts (id BIGINT, age SMALLINT, score REAL, ratio DOUBLE PRECISION, balance NUMERIC(10, 2), code CHAR(3), name VARCHAR(5), note CHARACTER VARYING);
+INSERT INTO accounts VALUES (9000000000, 42, 1.5, 0.25, 19.999, 'ab', 'Ada  ', 'x');
+SELECT id + 1, age + 1, score + 1, balance + 1, balance - 0.005, code, code = 'ab', code || name, id > 2147483647, balance > 19.99 FROM accounts;`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+
+	results, err := mb.Select(ast.Statements[2].SelectStatement)
+	assert.Nil(t, err)
+
+	types := []ColumnType{BigIntType, IntType, RealType, NumericType, NumericType, CharType, BoolType, TextType, BoolType, BoolType}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+	}
+
+	row := results.Rows[0]
+	assert.Equal(t, int64(9000000001), row[0].AsBigInt())
+	assert.Equal(t, int32(43), row[1].AsInt())
+	assert.Equal(t, 2.5, row[2].AsFloat())
+	assert.Equal(t, "21.00", row[3].AsText())
+	assert.Equal(t, "19.995", row[4].AsText())
+	assert.Equal(t, "ab ", row[5].AsText())
+	assert.True(t, row[6].AsBool())
+	assert.Equal(t, "abAda  ", row[7].AsText())
+	assert.True(t, row[8].AsBool())
+	assert.True(t, row[9].AsBool())
+
+	// Modifiers still apply while a query over the table is running
+	ast, err = Parse(`SELECT balance, name FROM accounts;
+INSERT INTO accounts VALUES (1, 1, 0, 0, 1.005, '', 'Lou', '');
+INSERT INTO accounts VALUES (1, 1, 0, 0, 0, '', 'Adalbert', '');`)
+	assert.Nil(t, err)
+
+	rows, err := mb.Query(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+	assert.ErrorIs(t, mb.Insert(ast.Statements[2].InsertStatement), ErrValueTooLong)
+	assert.Equal(t, "20.00", rows.Row()[0].AsText())
+	assert.False(t, rows.Next())
+	assert.Nil(t, rows.Err())
+
+	results, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results.Rows))
+	assert.Equal(t, "1.01", results.Rows[1][0].AsText())
+	assert.Equal(t, "Lou", results.Rows[1][1].AsText())
+
+	for source, want := range map[string]error{
+		"INSERT INTO accounts VALUES (1, 32768, 0, 0, 0, '', '', '');":     ErrNumericOutOfRange,
+		"INSERT INTO accounts VALUES (1, 1, 1e39, 0, 0, '', '', '');":      ErrNumericOutOfRange,
+		"INSERT INTO accounts VALUES (1, 1, 0, 0, 123456789, '', '', '');": ErrNumericOutOfRange,
+		"INSERT INTO accounts VALUES (1, 1, 0, 0, 0, 'abcd', '', '');":     ErrValueTooLong,
+		"INSERT INTO accounts VALUES (1, 1, 0, 0, 0, '', 'Adalbert', '');": ErrValueTooLong,
+		"INSERT INTO accounts VALUES (1, 1, 0, 0, 0, '', '', true);":       ErrInvalidDatatype,
+		"SELECT id + 9223372036854775807 FROM accounts;":                   ErrNumericOutOfRange,
+		"CREATE TABLE bad (name VARCHAR(0));":                              ErrInvalidModifier,
+		"CREATE TABLE bad (amount NUMERIC(2, 3));":                         ErrInvalidModifier,
+		"CREATE TABLE bad (amount NUMERIC(1001));":                         ErrInvalidModifier,
+		"CREATE TABLE bad (id INT(4));":                                    ErrInvalidModifier,
+		"CREATE TABLE bad (name VARCHAR(2, 1));":                           ErrInvalidModifier,
+	} {
+		ast, err := Parse(source)
+		assert.Nil(t, err, source)
+
+		stmt := ast.Statements[0]
+		switch stmt.Kind {
+		case InsertKind:
+			err = mb.Insert(stmt.InsertStatement)
+		case CreateTableKind:
+			err = mb.CreateTable(stmt.CreateTableStatement)
+		default:
+			_, err = mb.Select(stmt.SelectStatement)
+		}
+		assert.ErrorIs(t, err, want, source)
+	}
+
+	// A failed CREATE TABLE leaves no table behind
+	ast, err = Parse("CREATE TABLE half (a INT, b VARCHAR(0)); INSERT INTO half VALUES (1, 'x'); SELECT * FROM half;")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, mb.CreateTable(ast.Statements[0].CreateTableStatement), ErrInvalidModifier)
+	assert.ErrorIs(t, mb.Insert(ast.Statements[1].InsertStatement), ErrTableDoesNotExist)
+	_, err = mb.Select(ast.Statements[2].SelectStatement)
+	assert.ErrorIs(t, err, ErrTableDoesNotExist)
+
+	_, err = Parse("CREATE TABLE bad (ratio DOUBLE);")
+	assert.NotNil(t, err)
+}
+
+func TestDateTime(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE events (day DATE, at TIME, created TIMESTAMP, seen TIMESTAMP WITH TIME ZONE, took INTERVAL);
+INSERT INTO events VALUES (DATE '2026-10-18', '13:45:30.5', TIMESTAMP '2026-01-31 08:00:00', '2026-10-18 12:00:00+02', INTERVAL '1 day 2 hours');
+SELECT day + 1, day - DATE '2026-01-01', created + INTERVAL '1 month', seen - created, created + took, at + INTERVAL '12 hours', seen > created, day = '2026-10-18', took < INTERVAL '1 day' FROM events;
+SELECT extract(year FROM day), extract(second FROM at), date_trunc('month', seen), date_part('dow', day), extract(epoch FROM took), current_date, now() FROM events;`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+
+	results, err := mb.Select(ast.Statements[2].SelectStatement)
+	assert.Nil(t, err)
+
+	types := []ColumnType{DateType, IntType, TimestampType, IntervalType, TimestampType, TimeType, BoolType, BoolType, BoolType}
+	values := []string{"2026-10-19", "290", "2026-02-28 08:00:00", "260 days 02:00:00", "2026-02-01 10:00:00", "01:45:30.5", "t", "t", "f"}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+		assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+	}
+
+	results, err = mb.Select(ast.Statements[3].SelectStatement)
+	assert.Nil(t, err)
+
+	types = []ColumnType{NumericType, NumericType, TimestampTzType, FloatType, NumericType, DateType, TimestampTzType}
+	values = []string{"2026", "30.500000", "2026-10-01 00:00:00+00", "0", "93600.000000"}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+		if i < len(values) {
+			assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+		}
+	}
+
+	assert.Equal(t, "extract", results.Columns[0].Name)
+	assert.WithinDuration(t, time.Now(), results.Rows[0][6].AsTime(), time.Minute)
+
+	for source, want := range map[string]error{
+		"INSERT INTO events VALUES ('2026-02-30', '', '', '', '');":           ErrInvalidDatetime,
+		"INSERT INTO events VALUES (DATE '2026-10-18', '25:00', '', '', '');": ErrInvalidDatetime,
+		"SELECT date_trunc('fortnight', created) FROM events;":                ErrInvalidUnit,
+		"SELECT extract(hour FROM day) FROM events;":                          ErrInvalidUnit,
+		"SELECT tomorrow() FROM events;":                                      ErrFunctionDoesNotExist,
+		"SELECT created + day FROM events;":                                   ErrInvalidOperands,
+		"CREATE TABLE bad (at TIME WITH TIME ZONE);":                          ErrInvalidDatatype,
+	} {
+		ast, err := Parse(source)
+		assert.Nil(t, err, source)
+
+		stmt := ast.Statements[0]
+		switch stmt.Kind {
+		case InsertKind:
+			err = mb.Insert(stmt.InsertStatement)
+		case CreateTableKind:
+			err = mb.CreateTable(stmt.CreateTableStatement)
+		default:
+			_, err = mb.Select(stmt.SelectStatement)
+		}
+		assert.ErrorIs(t, err, want, source)
+	}
+
+	// Values can be any expression, not only literals
+	ast, err = Parse(`INSERT INTO events VALUES (DATE '2026-10-18' + 1, TIME '10:00' + INTERVAL '1 hour', TIMESTAMP '2026-01-01' + INTERVAL '1 day', now(), INTERVAL '1 day' + INTERVAL '1 hour');`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.Insert(ast.Statements[0].InsertStatement))
+
+	row := mb.tables["events"].rows[1]
+	types = []ColumnType{DateType, TimeType, TimestampType}
+	values = []string{"2026-10-19", "11:00:00", "2026-01-02 00:00:00"}
+	for i, ct := range types {
+		assert.Equal(t, values[i], FormatCell(row[i], ct), i)
+	}
+	assert.Equal(t, "1 day 01:00:00", FormatCell(row[4], IntervalType))
+
+	// Years before 1 are BC, and print so that they read back the same
+	ast, err = Parse(`SELECT DATE '0001-01-01' - 1, DATE '0044-03-15 BC', '0001-02-29 bc'::date, TIMESTAMP '0001-12-31 23:59:59.5 BC', '0010-06-01 12:00:00+02 BC'::timestamptz;`)
+	assert.Nil(t, err)
+
+	results, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+
+	values = []string{"0001-12-31 BC", "0044-03-15 BC", "0001-02-29 BC", "0001-12-31 23:59:59.5 BC", "0010-06-01 10:00:00+00 BC"}
+	for i, col := range results.Columns {
+		s := FormatCell(results.Rows[0][i], col.Type)
+		assert.Equal(t, values[i], s, i)
+
+		cell, err := parseTemporal(s, col.Type)
+		assert.Nil(t, err, s)
+		assert.Equal(t, results.Rows[0][i], cell, s)
+	}
+
+	for _, s := range []string{"0000-01-01", "0004-02-29 BC", "10:00 BC"} {
+		_, err = parseTemporal(s, DateType)
+		assert.ErrorIs(t, err, ErrInvalidDatetime, s)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE docs (raw JSON, doc JSONB);
+INSERT INTO docs VALUES ('{"b": [1, 2.50, {"c": "x"}],  "a": 1}', '{"b": [1, 2.50, {"c": "x"}],  "a": 1, "a": true}');
+SELECT raw, doc, doc->'b', doc->'b'->2->>'c', raw->'b'->-1, doc#>'{b,2}', doc#>>'{b,0}', doc->>'missing', doc->'missing', doc @> '{"b": [{"c": "x"}]}', doc @> '{"a": false}' FROM docs;
+SELECT json_typeof(raw->'b'), jsonb_array_length(doc->'b'), jsonb_extract_path_text(doc, 'b', '1'), json_extract_path(raw, 'a'), 1 + 2 = 3 AND 4 - 2 - 1 = 1 FROM docs;`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+
+	results, err := mb.Select(ast.Statements[2].SelectStatement)
+	assert.Nil(t, err)
+
+	types := []ColumnType{JsonType, JsonbType, JsonbType, TextType, JsonType, JsonbType, TextType, TextType, JsonbType, BoolType, BoolType}
+	values := []string{`{"b": [1, 2.50, {"c": "x"}],  "a": 1}`, `{"a": true, "b": [1, 2.50, {"c": "x"}]}`, `[1, 2.50, {"c": "x"}]`, "x", `{"c": "x"}`, `{"c": "x"}`, "1", "", "null", "t", "f"}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+		assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+	}
+
+	results, err = mb.Select(ast.Statements[3].SelectStatement)
+	assert.Nil(t, err)
+
+	types = []ColumnType{TextType, IntType, TextType, JsonType, BoolType}
+	values = []string{"array", "3", "2.50", "1", "t"}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+		assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+	}
+
+	// json needs a cast to jsonb before @>
+	ast, err = Parse(`SELECT raw::jsonb @> '{"a": 1}', doc @> raw::jsonb FROM docs;`)
+	assert.Nil(t, err)
+
+	results, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.True(t, results.Rows[0][0].AsBool())
+	assert.False(t, results.Rows[0][1].AsBool())
+
+	for source, want := range map[string]error{
+		"INSERT INTO docs VALUES ('{\"a\": }', '{}');": ErrInvalidJSONData,
+		"INSERT INTO docs VALUES ('{}', '[1, 2] 3');":  ErrInvalidJSONData,
+		"SELECT jsonb_array_length(doc) FROM docs;":    ErrNotJSONArray,
+		"SELECT doc->true FROM docs;":                  ErrInvalidOperands,
+		"SELECT raw @> '{}' FROM docs;":                ErrInvalidOperands,
+		"SELECT doc @> raw FROM docs;":                 ErrInvalidOperands,
+		"SELECT '{}' @> doc FROM docs;":                ErrInvalidOperands,
+		"SELECT jsonb_typeof(doc, 'a') FROM docs;":     ErrFunctionDoesNotExist,
+	} {
+		ast, err := Parse(source)
+		assert.Nil(t, err, source)
+
+		stmt := ast.Statements[0]
+		switch stmt.Kind {
+		case InsertKind:
+			err = mb.Insert(stmt.InsertStatement)
+		default:
+			_, err = mb.Select(stmt.SelectStatement)
+		}
+		assert.ErrorIs(t, err, want, source)
+	}
+}
+
+func TestBytea(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE blobs (data BYTEA, name TEXT);
+INSERT INTO blobs VALUES ('\x00ff5C41', 'héllo');
+INSERT INTO blobs VALUES ('a\\b\101', 'x');
+SELECT data, length(data), length(name), substring(data, 2, 2), substring(name FROM 2 FOR 3), substring(name, 0, 3), encode(data, 'base64'), encode(data, 'escape'), decode('41 42', 'hex'), data > '\x00', data = BYTEA '\x00FF5c41' FROM blobs;
+SELECT data FROM blobs WHERE data = 'a\\bA';`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[2].InsertStatement))
+
+	results, err := mb.Select(ast.Statements[3].SelectStatement)
+	assert.Nil(t, err)
+
+	types := []ColumnType{ByteaType, IntType, IntType, ByteaType, TextType, TextType, TextType, TextType, ByteaType, BoolType, BoolType}
+	values := []string{`\x00ff5c41`, "4", "5", `\xff5c`, "éll", "hé", "AP9cQQ==", `\000\377\\A`, `\x4142`, "t", "t"}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+		assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+	}
+
+	results, err = mb.Select(ast.Statements[4].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results.Rows))
+	assert.Equal(t, `\x615c6241`, FormatCell(results.Rows[0][0], ByteaType))
+
+	for source, want := range map[string]error{
+		`INSERT INTO blobs VALUES ('\x0', '');`:     ErrInvalidBytea,
+		`INSERT INTO blobs VALUES ('\q', '');`:      ErrInvalidBytea,
+		`SELECT encode(data, 'base32') FROM blobs;`: ErrInvalidEncoding,
+		`SELECT decode('zz', 'hex') FROM blobs;`:    ErrInvalidBytea,
+		`SELECT substring(data, 1, -1) FROM blobs;`: ErrInvalidOperands,
+		`SELECT length(data, 1) FROM blobs;`:        ErrFunctionDoesNotExist,
+	} {
+		ast, err := Parse(source)
+		assert.Nil(t, err, source)
+
+		stmt := ast.Statements[0]
+		switch stmt.Kind {
+		case InsertKind:
+			err = mb.Insert(stmt.InsertStatement)
+		default:
+			_, err = mb.Select(stmt.SelectStatement)
+		}
+		assert.ErrorIs(t, err, want, source)
+	}
+}
+
+func TestCast(t *testing.T) {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(`CREATE TABLE users (id INT, name TEXT, active BOOLEAN, score REAL);
+INSERT INTO users VALUES (23, '42', true, 2.5);
+SELECT id = '23'::int, name::int + 1, CAST(id AS TEXT) || '!', active::int, 0::boolean, 'yes'::boolean, score::int, '3.14159'::numeric(4, 2), id::varchar(1), active::text, DATE '2026-10-19'::text, 1 + '2'::int, 'abcd'::varchar(2) FROM users;`)
+	assert.Nil(t, err)
+	assert.Nil(t, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+	assert.Nil(t, mb.Insert(ast.Statements[1].InsertStatement))
+
+	results, err := mb.Select(ast.Statements[2].SelectStatement)
+	assert.Nil(t, err)
+
+	types := []ColumnType{BoolType, IntType, TextType, IntType, BoolType, BoolType, IntType, NumericType, VarcharType, TextType, TextType, IntType, VarcharType}
+	values := []string{"t", "43", "23!", "1", "f", "t", "2", "3.14", "2", "true", "2026-10-19", "3", "ab"}
+	for i, col := range results.Columns {
+		assert.Equal(t, types[i], col.Type, i)
+		assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+	}
+
+	assert.Equal(t, "int", results.Columns[3].Name)
+
+	ast, err = Parse(`SELECT active = true, active = false, true = true, 't'::boolean = true, active <> false, active != true, 'no'::boolean <> active FROM users;
+SELECT id FROM users WHERE active = 'yes'::boolean;`)
+	assert.Nil(t, err)
+
+	results, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+
+	values = []string{"t", "f", "t", "t", "t", "f", "t"}
+	for i, col := range results.Columns {
+		assert.Equal(t, values[i], FormatCell(results.Rows[0][i], col.Type), i)
+	}
+
+	results, err = mb.Select(ast.Statements[1].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results.Rows))
+
+	for source, want := range map[string]error{
+		"SELECT 'abc'::int FROM users;":            ErrInvalidInput,
+		"SELECT CAST(name AS boolean) FROM users;": ErrInvalidInput,
+		"SELECT active::date FROM users;":          ErrCannotCast,
+		"SELECT '2026-13-01'::date FROM users;":    ErrInvalidDatetime,
+		"SELECT '99999'::smallint FROM users;":     ErrInvalidInput,
+		"SELECT 99999::smallint FROM users;":       ErrNumericOutOfRange,
+	} {
+		ast, err := Parse(source)
+		assert.Nil(t, err, source)
+
+		_, err = mb.Select(ast.Statements[0].SelectStatement)
+		assert.ErrorIs(t, err, want, source)
+	}
+
+	ast, err = Parse("SELECT 'abc'::int FROM users;")
+	assert.Nil(t, err)
+	_, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.EqualError(t, err, `Invalid input syntax for type int: "abc"`)
+}
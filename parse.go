@@ -1,8 +1,8 @@
 package gosql
 
 import (
-	"errors"
-	"fmt"
+	"sort"
+	"strconv"
 )
 
 func tokenFromKeyword(k keyword) token {
@@ -19,42 +19,111 @@ func tokenFromSymbol(s symbol) token {
 	}
 }
 
-func helpMessage(tokens []*token, cursor uint, msg string) {
-	var c *token
-	if cursor+1 < uint(len(tokens)) {
-		c = tokens[cursor]
+// parser holds the tokens of a source being parsed and the syntax error
+// found furthest into them.
+type parser struct {
+	tokens []*token
+	// eof is the location just past the end of the source
+	eof location
+	err *ParseError
+	// placeholders holds the number of each ? by its cursor, and 0
+	// for the first placeholder of a statement that mixes ? and $n
+	placeholders map[uint]uint
+}
+
+func newParser(source string, tokens []*token) *parser {
+	eof := location{}
+	if len(tokens) > 0 {
+		eof = tokens[len(tokens)-1].end
+	}
+
+	p := &parser{tokens: tokens, eof: eof.advance(source, uint(len(source)))}
+	p.numberPlaceholders()
+	return p
+}
+
+// numberPlaceholders numbers the ? placeholders of each statement in
+// one pass, since the parser may try a token more than once.
+func (p *parser) numberPlaceholders() {
+	semicolonToken := tokenFromSymbol(semicolonSymbol)
+
+	var count uint
+	var style byte
+	for i, t := range p.tokens {
+		if t.equals(&semicolonToken) {
+			count, style = 0, 0
+			continue
+		}
+
+		if t.kind != placeholderKind {
+			continue
+		}
+
+		if p.placeholders == nil {
+			p.placeholders = map[uint]uint{}
+		}
+
+		if style != 0 && style != t.value[0] {
+			p.placeholders[uint(i)] = 0
+			continue
+		}
+		style = t.value[0]
+
+		if style == '?' {
+			count++
+			p.placeholders[uint(i)] = count
+		}
+	}
+}
+
+// helpMessage records a syntax error at the token at cursor. Alternatives
+// are often tried in turn, so only the error that got furthest is kept.
+func (p *parser) helpMessage(cursor uint, msg string, expected ...string) {
+	err := &ParseError{Msg: msg, Expected: expected}
+	if cursor < uint(len(p.tokens)) {
+		t := p.tokens[cursor]
+		err.Token = t.value
+		err.Line = t.loc.line
+		err.Column = t.loc.col
+		err.Offset = t.loc.offset
+		err.End = t.end.offset
 	} else {
-		c = tokens[cursor]
+		err.Line = p.eof.line
+		err.Column = p.eof.col
+		err.Offset = p.eof.offset
+		err.End = p.eof.offset
 	}
 
-	fmt.Printf("[%d,%d]: %s, got: %s\n", c.loc.line, c.loc.col, msg, c.value)
+	if p.err == nil || err.Offset > p.err.Offset {
+		p.err = err
+	}
 }
 
-func parseToken(tokens []*token, initialCursor uint, t token) (*token, uint, bool) {
+func (p *parser) parseToken(initialCursor uint, t token) (*token, uint, bool) {
 	cursor := initialCursor
 
-	if cursor >= uint(len(tokens)) {
+	if cursor >= uint(len(p.tokens)) {
 		return nil, initialCursor, false
 	}
 
-	p := tokens[cursor];
+	current := p.tokens[cursor]
 
-	if t.equals(p) {
-		return p, cursor + 1, true
+	if t.equals(current) {
+		return current, cursor + 1, true
 	}
 
 	return nil, initialCursor, false
 
 }
 
-func parseTokenKind(tokens []*token, initialCursor uint, kind tokenKind) (*token, uint, bool) {
+func (p *parser) parseTokenKind(initialCursor uint, kind tokenKind) (*token, uint, bool) {
 	cursor := initialCursor
 
-	if cursor >= uint(len(tokens)) {
+	if cursor >= uint(len(p.tokens)) {
 		return nil, initialCursor, false
 	}
 
-	current := tokens[cursor]
+	current := p.tokens[cursor]
 	if current.kind == kind {
 		return current, cursor + 1, true
 	}
@@ -62,12 +131,58 @@ func parseTokenKind(tokens []*token, initialCursor uint, kind tokenKind) (*token
 	return nil, initialCursor, false
 }
 
-func parseLiteralExpression(tokens []*token, initialCursor uint) (*expression, uint, bool) {
+// parseIdentifier also accepts unreserved keywords, returning them as
+// identifiers.
+func (p *parser) parseIdentifier(initialCursor uint) (*token, uint, bool) {
+	if initialCursor >= uint(len(p.tokens)) {
+		return nil, initialCursor, false
+	}
+
+	current := p.tokens[initialCursor]
+	switch {
+	case current.kind == identifierKind:
+		return current, initialCursor + 1, true
+	case current.kind == keywordKind && !keyword(current.value).reserved():
+		id := *current
+		id.kind = identifierKind
+		return &id, initialCursor + 1, true
+	}
+
+	return nil, initialCursor, false
+}
+
+func (p *parser) parseLiteralExpression(initialCursor uint) (*expression, uint, bool) {
 	cursor := initialCursor
 
-	kinds := []tokenKind{identifierKind, numericKind, stringKind, boolKind}
+	// Fold a leading minus into the numeric literal that follows it
+	_, newCursor, ok := p.parseToken(cursor, tokenFromSymbol(minusSymbol))
+	if ok {
+		t, newCursor, ok := p.parseTokenKind(newCursor, numericKind)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		return &expression{
+			literal: &token{
+				value: "-" + t.value,
+				kind:  numericKind,
+				loc:   p.tokens[cursor].loc,
+				end:   t.end,
+			},
+			kind: literalKind,
+		}, newCursor, true
+	}
+
+	if t, newCursor, ok := p.parseIdentifier(cursor); ok {
+		return &expression{
+			literal: t,
+			kind:    literalKind,
+		}, newCursor, true
+	}
+
+	kinds := []tokenKind{numericKind, stringKind, boolKind}
 	for _, kind := range kinds {
-		t, newCursor, ok := parseTokenKind(tokens, cursor, kind)
+		t, newCursor, ok := p.parseTokenKind(cursor, kind)
 		if ok {
 			return &expression{
 				literal: t,
@@ -79,48 +194,211 @@ func parseLiteralExpression(tokens []*token, initialCursor uint) (*expression, u
 	return nil, initialCursor, false
 }
 
-func parseExpression(tokens []*token, initialCursor uint, delimiters []token) (*expression, uint, bool) {
-	cursor := initialCursor
-
-	var exp *expression
-	_, newCursor, ok := parseToken(tokens, cursor, tokenFromSymbol(leftParenSymbol))
-
-	if ok {
-		cursor = newCursor
-		rightParenToken := tokenFromSymbol(rightParenSymbol)
+// parseTypedLiteral parses a string literal prefixed with the type it
+// has, as in DATE '2026-10-18' or TIMESTAMP WITH TIME ZONE '...'.
+func (p *parser) parseTypedLiteral(initialCursor uint) (*expression, uint, bool) {
+	ty, cursor, ok := p.parseTokenKind(initialCursor, keywordKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
 
-		exp, cursor, ok = parseExpression(tokens, cursor, append(delimiters, rightParenToken))
+	if k := keyword(ty.value); k == timestampKeyword || k == timeKeyword {
+		ty, cursor, ok = p.parseTimeZone(ty, cursor)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected expression after opening paren")
 			return nil, initialCursor, false
 		}
+	}
 
-		_, cursor, ok = parseToken(tokens, cursor, rightParenToken)
+	if _, ok := columnTypeOf(ty.value); !ok {
+		return nil, initialCursor, false
+	}
 
-		if !ok {
-			helpMessage(tokens, cursor, "Expected closing paren")
-			return nil, initialCursor, false
+	lit, cursor, ok := p.parseTokenKind(cursor, stringKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	return &expression{
+		cast: &castExpression{
+			exp:      expression{literal: lit, kind: literalKind},
+			datatype: *ty,
+		},
+		kind: castKind,
+	}, cursor, true
+}
+
+// parseCallExpression parses a function call such as now() or
+// date_trunc('day', ts), and the functions such as current_date that
+// are written without parentheses.
+func (p *parser) parseCallExpression(initialCursor uint) (*expression, uint, bool) {
+	for _, k := range []keyword{currentDateKeyword, currentTimestampKeyword, localtimestampKeyword} {
+		t, newCursor, ok := p.parseToken(initialCursor, tokenFromKeyword(k))
+		if ok {
+			return &expression{
+				call: &callExpression{name: *t},
+				kind: callKind,
+			}, newCursor, true
 		}
+	}
 
-	} else {
-		exp, cursor, ok = parseLiteralExpression(tokens, cursor)
+	name, cursor, ok := p.parseTokenKind(initialCursor, identifierKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	rightParenToken := tokenFromSymbol(rightParenSymbol)
+
+	var args []expression
+	switch name.value {
+	case "extract":
+		args, cursor, ok = p.parseExtractArguments(cursor)
+	case "substring":
+		args, cursor, ok = p.parseSubstringArguments(cursor)
+	default:
+		args, cursor, ok = p.parseArguments(cursor)
+	}
+
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(cursor, rightParenToken)
+	if !ok {
+		p.helpMessage(cursor, "Expected closing paren", ")")
+		return nil, initialCursor, false
+	}
+
+	return &expression{
+		call: &callExpression{name: *name, args: args},
+		kind: callKind,
+	}, cursor, true
+}
+
+// parseArguments parses the comma separated arguments of a call.
+func (p *parser) parseArguments(initialCursor uint) ([]expression, uint, bool) {
+	exps, cursor, ok := p.parseExpressions(initialCursor, []token{tokenFromSymbol(rightParenSymbol)})
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	args := []expression{}
+	for _, exp := range *exps {
+		args = append(args, *exp)
+	}
+
+	return args, cursor, true
+}
+
+// parseSubstringArguments parses the string FROM start FOR count that
+// substring takes as well as the usual arguments. FOR count may be left
+// out.
+func (p *parser) parseSubstringArguments(initialCursor uint) ([]expression, uint, bool) {
+	rightParenToken := tokenFromSymbol(rightParenSymbol)
+	fromToken := tokenFromKeyword(fromKeyword)
+	forToken := tokenFromKeyword(forKeyword)
+
+	source, cursor, ok := p.parseExpression(initialCursor, []token{fromToken, tokenFromSymbol(commaSymbol), rightParenToken})
+	if !ok {
+		return p.parseArguments(initialCursor)
+	}
+
+	_, cursor, ok = p.parseToken(cursor, fromToken)
+	if !ok {
+		return p.parseArguments(initialCursor)
+	}
+
+	start, cursor, ok := p.parseExpression(cursor, []token{forToken, rightParenToken})
+	if !ok {
+		p.helpMessage(cursor, "Expected expression")
+		return nil, initialCursor, false
+	}
+
+	args := []expression{*source, *start}
+
+	_, newCursor, ok := p.parseToken(cursor, forToken)
+	if !ok {
+		return args, cursor, true
+	}
+
+	count, cursor, ok := p.parseExpression(newCursor, []token{rightParenToken})
+	if !ok {
+		p.helpMessage(newCursor, "Expected expression")
+		return nil, initialCursor, false
+	}
+
+	return append(args, *count), cursor, true
+}
+
+// parseExtractArguments parses the field FROM source of extract. The
+// field becomes a string argument like the one date_part takes.
+func (p *parser) parseExtractArguments(initialCursor uint) ([]expression, uint, bool) {
+	field, cursor, ok := p.parseIdentifier(initialCursor)
+	if !ok {
+		field, cursor, ok = p.parseTokenKind(initialCursor, stringKind)
 		if !ok {
+			p.helpMessage(initialCursor, "Expected field to extract")
 			return nil, initialCursor, false
 		}
 	}
 
-	for _, d := range delimiters {
-		_, _, ok = parseToken(tokens, cursor, d)
-		if ok {
-			return exp, cursor, true
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(fromKeyword))
+	if !ok {
+		p.helpMessage(cursor, "Expected FROM", "FROM")
+		return nil, initialCursor, false
+	}
+
+	source, cursor, ok := p.parseExpression(cursor, []token{tokenFromSymbol(rightParenSymbol)})
+	if !ok {
+		p.helpMessage(cursor, "Expected expression")
+		return nil, initialCursor, false
+	}
+
+	fieldLiteral := *field
+	fieldLiteral.kind = stringKind
+	return []expression{{literal: &fieldLiteral, kind: literalKind}, *source}, cursor, true
+}
+
+func (p *parser) parseParameterExpression(initialCursor uint) (*expression, uint, bool) {
+	t, newCursor, ok := p.parseTokenKind(initialCursor, placeholderKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	index, numbered := p.placeholders[initialCursor]
+	if numbered && index == 0 {
+		p.helpMessage(initialCursor, "Cannot mix ? and $n placeholders in one statement")
+		return nil, initialCursor, false
+	}
+
+	if !numbered {
+		n, err := strconv.ParseUint(t.value[1:], 10, 16)
+		if err != nil || n == 0 {
+			p.helpMessage(initialCursor, "Invalid parameter number")
+			return nil, initialCursor, false
 		}
+		index = uint(n)
 	}
 
-	// Pratt parser work
+	return &expression{
+		parameter: &parameterExpression{
+			placeholder: t,
+			index:       index,
+		},
+		kind: parameterKind,
+	}, newCursor, true
+}
 
-	binOps := []token{
-		tokenFromKeyword(andKeyword),
-		tokenFromKeyword(orKeyword),
+// binaryOperators are the binary operators by precedence, from the
+// loosest binding to the tightest, as in PostgreSQL.
+var binaryOperators = [][]token{
+	{tokenFromKeyword(orKeyword)},
+	{tokenFromKeyword(andKeyword)},
+	{
 		tokenFromSymbol(eqSymbol),
 		tokenFromSymbol(neqSymbol),
 		tokenFromSymbol(neqSymbol2),
@@ -128,59 +406,215 @@ func parseExpression(tokens []*token, initialCursor uint, delimiters []token) (*
 		tokenFromSymbol(gteSymbol),
 		tokenFromSymbol(ltSymbol),
 		tokenFromSymbol(lteSymbol),
+	},
+	{
 		tokenFromSymbol(concatSymbol),
+		tokenFromSymbol(arrowSymbol),
+		tokenFromSymbol(arrowTextSymbol),
+		tokenFromSymbol(pathSymbol),
+		tokenFromSymbol(pathTextSymbol),
+		tokenFromSymbol(containsSymbol),
+	},
+	{
 		tokenFromSymbol(plusSymbol),
 		tokenFromSymbol(minusSymbol),
+	},
+}
+
+// parseBinaryOperator returns the binary operator at cursor and its
+// precedence, which is higher for operators that bind tighter.
+func (p *parser) parseBinaryOperator(initialCursor uint) (*token, int, uint, bool) {
+	for precedence, ops := range binaryOperators {
+		for _, op := range ops {
+			t, cursor, ok := p.parseToken(initialCursor, op)
+			if ok {
+				return t, precedence, cursor, true
+			}
+		}
 	}
 
-	binExp := binaryExpression{
-		a: *exp,
+	return nil, 0, initialCursor, false
+}
+
+func (p *parser) parseExpression(initialCursor uint, delimiters []token) (*expression, uint, bool) {
+	return p.parseBinaryExpression(initialCursor, delimiters, 0)
+}
+
+// parseBinaryExpression parses operands joined by binary operators of
+// at least minPrecedence. Operators of the same precedence group to the
+// left, so 1 - 2 - 3 is (1 - 2) - 3.
+func (p *parser) parseBinaryExpression(initialCursor uint, delimiters []token, minPrecedence int) (*expression, uint, bool) {
+	exp, cursor, ok := p.parseOperand(initialCursor, delimiters)
+	if !ok {
+		return nil, initialCursor, false
 	}
 
-	binOpFound := false
+	for {
+		for _, d := range delimiters {
+			_, _, ok = p.parseToken(cursor, d)
+			if ok {
+				return exp, cursor, true
+			}
+		}
 
-	for _, op := range binOps {
-		var t *token
-		t, cursor, ok = parseToken(tokens, cursor, op)
-		if ok {
-			binExp.op = *t
-			binOpFound = true
-			break
+		op, precedence, newCursor, ok := p.parseBinaryOperator(cursor)
+		if !ok {
+			p.helpMessage(cursor, "Expected binary operator")
+			return nil, initialCursor, false
+		}
+
+		// A looser operator ends the operand of the one before it
+		if precedence < minPrecedence {
+			return exp, cursor, true
+		}
+
+		b, newCursor, ok := p.parseBinaryExpression(newCursor, delimiters, precedence+1)
+		if !ok {
+			p.helpMessage(newCursor, "Expected right operand")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		exp = &expression{
+			binary: &binaryExpression{a: *exp, b: *b, op: *op},
+			kind:   binaryKind,
 		}
 	}
+}
+
+// parseOperand parses an expression in parentheses or one without any
+// binary operators.
+func (p *parser) parseOperand(initialCursor uint, delimiters []token) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	var exp *expression
+	_, newCursor, ok := p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
+
+	if ok {
+		cursor = newCursor
+		rightParenToken := tokenFromSymbol(rightParenSymbol)
+
+		exp, cursor, ok = p.parseExpression(cursor, append(delimiters, rightParenToken))
+		if !ok {
+			p.helpMessage(cursor, "Expected expression after opening paren")
+			return nil, initialCursor, false
+		}
 
-	if !binOpFound {
-		helpMessage(tokens, cursor, "Expected binary operator")
+		_, cursor, ok = p.parseToken(cursor, rightParenToken)
+
+		if !ok {
+			p.helpMessage(cursor, "Expected closing paren", ")")
+			return nil, initialCursor, false
+		}
+	} else {
+		exp, cursor, ok = p.parseCastExpression(cursor)
+		if !ok {
+			exp, cursor, ok = p.parseTypedLiteral(cursor)
+		}
+		if !ok {
+			exp, cursor, ok = p.parseCallExpression(cursor)
+		}
+		if !ok {
+			exp, cursor, ok = p.parseLiteralExpression(cursor)
+		}
+		if !ok {
+			exp, cursor, ok = p.parseParameterExpression(cursor)
+		}
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+
+	// A :: cast binds tighter than any operator
+	for {
+		_, newCursor, ok := p.parseToken(cursor, tokenFromSymbol(castSymbol))
+		if !ok {
+			return exp, cursor, true
+		}
+
+		cast, newCursor, ok := p.parseCastType(*exp, newCursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		exp, cursor = cast, newCursor
+	}
+}
+
+// parseCastExpression parses CAST(expression AS type).
+func (p *parser) parseCastExpression(initialCursor uint) (*expression, uint, bool) {
+	_, cursor, ok := p.parseToken(initialCursor, tokenFromKeyword(castKeyword))
+	if !ok {
 		return nil, initialCursor, false
 	}
 
-	b, newCursor, ok := parseExpression(tokens, cursor, delimiters)
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
+	if !ok {
+		p.helpMessage(cursor, "Expected opening paren", "(")
+		return nil, initialCursor, false
+	}
+
+	exp, cursor, ok := p.parseExpression(cursor, []token{tokenFromKeyword(asKeyword)})
+	if !ok {
+		p.helpMessage(cursor, "Expected expression")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(asKeyword))
+	if !ok {
+		p.helpMessage(cursor, "Expected AS", "AS")
+		return nil, initialCursor, false
+	}
+
+	cast, cursor, ok := p.parseCastType(*exp, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
 
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(rightParenSymbol))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected right operand")
+		p.helpMessage(cursor, "Expected closing paren", ")")
 		return nil, initialCursor, false
 	}
 
-	binExp.b = *b
+	return cast, cursor, true
+}
+
+// parseCastType parses the type, with any modifiers, that exp is cast
+// to.
+func (p *parser) parseCastType(exp expression, initialCursor uint) (*expression, uint, bool) {
+	ty, cursor, ok := p.parseColumnType(initialCursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	modifiers, cursor, ok := p.parseTypeModifiers(cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
 
 	return &expression{
-		binary: &binExp,
-		kind:   binaryKind,
-	}, newCursor, true
+		cast: &castExpression{
+			exp:       exp,
+			datatype:  *ty,
+			modifiers: modifiers,
+		},
+		kind: castKind,
+	}, cursor, true
 }
 
-func parseExpressions(tokens []*token, initialCursor uint, delimiters []token) (*[]*expression, uint, bool) {
+func (p *parser) parseExpressions(initialCursor uint, delimiters []token) (*[]*expression, uint, bool) {
 	cursor := initialCursor
 
 	exps := []*expression{}
 outer:
 	for {
-		if cursor >= uint(len(tokens)) {
+		if cursor >= uint(len(p.tokens)) {
 			return nil, initialCursor, false
 		}
 
 		// Look for delimiter
-		current := tokens[cursor]
+		current := p.tokens[cursor]
 		for _, delimiter := range delimiters {
 			if delimiter.equals(current) {
 				break outer
@@ -190,17 +624,17 @@ outer:
 		// Look for comma
 		if len(exps) > 0 {
 			var ok bool
-			_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(commaSymbol))
+			_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(commaSymbol))
 			if !ok {
-				helpMessage(tokens, cursor, "Expected comma")
+				p.helpMessage(cursor, "Expected comma", ",")
 				return nil, initialCursor, false
 			}
 		}
 
 		// Look for expression
-		exp, newCursor, ok := parseExpression(tokens, cursor, []token{tokenFromSymbol(commaSymbol), tokenFromSymbol(rightParenSymbol)})
+		exp, newCursor, ok := p.parseExpression(cursor, []token{tokenFromSymbol(commaSymbol), tokenFromSymbol(rightParenSymbol)})
 		if !ok {
-			helpMessage(tokens, cursor, "Expected expression")
+			p.helpMessage(cursor, "Expected expression")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
@@ -211,18 +645,18 @@ outer:
 	return &exps, cursor, true
 }
 
-func parseSelectItem(tokens []*token, initialCursor uint, delimiters []token) (*[]*selectItem, uint, bool) {
+func (p *parser) parseSelectItem(initialCursor uint, delimiters []token) (*[]*selectItem, uint, bool) {
 	cursor := initialCursor
 
 	s := []*selectItem{}
 
 outer:
 	for {
-		if cursor >= uint(len(tokens)) {
+		if cursor >= uint(len(p.tokens)) {
 			return nil, initialCursor, false
 		}
 
-		current := tokens[cursor]
+		current := p.tokens[cursor]
 		for _, delimiter := range delimiters {
 			if delimiter.equals(current) {
 				break outer
@@ -231,38 +665,38 @@ outer:
 
 		var ok bool
 		if len(s) > 0 {
-			_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(commaSymbol))
+			_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(commaSymbol))
 			if !ok {
-				helpMessage(tokens, cursor, "Expected comma")
+				p.helpMessage(cursor, "Expected comma", ",")
 				return nil, initialCursor, false
 			}
 		}
 
 		var si selectItem
 
-		_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(asteriskSymbol))
+		_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(asteriskSymbol))
 
 		if ok {
 			si = selectItem{asteriks: true}
 		} else {
 			asToken := tokenFromKeyword(asKeyword)
 			delimiters := append(delimiters, tokenFromSymbol(commaSymbol), asToken)
-			exp, newCursor, ok := parseExpression(tokens, cursor, delimiters)
+			exp, newCursor, ok := p.parseExpression(cursor, delimiters)
 
 			if !ok {
-				helpMessage(tokens, cursor, "Expected expression")
+				p.helpMessage(cursor, "Expected expression")
 				return nil, initialCursor, false
 			}
 
 			cursor = newCursor
 			si.exp = exp
 
-			_, cursor, ok = parseToken(tokens, cursor, asToken)
+			_, cursor, ok = p.parseToken(cursor, asToken)
 
 			if ok {
-				id, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+				id, newCursor, ok := p.parseIdentifier(cursor)
 				if !ok {
-					helpMessage(tokens, cursor, "Expected identifier after AS")
+					p.helpMessage(cursor, "Expected identifier after AS")
 				}
 
 				cursor = newCursor
@@ -277,8 +711,8 @@ outer:
 	return &s, cursor, true
 }
 
-func parseFromItem(tokens []*token, initialCursor uint, _ []token) (*fromItem, uint, bool) {
-	ident, newCursor, ok := parseTokenKind(tokens, initialCursor, identifierKind)
+func (p *parser) parseFromItem(initialCursor uint, _ []token) (*fromItem, uint, bool) {
+	ident, newCursor, ok := p.parseIdentifier(initialCursor)
 	if !ok {
 		return nil, initialCursor, false
 	}
@@ -286,11 +720,11 @@ func parseFromItem(tokens []*token, initialCursor uint, _ []token) (*fromItem, u
 	return &fromItem{table: ident}, newCursor, true
 }
 
-func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token) (*SelectStatement, uint, bool) {
+func (p *parser) parseSelectStatement(initialCursor uint, delimiter token) (*SelectStatement, uint, bool) {
 	var ok bool
 	cursor := initialCursor
 
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(selectKeyword))
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(selectKeyword))
 	if !ok {
 		return nil, initialCursor, false
 	}
@@ -298,7 +732,7 @@ func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token)
 	slct := SelectStatement{}
 
 	fromToken := tokenFromKeyword(fromKeyword)
-	item, newCursor, ok := parseSelectItem(tokens, cursor, []token{fromToken, delimiter})
+	item, newCursor, ok := p.parseSelectItem(cursor, []token{fromToken, delimiter})
 	if !ok {
 		return nil, initialCursor, false
 	}
@@ -309,12 +743,12 @@ func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token)
 	whereToken := tokenFromKeyword(whereKeyword)
 	delimiters := []token{delimiter, whereToken}
 
-	_, cursor, ok = parseToken(tokens, cursor, fromToken)
+	_, cursor, ok = p.parseToken(cursor, fromToken)
 	if ok {
 
-		from, newCursor, ok := parseFromItem(tokens, cursor, delimiters)
+		from, newCursor, ok := p.parseFromItem(cursor, delimiters)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected FROM token")
+			p.helpMessage(cursor, "Expected table name after FROM")
 			return nil, initialCursor, false
 		}
 
@@ -322,12 +756,12 @@ func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token)
 		cursor = newCursor
 	}
 
-	_, cursor, ok = parseToken(tokens, cursor, whereToken)
+	_, cursor, ok = p.parseToken(cursor, whereToken)
 	if ok {
-		where, newCursor, ok := parseExpression(tokens, cursor, []token{delimiter})
+		where, newCursor, ok := p.parseExpression(cursor, []token{delimiter})
 
 		if !ok {
-			helpMessage(tokens, cursor, "Expected WHERE conditionals")
+			p.helpMessage(cursor, "Expected WHERE conditionals")
 		}
 		slct.where = where
 		cursor = newCursor
@@ -336,55 +770,55 @@ func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token)
 	return &slct, cursor, true
 }
 
-func parseInsertStatement(tokens []*token, initialCursor uint, delimiter token) (*InsertStatement, uint, bool) {
+func (p *parser) parseInsertStatement(initialCursor uint, delimiter token) (*InsertStatement, uint, bool) {
 	cursor := initialCursor
 
 	// Look for INSERT
-	_, cursor, ok := parseToken(tokens, cursor, tokenFromKeyword(insertKeyword))
+	_, cursor, ok := p.parseToken(cursor, tokenFromKeyword(insertKeyword))
 	if !ok {
 		return nil, initialCursor, false
 	}
 
 	// Look for INTO
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(intoKeyword))
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(intoKeyword))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected into")
+		p.helpMessage(cursor, "Expected into", "INTO")
 		return nil, initialCursor, false
 	}
 
 	// Look for table name
-	table, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+	table, newCursor, ok := p.parseIdentifier(cursor)
 	if !ok {
-		helpMessage(tokens, cursor, "Expected table name")
+		p.helpMessage(cursor, "Expected table name")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
 	// Look for VALUES
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(valuesKeyword))
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(valuesKeyword))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected VALUES")
+		p.helpMessage(cursor, "Expected VALUES", "VALUES")
 		return nil, initialCursor, false
 	}
 
 	// Look for left paren
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(leftParenSymbol))
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected left paren")
+		p.helpMessage(cursor, "Expected left paren", "(")
 		return nil, initialCursor, false
 	}
 
 	// Look for expression list
-	values, newCursor, ok := parseExpressions(tokens, cursor, []token{tokenFromSymbol(rightParenSymbol)})
+	values, newCursor, ok := p.parseExpressions(cursor, []token{tokenFromSymbol(rightParenSymbol)})
 	if !ok {
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
 	// Look for right paren
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(rightParenSymbol))
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(rightParenSymbol))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected right paren")
+		p.helpMessage(cursor, "Expected right paren", ")")
 		return nil, initialCursor, false
 	}
 
@@ -394,12 +828,187 @@ func parseInsertStatement(tokens []*token, initialCursor uint, delimiter token)
 	}, cursor, true
 }
 
-func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Statement, uint, bool) {
+func (p *parser) parseCopyOptions(initialCursor uint, cp *CopyStatement) (uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, ok := p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
+	if !ok {
+		p.helpMessage(cursor, "Expected left paren", "(")
+		return initialCursor, false
+	}
+
+	headerToken := tokenFromKeyword(headerKeyword)
+	delimiterToken := tokenFromKeyword(delimiterKeyword)
+
+	for {
+		switch {
+		case cursor >= uint(len(p.tokens)):
+			return initialCursor, false
+
+		case p.tokens[cursor].equals(&headerToken):
+			cursor++
+			cp.header = true
+
+			value, newCursor, ok := p.parseTokenKind(cursor, boolKind)
+			if ok {
+				cp.header = value.value == string(trueKeyword)
+				cursor = newCursor
+			}
+
+		case p.tokens[cursor].equals(&delimiterToken):
+			cursor++
+
+			value, newCursor, ok := p.parseTokenKind(cursor, stringKind)
+			if !ok || len([]rune(value.value)) != 1 {
+				p.helpMessage(cursor, "Expected single character delimiter")
+				return initialCursor, false
+			}
+
+			cp.delimiter = []rune(value.value)[0]
+			cursor = newCursor
+
+		default:
+			p.helpMessage(cursor, "Expected COPY option", "HEADER", "DELIMITER")
+			return initialCursor, false
+		}
+
+		_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(commaSymbol))
+		if !ok {
+			break
+		}
+	}
+
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(rightParenSymbol))
+	if !ok {
+		p.helpMessage(cursor, "Expected right paren", ")")
+		return initialCursor, false
+	}
+
+	return cursor, true
+}
+
+func (p *parser) parseCopyStatement(initialCursor uint, delimiter token) (*CopyStatement, uint, bool) {
+	cursor := initialCursor
+
+	// Look for COPY
+	_, cursor, ok := p.parseToken(cursor, tokenFromKeyword(copyKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	cp := CopyStatement{delimiter: ','}
+
+	// Look for a parenthesized query or a table name
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
+	if ok {
+		rightParenToken := tokenFromSymbol(rightParenSymbol)
+		slct, newCursor, ok := p.parseSelectStatement(cursor, rightParenToken)
+		if !ok {
+			p.helpMessage(cursor, "Expected SELECT statement")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		_, cursor, ok = p.parseToken(cursor, rightParenToken)
+		if !ok {
+			p.helpMessage(cursor, "Expected right paren", ")")
+			return nil, initialCursor, false
+		}
+
+		cp.query = slct
+	} else {
+		table, newCursor, ok := p.parseIdentifier(cursor)
+		if !ok {
+			p.helpMessage(cursor, "Expected table name")
+			return nil, initialCursor, false
+		}
+
+		cp.table = table
+		cursor = newCursor
+	}
+
+	// Look for FROM or TO
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(fromKeyword))
+	if ok {
+		if cp.query != nil {
+			p.helpMessage(cursor-1, "Expected TO after COPY query", "TO")
+			return nil, initialCursor, false
+		}
+
+		cp.from = true
+	} else {
+		_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(toKeyword))
+		if !ok {
+			p.helpMessage(cursor, "Expected FROM or TO", "FROM", "TO")
+			return nil, initialCursor, false
+		}
+	}
+
+	// Look for file name
+	file, newCursor, ok := p.parseTokenKind(cursor, stringKind)
+	if !ok {
+		p.helpMessage(cursor, "Expected file name")
+		return nil, initialCursor, false
+	}
+	cp.file = *file
+	cursor = newCursor
+
+	// Look for options
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(withKeyword))
+	if ok {
+		cursor, ok = p.parseCopyOptions(cursor, &cp)
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+
+	return &cp, cursor, true
+}
+
+func (p *parser) parseSetStatement(initialCursor uint, delimiter token) (*SetStatement, uint, bool) {
+	cursor := initialCursor
+
+	// Look for SET
+	_, cursor, ok := p.parseToken(cursor, tokenFromKeyword(setKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	// Look for setting name
+	name, cursor, ok := p.parseIdentifier(cursor)
+	if !ok {
+		p.helpMessage(cursor, "Expected setting name")
+		return nil, initialCursor, false
+	}
+
+	// Look for = or TO
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(eqSymbol))
+	if !ok {
+		_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(toKeyword))
+		if !ok {
+			p.helpMessage(cursor, "Expected = or TO", "=", "TO")
+			return nil, initialCursor, false
+		}
+	}
+
+	// Look for value
+	for _, kind := range []tokenKind{stringKind, numericKind, identifierKind} {
+		value, newCursor, ok := p.parseTokenKind(cursor, kind)
+		if ok {
+			return &SetStatement{name: *name, value: *value}, newCursor, true
+		}
+	}
+
+	p.helpMessage(cursor, "Expected setting value")
+	return nil, initialCursor, false
+}
+
+func (p *parser) parseStatement(initialCursor uint, delimiter token) (*Statement, uint, bool) {
 	cursor := initialCursor
 
 	// Look for a SELECT statement
 	semicolonToken := tokenFromSymbol(semicolonSymbol)
-	slct, newCursor, ok := parseSelectStatement(tokens, cursor, semicolonToken)
+	slct, newCursor, ok := p.parseSelectStatement(cursor, semicolonToken)
 
 	if ok {
 		return &Statement{
@@ -409,7 +1018,7 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 	}
 
 	// // Look for a INSERT statement
-	inst, newCursor, ok := parseInsertStatement(tokens, cursor, semicolonToken)
+	inst, newCursor, ok := p.parseInsertStatement(cursor, semicolonToken)
 	if ok {
 		return &Statement{
 			Kind:            InsertKind,
@@ -418,7 +1027,7 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 	}
 
 	// Look for a CREATE statement
-	crtTbl, newCursor, ok := parseCreateTableStatement(tokens, cursor, semicolonToken)
+	crtTbl, newCursor, ok := p.parseCreateTableStatement(cursor, semicolonToken)
 	if ok {
 		return &Statement{
 			Kind:                 CreateTableKind,
@@ -426,20 +1035,38 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 		}, newCursor, true
 	}
 
+	// Look for a COPY statement
+	cp, newCursor, ok := p.parseCopyStatement(cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:          CopyKind,
+			CopyStatement: cp,
+		}, newCursor, true
+	}
+
+	// Look for a SET statement
+	set, newCursor, ok := p.parseSetStatement(cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:         SetKind,
+			SetStatement: set,
+		}, newCursor, true
+	}
+
 	return nil, initialCursor, false
 }
 
-func parseColumnDefinitions(tokens []*token, initialCursor uint, delimiter token) (*[]*columnDefinition, uint, bool) {
+func (p *parser) parseColumnDefinitions(initialCursor uint, delimiter token) (*[]*columnDefinition, uint, bool) {
 	cursor := initialCursor
 
 	cds := []*columnDefinition{}
 	for {
-		if cursor >= uint(len(tokens)) {
+		if cursor >= uint(len(p.tokens)) {
 			return nil, initialCursor, false
 		}
 
 		// Look for a delimiter
-		current := tokens[cursor]
+		current := p.tokens[cursor]
 		if delimiter.equals(current) {
 			break
 		}
@@ -447,73 +1074,179 @@ func parseColumnDefinitions(tokens []*token, initialCursor uint, delimiter token
 		// Look for a comma
 		if len(cds) > 0 {
 			var ok bool
-			_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(commaSymbol))
+			_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(commaSymbol))
 			if !ok {
-				helpMessage(tokens, cursor, "Expected comma")
+				p.helpMessage(cursor, "Expected comma", ",")
 				return nil, initialCursor, false
 			}
 		}
 
 		// Look for a column name
-		id, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+		id, newCursor, ok := p.parseIdentifier(cursor)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected column name")
+			p.helpMessage(cursor, "Expected column name")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
 
 		// Look for a column type
-		ty, newCursor, ok := parseTokenKind(tokens, cursor, keywordKind)
+		ty, newCursor, ok := p.parseColumnType(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		// Look for type modifiers
+		modifiers, newCursor, ok := p.parseTypeModifiers(cursor)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected column type")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
 
 		cds = append(cds, &columnDefinition{
-			name:     *id,
-			datatype: *ty,
+			name:      *id,
+			datatype:  *ty,
+			modifiers: modifiers,
 		})
 	}
 
 	return &cds, cursor, true
 }
 
-func parseCreateTableStatement(tokens []*token, initialCursor uint, delimiter token) (*CreateTableStatement, uint, bool) {
+// parseColumnType parses a type name. The two word names double
+// precision and character varying are joined into a single token.
+func (p *parser) parseColumnType(initialCursor uint) (*token, uint, bool) {
+	ty, cursor, ok := p.parseTokenKind(initialCursor, keywordKind)
+	if !ok {
+		p.helpMessage(initialCursor, "Expected column type", "INT", "TEXT", "BOOLEAN", "FLOAT", "SMALLINT", "BIGINT", "REAL", "DOUBLE PRECISION", "NUMERIC", "VARCHAR", "CHAR", "DATE", "TIME", "TIMESTAMP", "TIMESTAMPTZ", "INTERVAL", "JSON", "JSONB", "BYTEA")
+		return nil, initialCursor, false
+	}
+
+	var second keyword
+	switch keyword(ty.value) {
+	case doubleKeyword:
+		second = precisionKeyword
+	case characterKeyword:
+		second = varyingKeyword
+	case timestampKeyword, timeKeyword:
+		return p.parseTimeZone(ty, cursor)
+	default:
+		return ty, cursor, true
+	}
+
+	next, newCursor, ok := p.parseToken(cursor, tokenFromKeyword(second))
+	if !ok {
+		if second == precisionKeyword {
+			p.helpMessage(cursor, "Expected PRECISION", "PRECISION")
+			return nil, initialCursor, false
+		}
+
+		return ty, cursor, true
+	}
+
+	joined := *ty
+	joined.value = ty.value + " " + next.value
+	joined.end = next.end
+	return &joined, newCursor, true
+}
+
+// parseTimeZone parses the WITH TIME ZONE or WITHOUT TIME ZONE that may
+// follow timestamp and time. With a time zone is joined to the type
+// name, without one is what the type means anyway.
+func (p *parser) parseTimeZone(ty *token, initialCursor uint) (*token, uint, bool) {
+	with, cursor, ok := p.parseToken(initialCursor, tokenFromKeyword(withKeyword))
+	if !ok {
+		with, cursor, ok = p.parseToken(initialCursor, tokenFromKeyword(withoutKeyword))
+		if !ok {
+			return ty, initialCursor, true
+		}
+	}
+
+	for _, k := range []keyword{timeKeyword, zoneKeyword} {
+		_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(k))
+		if !ok {
+			p.helpMessage(cursor, "Expected TIME ZONE", "TIME ZONE")
+			return nil, initialCursor, false
+		}
+	}
+
+	joined := *ty
+	joined.end = p.tokens[cursor-1].end
+	if with.value == string(withKeyword) {
+		joined.value = ty.value + " with time zone"
+	}
+
+	return &joined, cursor, true
+}
+
+// parseTypeModifiers parses the optional (n) or (p, s) after a type.
+func (p *parser) parseTypeModifiers(initialCursor uint) ([]*token, uint, bool) {
+	_, cursor, ok := p.parseToken(initialCursor, tokenFromSymbol(leftParenSymbol))
+	if !ok {
+		return nil, initialCursor, true
+	}
+
+	modifiers := []*token{}
+	for {
+		if len(modifiers) > 0 {
+			_, newCursor, ok := p.parseToken(cursor, tokenFromSymbol(rightParenSymbol))
+			if ok {
+				return modifiers, newCursor, true
+			}
+
+			_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(commaSymbol))
+			if !ok {
+				p.helpMessage(cursor, "Expected comma or closing paren", ",", ")")
+				return nil, initialCursor, false
+			}
+		}
+
+		n, newCursor, ok := p.parseTokenKind(cursor, numericKind)
+		if !ok {
+			p.helpMessage(cursor, "Expected type modifier")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		modifiers = append(modifiers, n)
+	}
+}
+
+func (p *parser) parseCreateTableStatement(initialCursor uint, delimiter token) (*CreateTableStatement, uint, bool) {
 	cursor := initialCursor
 
-	_, cursor, ok := parseToken(tokens, cursor, tokenFromKeyword(createKeyword))
+	_, cursor, ok := p.parseToken(cursor, tokenFromKeyword(createKeyword))
 	if !ok {
 		return nil, initialCursor, false
 	}
 
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(tableKeyword))
+	_, cursor, ok = p.parseToken(cursor, tokenFromKeyword(tableKeyword))
 	if !ok {
 		return nil, initialCursor, false
 	}
 
-	name, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+	name, newCursor, ok := p.parseIdentifier(cursor)
 	if !ok {
-		helpMessage(tokens, cursor, "Expected table name")
+		p.helpMessage(cursor, "Expected table name")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(leftParenSymbol))
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(leftParenSymbol))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected left parenthesis")
+		p.helpMessage(cursor, "Expected left parenthesis", "(")
 		return nil, initialCursor, false
 	}
 
-	cols, newCursor, ok := parseColumnDefinitions(tokens, cursor, tokenFromSymbol(rightParenSymbol))
+	cols, newCursor, ok := p.parseColumnDefinitions(cursor, tokenFromSymbol(rightParenSymbol))
 	if !ok {
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(rightParenSymbol))
+	_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(rightParenSymbol))
 	if !ok {
-		helpMessage(tokens, cursor, "Expected right parenthesis")
+		p.helpMessage(cursor, "Expected right parenthesis", ")")
 		return nil, initialCursor, false
 	}
 
@@ -523,28 +1256,57 @@ func parseCreateTableStatement(tokens []*token, initialCursor uint, delimiter to
 	}, cursor, true
 }
 
-func Parse(source string) (*Ast, error) {
-	tokens, err := lex(source)
-	if err != nil {
-		return nil, err
+// skipStatement returns the cursor just past the semicolons ending the
+// statement at cursor.
+func (p *parser) skipStatement(initialCursor uint) uint {
+	cursor := initialCursor
+	semicolonToken := tokenFromSymbol(semicolonSymbol)
+
+	for cursor < uint(len(p.tokens)) && !p.tokens[cursor].equals(&semicolonToken) {
+		cursor++
+	}
+
+	for cursor < uint(len(p.tokens)) && p.tokens[cursor].equals(&semicolonToken) {
+		cursor++
+	}
+
+	return cursor
+}
+
+func parse(source string, recovering bool) (*Ast, error) {
+	tokens, errs := lexTokens(source, recovering, false)
+	if len(errs) > 0 && !recovering {
+		return nil, errs[0]
 	}
 
+	return newParser(source, tokens).parseStatements(recovering, errs)
+}
+
+// parseStatements parses every statement in the tokens, adding syntax
+// errors to the lexing errors when recovering.
+func (p *parser) parseStatements(recovering bool, errs ParseErrors) (*Ast, error) {
 	a := Ast{}
 	cursor := uint(0)
-	for cursor < uint(len(tokens)) {
-		stmt, newCursor, ok := parseStatement(tokens, cursor, tokenFromSymbol(semicolonSymbol))
+	for cursor < uint(len(p.tokens)) {
+		stmt, newCursor, ok := p.parseStatement(cursor, tokenFromSymbol(semicolonSymbol))
 		if !ok {
-			helpMessage(tokens, cursor, "Expected statement")
-			return nil, errors.New("Failed to parse, expected statement")
+			p.helpMessage(cursor, "Expected statement", "SELECT", "INSERT", "CREATE", "COPY", "SET")
+			if !recovering {
+				return nil, p.err
+			}
+
+			errs = append(errs, p.err)
+			p.err = nil
+			cursor = p.skipStatement(cursor)
+			continue
 		}
 		cursor = newCursor
-
-		a.Statements = append(a.Statements, stmt)
+		p.err = nil
 
 		atLeastOneSemicolon := false
 
 		for {
-			_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(semicolonSymbol))
+			_, cursor, ok = p.parseToken(cursor, tokenFromSymbol(semicolonSymbol))
 			if ok {
 				atLeastOneSemicolon = true
 			} else {
@@ -553,10 +1315,41 @@ func Parse(source string) (*Ast, error) {
 		}
 
 		if !atLeastOneSemicolon {
-			helpMessage(tokens, cursor, "Expected semi-colon delimiter between statements")
-			return nil, errors.New("Missing semi-colon between statements")
+			p.helpMessage(cursor, "Expected semi-colon delimiter between statements", ";")
+			if !recovering {
+				return nil, p.err
+			}
+
+			// What follows cannot be told apart from the statement so
+			// both are dropped
+			errs = append(errs, p.err)
+			p.err = nil
+			cursor = p.skipStatement(cursor)
+			continue
 		}
+
+		a.Statements = append(a.Statements, stmt)
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Offset < errs[j].Offset
+		})
+
+		return &a, errs
 	}
 
 	return &a, nil
 }
+
+func Parse(source string) (*Ast, error) {
+	return parse(source, false)
+}
+
+// ParseRecover parses every statement it can. After a syntax error it
+// skips to the next semicolon and carries on, so the error is a
+// ParseErrors listing every problem found. The statements that parsed
+// are returned even when there are errors.
+func ParseRecover(source string) (*Ast, error) {
+	return parse(source, true)
+}
@@ -19,14 +19,16 @@ func TestParse(t *testing.T) {
 						Kind: InsertKind,
 						InsertStatement: &InsertStatement{
 							table: token{
-								loc:   location{col: 12, line: 0},
+								loc:   location{line: 0, col: 12, offset: 12},
+								end:   location{line: 0, col: 17, offset: 17},
 								kind:  identifierKind,
 								value: "users",
 							},
 							values: &[]*expression{
 								{
 									literal: &token{
-										loc:   location{col: 26, line: 0},
+										loc:   location{line: 0, col: 26, offset: 26},
+										end:   location{line: 0, col: 29, offset: 29},
 										kind:  numericKind,
 										value: "105",
 									},
@@ -34,7 +36,8 @@ func TestParse(t *testing.T) {
 								},
 								{
 									literal: &token{
-										loc:   location{col: 32, line: 0},
+										loc:   location{line: 0, col: 31, offset: 31},
+										end:   location{line: 0, col: 34, offset: 34},
 										kind:  numericKind,
 										value: "233",
 									},
@@ -54,31 +57,36 @@ func TestParse(t *testing.T) {
 						Kind: CreateTableKind,
 						CreateTableStatement: &CreateTableStatement{
 							name: token{
-								loc:   location{col: 13, line: 0},
+								loc:   location{line: 0, col: 13, offset: 13},
+								end:   location{line: 0, col: 18, offset: 18},
 								kind:  identifierKind,
 								value: "users",
 							},
 							cols: &[]*columnDefinition{
 								{
 									name: token{
-										loc:   location{col: 20, line: 0},
+										loc:   location{line: 0, col: 20, offset: 20},
+										end:   location{line: 0, col: 22, offset: 22},
 										kind:  identifierKind,
 										value: "id",
 									},
 									datatype: token{
-										loc:   location{col: 23, line: 0},
+										loc:   location{line: 0, col: 23, offset: 23},
+										end:   location{line: 0, col: 26, offset: 26},
 										kind:  keywordKind,
 										value: "int",
 									},
 								},
 								{
 									name: token{
-										loc:   location{col: 28, line: 0},
+										loc:   location{line: 0, col: 28, offset: 28},
+										end:   location{line: 0, col: 32, offset: 32},
 										kind:  identifierKind,
 										value: "name",
 									},
 									datatype: token{
-										loc:   location{col: 33, line: 0},
+										loc:   location{line: 0, col: 33, offset: 33},
+										end:   location{line: 0, col: 37, offset: 37},
 										kind:  keywordKind,
 										value: "text",
 									},
@@ -102,7 +110,8 @@ func TestParse(t *testing.T) {
 									exp: &expression{
 										kind: literalKind,
 										literal: &token{
-											loc:   location{col: 7, line: 0},
+											loc:   location{line: 0, col: 7, offset: 7},
+											end:   location{line: 0, col: 16, offset: 16},
 											kind:  identifierKind,
 											value: "exclusive",
 										},
@@ -130,7 +139,8 @@ func TestParse(t *testing.T) {
 									exp: &expression{
 										kind: literalKind,
 										literal: &token{
-											loc:   location{col: 10, line: 0},
+											loc:   location{line: 0, col: 10, offset: 10},
+											end:   location{line: 0, col: 19, offset: 19},
 											kind:  identifierKind,
 											value: "exclusive",
 										},
@@ -153,7 +163,8 @@ func TestParse(t *testing.T) {
 								exp: &expression{
 									kind: literalKind,
 									literal: &token{
-										loc:   location{col: 7, line: 0},
+										loc:   location{line: 0, col: 7, offset: 7},
+										end:   location{line: 0, col: 9, offset: 9},
 										kind:  identifierKind,
 										value: "id",
 									},
@@ -163,13 +174,15 @@ func TestParse(t *testing.T) {
 								exp: &expression{
 									kind: literalKind,
 									literal: &token{
-										loc:   location{col: 11, line: 0},
+										loc:   location{line: 0, col: 11, offset: 11},
+										end:   location{line: 0, col: 15, offset: 15},
 										kind:  identifierKind,
 										value: "name",
 									},
 								},
 								as: &token{
-									loc:   location{col: 19, line: 0},
+									loc:   location{line: 0, col: 19, offset: 19},
+									end:   location{line: 0, col: 27, offset: 27},
 									kind:  identifierKind,
 									value: "fullname",
 								},
@@ -178,7 +191,8 @@ func TestParse(t *testing.T) {
 						from: &fromItem{
 							table: &token{
 								value: "users",
-								loc:   location{col: 33, line: 0},
+								loc:   location{line: 0, col: 33, offset: 33},
+								end:   location{line: 0, col: 38, offset: 38},
 								kind:  identifierKind,
 							},
 						},
@@ -196,7 +210,8 @@ func TestParse(t *testing.T) {
 							exp: &expression{
 								kind: literalKind,
 								literal: &token{
-									loc:   location{col: 7, line: 0},
+									loc:   location{line: 0, col: 7, offset: 7},
+									end:   location{line: 0, col: 9, offset: 9},
 									kind:  identifierKind,
 									value: "id",
 								},
@@ -206,7 +221,8 @@ func TestParse(t *testing.T) {
 							exp: &expression{
 								kind: literalKind,
 								literal: &token{
-									loc:   location{col: 11, line: 0},
+									loc:   location{line: 0, col: 11, offset: 11},
+									end:   location{line: 0, col: 15, offset: 15},
 									kind:  identifierKind,
 									value: "name",
 								},
@@ -216,7 +232,8 @@ func TestParse(t *testing.T) {
 					from: &fromItem{
 						table: &token{
 							value: "user",
-							loc:   location{col: 21, line: 0},
+							loc:   location{line: 0, col: 21, offset: 21},
+							end:   location{line: 0, col: 25, offset: 25},
 							kind:  identifierKind,
 						},
 					},
@@ -225,7 +242,8 @@ func TestParse(t *testing.T) {
 							a: expression{
 								literal: &token{
 									value: "age",
-									loc:   location{line: 0, col: 32},
+									loc:   location{line: 0, col: 32, offset: 32},
+									end:   location{line: 0, col: 35, offset: 35},
 									kind:  identifierKind,
 								},
 								kind: literalKind,
@@ -234,14 +252,16 @@ func TestParse(t *testing.T) {
 								literal: &token{
 									value: "23",
 									kind:  numericKind,
-									loc:   location{line: 0, col: 38},
+									loc:   location{line: 0, col: 38, offset: 38},
+									end:   location{line: 0, col: 40, offset: 40},
 								},
 								kind: literalKind,
 							},
 							op: token{
 								value: "=",
 								kind:  symbolKind,
-								loc:   location{line: 0, col: 36},
+								loc:   location{line: 0, col: 36, offset: 36},
+								end:   location{line: 0, col: 37, offset: 37},
 							},
 						},
 						kind: binaryKind,
@@ -257,14 +277,16 @@ func TestParse(t *testing.T) {
 						Kind: InsertKind,
 						InsertStatement: &InsertStatement{
 							table: token{
-								loc:   location{col: 12, line: 0},
+								loc:   location{line: 0, col: 12, offset: 12},
+								end:   location{line: 0, col: 17, offset: 17},
 								kind:  identifierKind,
 								value: "users",
 							},
 							values: &[]*expression{
 								{
 									literal: &token{
-										loc:   location{col: 26, line: 0},
+										loc:   location{line: 0, col: 26, offset: 26},
+										end:   location{line: 0, col: 29, offset: 29},
 										kind:  numericKind,
 										value: "105",
 									},
@@ -273,7 +295,8 @@ func TestParse(t *testing.T) {
 									binary: &binaryExpression{
 										a: expression{
 											literal: &token{
-												loc:   location{col: 32, line: 0},
+												loc:   location{line: 0, col: 31, offset: 31},
+												end:   location{line: 0, col: 34, offset: 34},
 												kind:  numericKind,
 												value: "233",
 											},
@@ -281,14 +304,16 @@ func TestParse(t *testing.T) {
 										},
 										b: expression{
 											literal: &token{
-												loc:   location{col: 39, line: 0},
+												loc:   location{line: 0, col: 37, offset: 37},
+												end:   location{line: 0, col: 39, offset: 39},
 												kind:  numericKind,
 												value: "42",
 											},
 											kind: literalKind,
 										},
 										op: token{
-											loc:   location{col: 37, line: 0},
+											loc:   location{line: 0, col: 35, offset: 35},
+											end:   location{line: 0, col: 36, offset: 36},
 											kind:  symbolKind,
 											value: string(plusSymbol),
 										},
@@ -309,14 +334,16 @@ func TestParse(t *testing.T) {
 						Kind: InsertKind,
 						InsertStatement: &InsertStatement{
 							table: token{
-								loc:   location{col: 12, line: 0},
+								loc:   location{line: 0, col: 12, offset: 12},
+								end:   location{line: 0, col: 17, offset: 17},
 								kind:  identifierKind,
 								value: "users",
 							},
 							values: &[]*expression{
 								{
 									literal: &token{
-										loc:   location{col: 26, line: 0},
+										loc:   location{line: 0, col: 26, offset: 26},
+										end:   location{line: 0, col: 29, offset: 29},
 										kind:  numericKind,
 										value: "105",
 									},
@@ -325,7 +352,8 @@ func TestParse(t *testing.T) {
 									binary: &binaryExpression{
 										a: expression{
 											literal: &token{
-												loc:   location{col: 32, line: 0},
+												loc:   location{line: 0, col: 31, offset: 31},
+												end:   location{line: 0, col: 34, offset: 34},
 												kind:  numericKind,
 												value: "233",
 											},
@@ -333,14 +361,16 @@ func TestParse(t *testing.T) {
 										},
 										b: expression{
 											literal: &token{
-												loc:   location{col: 39, line: 0},
+												loc:   location{line: 0, col: 37, offset: 37},
+												end:   location{line: 0, col: 39, offset: 39},
 												kind:  numericKind,
 												value: "42",
 											},
 											kind: literalKind,
 										},
 										op: token{
-											loc:   location{col: 37, line: 0},
+											loc:   location{line: 0, col: 35, offset: 35},
+											end:   location{line: 0, col: 36, offset: 36},
 											kind:  symbolKind,
 											value: string(minusSymbol),
 										},
@@ -363,7 +393,8 @@ func TestParse(t *testing.T) {
 							exp: &expression{
 								kind: literalKind,
 								literal: &token{
-									loc:   location{col: 7, line: 0},
+									loc:   location{line: 0, col: 7, offset: 7},
+									end:   location{line: 0, col: 9, offset: 9},
 									kind:  identifierKind,
 									value: "id",
 								},
@@ -373,7 +404,8 @@ func TestParse(t *testing.T) {
 							exp: &expression{
 								kind: literalKind,
 								literal: &token{
-									loc:   location{col: 11, line: 0},
+									loc:   location{line: 0, col: 11, offset: 11},
+									end:   location{line: 0, col: 15, offset: 15},
 									kind:  identifierKind,
 									value: "name",
 								},
@@ -383,32 +415,76 @@ func TestParse(t *testing.T) {
 					from: &fromItem{
 						table: &token{
 							value: "user",
-							loc:   location{col: 21, line: 0},
+							loc:   location{line: 0, col: 21, offset: 21},
+							end:   location{line: 0, col: 25, offset: 25},
 							kind:  identifierKind,
 						},
 					},
 					where: &expression{
 						binary: &binaryExpression{
 							a: expression{
-								literal: &token{
-									value: "age",
-									loc:   location{line: 0, col: 32},
-									kind:  identifierKind,
+								binary: &binaryExpression{
+									a: expression{
+										literal: &token{
+											value: "age",
+											kind:  identifierKind,
+											loc:   location{line: 0, col: 33, offset: 33},
+											end:   location{line: 0, col: 36, offset: 36},
+										},
+										kind: literalKind,
+									},
+									b: expression{
+										literal: &token{
+											value: "23",
+											kind:  numericKind,
+											loc:   location{line: 0, col: 39, offset: 39},
+											end:   location{line: 0, col: 41, offset: 41},
+										},
+										kind: literalKind,
+									},
+									op: token{
+										value: "=",
+										kind:  symbolKind,
+										loc:   location{line: 0, col: 37, offset: 37},
+										end:   location{line: 0, col: 38, offset: 38},
+									},
 								},
-								kind: literalKind,
+								kind: binaryKind,
 							},
 							b: expression{
-								literal: &token{
-									value: "23",
-									kind:  numericKind,
-									loc:   location{line: 0, col: 38},
+								binary: &binaryExpression{
+									a: expression{
+										literal: &token{
+											value: "age",
+											kind:  identifierKind,
+											loc:   location{line: 0, col: 48, offset: 48},
+											end:   location{line: 0, col: 51, offset: 51},
+										},
+										kind: literalKind,
+									},
+									b: expression{
+										literal: &token{
+											value: "23",
+											kind:  numericKind,
+											loc:   location{line: 0, col: 54, offset: 54},
+											end:   location{line: 0, col: 56, offset: 56},
+										},
+										kind: literalKind,
+									},
+									op: token{
+										value: ">",
+										kind:  symbolKind,
+										loc:   location{line: 0, col: 52, offset: 52},
+										end:   location{line: 0, col: 53, offset: 53},
+									},
 								},
-								kind: literalKind,
+								kind: binaryKind,
 							},
 							op: token{
-								value: "=",
-								kind:  symbolKind,
-								loc:   location{line: 0, col: 36},
+								value: "and",
+								kind:  keywordKind,
+								loc:   location{line: 0, col: 43, offset: 43},
+								end:   location{line: 0, col: 46, offset: 46},
 							},
 						},
 						kind: binaryKind,
@@ -416,7 +492,6 @@ func TestParse(t *testing.T) {
 				},
 			}}},
 		},
-		
 	}
 
 	for _, test := range tests {
@@ -425,3 +500,113 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, test.ast, ast, test.source)
 	}
 }
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		source string
+		err    *ParseError
+	}{
+		{
+			source: "SELECT id FROM;",
+			err:    &ParseError{Line: 0, Column: 14, Offset: 14, End: 15, Token: ";", Msg: "Expected table name after FROM"},
+		},
+		{
+			source: "INSERT INTO users VALUES (1;",
+			err:    &ParseError{Line: 0, Column: 27, Offset: 27, End: 28, Token: ";", Msg: "Expected binary operator"},
+		},
+		{
+			source: "SELECT 1;\nCOPY users TO 'a.csv' WITH (HEADER",
+			err:    &ParseError{Line: 1, Column: 34, Offset: 44, End: 44, Expected: []string{")"}, Msg: "Expected right paren"},
+		},
+		{
+			source: "DROP TABLE users;",
+			err:    &ParseError{Line: 0, Column: 0, Offset: 0, End: 4, Token: "drop", Expected: []string{"SELECT", "INSERT", "CREATE", "COPY", "SET"}, Msg: "Expected statement"},
+		},
+		{
+			source: "SELECT 1 # 2;",
+			err:    &ParseError{Line: 0, Column: 9, Offset: 9, End: 10, Token: "#", Msg: "Unable to lex token after 1"},
+		},
+		{
+			source: `SELECT "" FROM t;`,
+			err:    &ParseError{Line: 0, Column: 7, Offset: 7, End: 8, Token: `"`, Msg: "Zero-length quoted identifier"},
+		},
+		{
+			source: "SELECT .;",
+			err:    &ParseError{Line: 0, Column: 7, Offset: 7, End: 8, Token: ".", Msg: "Invalid number"},
+		},
+		{
+			source: "SELECT 1e;",
+			err:    &ParseError{Line: 0, Column: 7, Offset: 7, End: 9, Token: "1e", Msg: "Invalid number"},
+		},
+		{
+			source: "SELECT 1, 2.5e+ FROM t;",
+			err:    &ParseError{Line: 0, Column: 10, Offset: 10, End: 15, Token: "2.5e+", Msg: "Invalid number"},
+		},
+		{
+			source: "SELECT ?; INSERT INTO users VALUES (?, $1, ?);",
+			err:    &ParseError{Line: 0, Column: 39, Offset: 39, End: 41, Token: "$1", Msg: "Cannot mix ? and $n placeholders in one statement"},
+		},
+		{
+			source: "SELECT $1 = ?;",
+			err:    &ParseError{Line: 0, Column: 12, Offset: 12, End: 13, Token: "?", Msg: "Cannot mix ? and $n placeholders in one statement"},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.source)
+		assert.Equal(t, test.err, err, test.source)
+	}
+
+	_, err := Parse("SELECT id FROM;")
+	assert.Equal(t, "[0,14]: Expected table name after FROM, got: ;", err.Error())
+}
+
+func TestParseRecover(t *testing.T) {
+	source := `SELECT id FROM;
+INSERT INTO users VALUES (1);
+SELECT # FROM users;
+CREATE TABLE t (id INT);
+SELECT 1 SELECT 2;
+COPY users TO 'a.csv';`
+
+	ast, err := ParseRecover(source)
+	assert.Equal(t, 3, len(ast.Statements))
+	assert.Equal(t, InsertKind, ast.Statements[0].Kind)
+	assert.Equal(t, CreateTableKind, ast.Statements[1].Kind)
+	assert.Equal(t, CopyKind, ast.Statements[2].Kind)
+
+	var errs ParseErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.Equal(t, 3, len(errs))
+	assert.Equal(t, "Expected table name after FROM", errs[0].Msg)
+	assert.Equal(t, "Unable to lex token after select", errs[1].Msg)
+	assert.Equal(t, uint(2), errs[1].Line)
+	assert.Equal(t, "Expected binary operator", errs[2].Msg)
+	assert.Equal(t, uint(4), errs[2].Line)
+
+	var first *ParseError
+	assert.ErrorAs(t, err, &first)
+	assert.Equal(t, errs[0], first)
+
+	ast, err = ParseRecover("SELECT 1;")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ast.Statements))
+}
+
+func TestParseUnreservedKeywords(t *testing.T) {
+	ast, err := Parse("CREATE TABLE copy (text TEXT, header INT); SELECT text, header AS delimiter FROM copy WHERE header > 1; SET set = 1;")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ast.Statements))
+
+	create := ast.Statements[0].CreateTableStatement
+	assert.Equal(t, "copy", create.name.value)
+	assert.Equal(t, identifierKind, create.name.kind)
+	assert.Equal(t, "text", (*create.cols)[0].name.value)
+
+	slct := ast.Statements[1].SelectStatement
+	assert.Equal(t, identifierKind, (*slct.item)[0].exp.literal.kind)
+	assert.Equal(t, "delimiter", (*slct.item)[1].as.value)
+
+	_, err = Parse("CREATE TABLE from (id INT);")
+	assert.Equal(t, "Expected table name", err.(*ParseError).Msg)
+}
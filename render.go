@@ -0,0 +1,100 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// errorHint is a short suggestion shown under the marked source.
+func errorHint(err error, token string) string {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		switch {
+		case len(perr.Expected) == 1:
+			return "expected " + perr.Expected[0]
+		case len(perr.Expected) > 1:
+			return "expected one of " + strings.Join(perr.Expected, ", ")
+		case strings.HasPrefix(perr.Msg, "Unable to lex"):
+			return "unexpected character " + perr.Token
+		}
+
+		return ""
+	}
+
+	switch {
+	case errors.Is(err, ErrColumnDoesNotExist):
+		return fmt.Sprintf("no column named %s", token)
+	case errors.Is(err, ErrTableDoesNotExist):
+		return fmt.Sprintf("no table named %s", token)
+	}
+
+	return ""
+}
+
+func renderError(sb *strings.Builder, source string, err error, msg string, line, col, offset, end uint, token string) {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(source), "\n")
+	if line >= uint(len(lines)) {
+		line = uint(len(lines)) - 1
+	}
+	text := lines[line]
+	col = min(col, uint(len(text)))
+
+	fmt.Fprintf(sb, "ERROR: %s\n", msg)
+	fmt.Fprintf(sb, "LINE %d: %s\n", line+1, text)
+
+	// Tabs are kept so the marker lines up with the token
+	prefix := []rune(fmt.Sprintf("LINE %d: ", line+1) + text[:col])
+	for i, r := range prefix {
+		if r != '\t' {
+			prefix[i] = ' '
+		}
+	}
+
+	// Tokens spanning lines are marked up to the end of the first
+	span := source[min(offset, uint(len(source))):min(end, uint(len(source)))]
+	if i := strings.IndexAny(span, "\r\n"); i != -1 {
+		span = span[:i]
+	}
+	width := max(utf8.RuneCountInString(span), 1)
+	fmt.Fprintf(sb, "%s^%s\n", string(prefix), strings.Repeat("~", width-1))
+
+	hint := errorHint(err, token)
+	if hint != "" {
+		fmt.Fprintf(sb, "HINT: %s\n", hint)
+	}
+}
+
+// RenderError formats an error from lexing, parsing or running source.
+// Errors that know their position show the offending line with the
+// token marked and a hint:
+//
+//	ERROR: Column does not exist
+//	LINE 1: SELECT nme FROM users;
+//	               ^~~
+//	HINT: no column named nme
+//
+// Other errors are shown as just their message.
+func RenderError(source string, err error) string {
+	var sb strings.Builder
+
+	var errs ParseErrors
+	var perr *ParseError
+	var serr *SourceError
+
+	switch {
+	case errors.As(err, &errs):
+		for _, e := range errs {
+			renderError(&sb, source, e, e.Msg, e.Line, e.Column, e.Offset, e.End, e.Token)
+		}
+	case errors.As(err, &perr):
+		renderError(&sb, source, perr, perr.Msg, perr.Line, perr.Column, perr.Offset, perr.End, perr.Token)
+	case errors.As(err, &serr):
+		renderError(&sb, source, err, err.Error(), serr.Line, serr.Column, serr.Offset, serr.End, serr.Token)
+	default:
+		fmt.Fprintf(&sb, "ERROR: %s\n", err)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,60 @@
+package gosql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderError(t *testing.T) {
+	source := "SELECT id\nFROM;"
+	_, err := Parse(source)
+	assert.Equal(t, `ERROR: Expected table name after FROM
+LINE 2: FROM;
+            ^
+`, RenderError(source, err))
+
+	source = "SELECT id\r\nFROM 'users';"
+	_, err = Parse(source)
+	assert.Equal(t, `ERROR: Expected table name after FROM
+LINE 2: FROM 'users';
+             ^~~~~~~
+`, RenderError(source, err))
+
+	source = "CREATE TABLE t (id MONEY);"
+	_, err = Parse(source)
+	assert.Equal(t, `ERROR: Expected column type
+LINE 1: CREATE TABLE t (id MONEY);
+                           ^~~~~
+HINT: expected one of INT, TEXT, BOOLEAN, FLOAT, SMALLINT, BIGINT, REAL, DOUBLE PRECISION, NUMERIC, VARCHAR, CHAR, DATE, TIME, TIMESTAMP, TIMESTAMPTZ, INTERVAL, JSON, JSONB, BYTEA
+`, RenderError(source, err))
+
+	mb := newDumpFixture(t)
+	source = "SELECT nme FROM users;"
+	ast, err := Parse(source)
+	assert.Nil(t, err)
+	_, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.Equal(t, `ERROR: Column does not exist
+LINE 1: SELECT nme FROM users;
+               ^~~
+HINT: no column named nme
+`, RenderError(source, err))
+
+	source = "\tINSERT INTO missing VALUES (1);"
+	ast, err = Parse(source)
+	assert.Nil(t, err)
+	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Equal(t, "ERROR: Table does not exist\nLINE 1: \tINSERT INTO missing VALUES (1);\n        \t            ^~~~~~~\nHINT: no table named missing\n", RenderError(source, err))
+
+	source = "INSERT INTO users VALUES (-99999999999, 'x', true);"
+	ast, err = Parse(source)
+	assert.Nil(t, err)
+	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Equal(t, `ERROR: Numeric value out of range
+LINE 1: INSERT INTO users VALUES (-99999999999, 'x', true);
+                                  ^~~~~~~~~~~~
+`, RenderError(source, err))
+
+	assert.Equal(t, "ERROR: boom\n", RenderError(source, errors.New("boom")))
+}
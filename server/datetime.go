@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"gosql"
+)
+
+// The binary formats count from 2000-01-01 rather than 1970-01-01.
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const postgresEpochDays = 10957
+
+var errInvalidDatetime = errors.New("invalid binary date/time")
+
+// encodeTemporal converts a date, time, timestamp or interval cell to
+// its binary format. Dates are days and timestamps microseconds since
+// 2000-01-01, times microseconds since midnight and intervals
+// microseconds, days and months.
+func encodeTemporal(cell gosql.Cell, ct gosql.ColumnType) []byte {
+	switch ct {
+	case gosql.DateType:
+		days := cell.AsTime().Unix()/86400 - postgresEpochDays
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(days)))
+	case gosql.TimeType:
+		return binary.BigEndian.AppendUint64(nil, uint64(cell.AsBigInt()))
+	case gosql.IntervalType:
+		months, days, micros := gosql.IntervalParts(cell)
+		b := binary.BigEndian.AppendUint64(nil, uint64(micros))
+		b = binary.BigEndian.AppendUint32(b, uint32(days))
+		return binary.BigEndian.AppendUint32(b, uint32(months))
+	default:
+		micros := cell.AsTime().Sub(postgresEpoch).Microseconds()
+		return binary.BigEndian.AppendUint64(nil, uint64(micros))
+	}
+}
+
+// decodeTemporal is the inverse of encodeTemporal. Dates and timestamps
+// become a time.Time and times and intervals the text they are read
+// from.
+func decodeTemporal(b []byte, ct gosql.ColumnType) (any, error) {
+	switch ct {
+	case gosql.DateType:
+		if len(b) != 4 {
+			return nil, errInvalidDatetime
+		}
+
+		days := int(int32(binary.BigEndian.Uint32(b)))
+		return postgresEpoch.AddDate(0, 0, days), nil
+
+	case gosql.TimeType:
+		if len(b) != 8 {
+			return nil, errInvalidDatetime
+		}
+
+		micros := int64(binary.BigEndian.Uint64(b))
+		if micros < 0 || micros >= 24*int64(time.Hour/time.Microsecond) {
+			return nil, errInvalidDatetime
+		}
+
+		d := time.Duration(micros) * time.Microsecond
+		return fmt.Sprintf("%02d:%02d:%02d.%06d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, micros%1000000), nil
+
+	case gosql.IntervalType:
+		if len(b) != 16 {
+			return nil, errInvalidDatetime
+		}
+
+		micros := int64(binary.BigEndian.Uint64(b))
+		days := int32(binary.BigEndian.Uint32(b[8:]))
+		months := int32(binary.BigEndian.Uint32(b[12:]))
+		return fmt.Sprintf("%d mons %d days %d microseconds", months, days, micros), nil
+
+	default:
+		if len(b) != 8 {
+			return nil, errInvalidDatetime
+		}
+
+		micros := int64(binary.BigEndian.Uint64(b))
+		return postgresEpoch.Add(time.Duration(micros) * time.Microsecond), nil
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"errors"
+
+	"gosql"
+)
+
+// SQLSTATE codes, see Appendix A of the PostgreSQL documentation.
+const (
+	syntaxErrorState           = "42601"
+	undefinedTableState        = "42P01"
+	undefinedColumnState       = "42703"
+	undefinedObjectState       = "42704"
+	undefinedFunctionState     = "42883"
+	undefinedParameterState    = "42P02"
+	duplicateCursorState       = "42P03"
+	duplicateStatementState    = "42P05"
+	invalidTextState           = "22P02"
+	invalidDatetimeState       = "22007"
+	invalidBinaryState         = "22P03"
+	badCopyFileFormatState     = "22P04"
+	nullValueState             = "22004"
+	numericOutOfRangeState     = "22003"
+	stringTruncationState      = "22001"
+	invalidParameterState      = "22023"
+	invalidCursorState         = "34000"
+	invalidStatementState      = "26000"
+	featureNotSupportedState   = "0A000"
+	cannotCoerceState          = "42846"
+	insufficientPrivilegeState = "42501"
+	dataCorruptedState         = "XX001"
+	internalErrorState         = "XX000"
+	protocolViolationState     = "08P01"
+	invalidPasswordState       = "28P01"
+	queryCanceledState         = "57014"
+)
+
+// sqlState maps the errors returned by gosql to the closest PostgreSQL
+// error code.
+func sqlState(err error) string {
+	var perr *protocolError
+	if errors.As(err, &perr) {
+		return perr.code
+	}
+
+	switch {
+	case errors.Is(err, gosql.ErrTableDoesNotExist):
+		return undefinedTableState
+	case errors.Is(err, gosql.ErrNotSupported):
+		return featureNotSupportedState
+	case errors.Is(err, gosql.ErrColumnDoesNotExist):
+		return undefinedColumnState
+	case errors.Is(err, gosql.ErrInvalidSelectItem),
+		errors.Is(err, gosql.ErrMissingValues):
+		return syntaxErrorState
+	case errors.Is(err, gosql.ErrInvalidDatatype):
+		return undefinedObjectState
+	case errors.Is(err, gosql.ErrInvalidOperands):
+		return undefinedFunctionState
+	case errors.Is(err, gosql.ErrInvalidCopyData):
+		return badCopyFileFormatState
+	case errors.Is(err, gosql.ErrInvalidJSONData):
+		return invalidTextState
+	case errors.Is(err, gosql.ErrParameterCount):
+		return protocolViolationState
+	case errors.Is(err, gosql.ErrInvalidParameter):
+		return invalidParameterState
+	case errors.Is(err, gosql.ErrUnboundParameter):
+		return undefinedParameterState
+	case errors.Is(err, gosql.ErrNumericOutOfRange):
+		return numericOutOfRangeState
+	case errors.Is(err, gosql.ErrValueTooLong):
+		return stringTruncationState
+	case errors.Is(err, gosql.ErrInvalidModifier):
+		return invalidParameterState
+	case errors.Is(err, gosql.ErrInvalidDatetime):
+		return invalidDatetimeState
+	case errors.Is(err, gosql.ErrInvalidUnit):
+		return invalidParameterState
+	case errors.Is(err, gosql.ErrFunctionDoesNotExist):
+		return undefinedFunctionState
+	case errors.Is(err, gosql.ErrNotJSONArray):
+		return invalidParameterState
+	case errors.Is(err, gosql.ErrInvalidBytea):
+		return invalidTextState
+	case errors.Is(err, gosql.ErrInvalidEncoding):
+		return invalidParameterState
+	case errors.Is(err, gosql.ErrInvalidInput):
+		return invalidTextState
+	case errors.Is(err, gosql.ErrCannotCast):
+		return cannotCoerceState
+	case errors.Is(err, gosql.ErrQueryCanceled):
+		return queryCanceledState
+	case errors.Is(err, gosql.ErrUnknownSetting):
+		return undefinedObjectState
+	case errors.Is(err, gosql.ErrInvalidSetting):
+		return invalidParameterState
+	case errors.Is(err, gosql.ErrInvalidDump),
+		errors.Is(err, gosql.ErrUnsupportedDumpVersion):
+		return dataCorruptedState
+	}
+
+	return internalErrorState
+}
+
+func (m *messageWriter) errorResponse(code, message string) {
+	m.begin(errorResponseMessage)
+	m.byte('S')
+	m.string("ERROR")
+	m.byte('V')
+	m.string("ERROR")
+	m.byte('C')
+	m.string(code)
+	m.byte('M')
+	m.string(message)
+	m.byte(0)
+	m.end()
+}
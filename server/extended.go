@@ -0,0 +1,553 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gosql"
+)
+
+var (
+	errMultipleStatements = errors.New("Cannot insert multiple commands into a prepared statement")
+	errNullParameter      = errors.New("NULL parameters are not supported")
+)
+
+type preparedStatement struct {
+	// stmt is nil for an empty query
+	stmt       *gosql.Statement
+	paramTypes []gosql.ColumnType
+	columns    []gosql.ResultColumn
+}
+
+type portal struct {
+	prepared *preparedStatement
+	stmt     *gosql.Statement
+	formats  []int16
+
+	// A SELECT is started by the first Execute and its rows handed out
+	// across as many Executes as the row limit requires.
+	rows gosql.RowIterator
+	// cancel ends the statement timeout of a started SELECT
+	cancel context.CancelFunc
+	done   bool
+}
+
+func (p *portal) close() {
+	if p.rows != nil {
+		p.rows.Close()
+		p.cancel()
+	}
+}
+
+func (c *conn) closePortal(name string) {
+	if p, ok := c.portals[name]; ok {
+		p.close()
+		delete(c.portals, name)
+	}
+}
+
+// protocolError is an error to report with a specific SQLSTATE code.
+type protocolError struct {
+	code    string
+	message string
+}
+
+func (e *protocolError) Error() string {
+	return e.message
+}
+
+func newProtocolError(code, format string, args ...any) *protocolError {
+	return &protocolError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func columnType(oid int32) (gosql.ColumnType, bool) {
+	switch oid {
+	case boolOid:
+		return gosql.BoolType, true
+	case int2Oid:
+		return gosql.SmallIntType, true
+	case int4Oid:
+		return gosql.IntType, true
+	case int8Oid:
+		return gosql.BigIntType, true
+	case textOid, varcharOid, bpcharOid:
+		return gosql.TextType, true
+	case float4Oid:
+		return gosql.RealType, true
+	case float8Oid:
+		return gosql.FloatType, true
+	case numericOid:
+		return gosql.NumericType, true
+	case dateOid:
+		return gosql.DateType, true
+	case timeOid:
+		return gosql.TimeType, true
+	case timestampOid:
+		return gosql.TimestampType, true
+	case timestamptzOid:
+		return gosql.TimestampTzType, true
+	case intervalOid:
+		return gosql.IntervalType, true
+	case jsonOid:
+		return gosql.JsonType, true
+	case jsonbOid:
+		return gosql.JsonbType, true
+	case byteaOid:
+		return gosql.ByteaType, true
+	}
+
+	return 0, false
+}
+
+// formatCodes expands the format codes of a Bind message to one code per
+// value.
+func formatCodes(codes []int16, n int) ([]int16, error) {
+	formats := make([]int16, n)
+
+	switch len(codes) {
+	case 0:
+	case 1:
+		for i := range formats {
+			formats[i] = codes[0]
+		}
+	case n:
+		copy(formats, codes)
+	default:
+		return nil, newProtocolError(protocolViolationState, "Got %d format codes for %d values", len(codes), n)
+	}
+
+	for _, f := range formats {
+		if f != textFormat && f != binaryFormat {
+			return nil, newProtocolError(protocolViolationState, "Unsupported format code %d", f)
+		}
+	}
+
+	return formats, nil
+}
+
+func parseParameter(value []byte, ct gosql.ColumnType, format int16) (any, error) {
+	if format == binaryFormat {
+		switch ct {
+		case gosql.SmallIntType, gosql.IntType, gosql.BigIntType:
+			switch len(value) {
+			case 2:
+				return int32(int16(binary.BigEndian.Uint16(value))), nil
+			case 4:
+				return int32(binary.BigEndian.Uint32(value)), nil
+			case 8:
+				return int64(binary.BigEndian.Uint64(value)), nil
+			}
+		case gosql.RealType, gosql.FloatType:
+			switch len(value) {
+			case 4:
+				return float64(math.Float32frombits(binary.BigEndian.Uint32(value))), nil
+			case 8:
+				return math.Float64frombits(binary.BigEndian.Uint64(value)), nil
+			}
+		case gosql.NumericType:
+			s, err := decodeNumeric(value)
+			if err == nil {
+				return gosql.Numeric(s), nil
+			}
+		case gosql.BoolType:
+			if len(value) == 1 {
+				return value[0] != 0, nil
+			}
+		case gosql.DateType, gosql.TimeType, gosql.TimestampType, gosql.TimestampTzType, gosql.IntervalType:
+			v, err := decodeTemporal(value, ct)
+			if err == nil {
+				return v, nil
+			}
+		case gosql.JsonbType:
+			// Binary jsonb is its text after a version number of 1
+			if len(value) > 0 && value[0] == jsonbVersion {
+				return string(value[1:]), nil
+			}
+		case gosql.ByteaType:
+			// Bound as the hex format so no byte is read as an escape
+			return `\x` + hex.EncodeToString(value), nil
+		default:
+			return string(value), nil
+		}
+
+		return nil, newProtocolError(invalidBinaryState, "Invalid binary parameter of %d bytes", len(value))
+	}
+
+	switch ct {
+	case gosql.SmallIntType, gosql.IntType, gosql.BigIntType:
+		i, err := strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+		if err == nil {
+			return i, nil
+		}
+	case gosql.RealType, gosql.FloatType:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(value)), 64)
+		if err == nil {
+			return f, nil
+		}
+	case gosql.NumericType:
+		return gosql.Numeric(strings.TrimSpace(string(value))), nil
+	case gosql.BoolType:
+		switch strings.ToLower(strings.TrimSpace(string(value))) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+	default:
+		return string(value), nil
+	}
+
+	return nil, newProtocolError(invalidTextState, "Invalid input syntax %q", value)
+}
+
+func (s *Server) extendedQuery(c *conn, typ byte, body []byte) {
+	if typ == syncMessage {
+		c.ignoreUntilSync = false
+		c.closePortal("")
+		c.w.readyForQuery()
+		return
+	}
+
+	if c.ignoreUntilSync || typ == flushMessage {
+		return
+	}
+
+	m := &messageReader{body: body}
+
+	var err error
+	switch typ {
+	case parseMessage:
+		err = s.parse(c, m)
+	case bindMessage:
+		err = s.bind(c, m)
+	case describeMessage:
+		err = s.describe(c, m)
+	case executeMessage:
+		err = s.executePortal(c, m)
+	case closeMessage:
+		err = s.close(c, m)
+	}
+
+	if err == nil && m.err != nil {
+		err = newProtocolError(protocolViolationState, "Invalid message format")
+	}
+
+	if err != nil {
+		var perr *protocolError
+		if errors.As(err, &perr) {
+			c.w.errorResponse(perr.code, perr.message)
+		} else {
+			c.w.errorResponse(sqlState(err), err.Error())
+		}
+
+		c.ignoreUntilSync = true
+	}
+}
+
+func (s *Server) parse(c *conn, m *messageReader) error {
+	name := m.string()
+	query := m.string()
+
+	declared := []int32{}
+	for n := m.int16(); n > 0 && m.err == nil; n-- {
+		declared = append(declared, m.int32())
+	}
+
+	if m.err != nil {
+		return nil
+	}
+
+	if _, ok := c.statements[name]; ok && name != "" {
+		return newProtocolError(duplicateStatementState, "Prepared statement %q already exists", name)
+	}
+
+	ps := &preparedStatement{}
+
+	if strings.TrimSpace(query) != "" {
+		ast, err := gosql.Parse(query)
+		if err != nil {
+			return newProtocolError(syntaxErrorState, "%s", err)
+		}
+
+		if len(ast.Statements) > 1 {
+			return newProtocolError(syntaxErrorState, "%s", errMultipleStatements)
+		}
+
+		if len(ast.Statements) == 1 {
+			ps.stmt = ast.Statements[0]
+
+			// Prepared statements need their types before they run
+			db, ok := s.backend.(gosql.DescribeBackend)
+			if !ok {
+				return fmt.Errorf("%w: prepared statements", gosql.ErrNotSupported)
+			}
+
+			s.mu.Lock()
+			ps.paramTypes, ps.columns, err = db.Describe(ps.stmt)
+			s.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Types given by the client win over inferred ones
+	for i, oid := range declared {
+		if oid == unknownOid {
+			continue
+		}
+
+		ct, ok := columnType(oid)
+		if !ok {
+			return newProtocolError(featureNotSupportedState, "Unsupported parameter type %d", oid)
+		}
+
+		if i < len(ps.paramTypes) {
+			ps.paramTypes[i] = ct
+		} else {
+			ps.paramTypes = append(ps.paramTypes, ct)
+		}
+	}
+
+	c.statements[name] = ps
+
+	c.w.begin(parseCompleteMessage)
+	c.w.end()
+	return nil
+}
+
+func (s *Server) bind(c *conn, m *messageReader) error {
+	portalName := m.string()
+	statementName := m.string()
+
+	paramFormatCodes := []int16{}
+	for n := m.int16(); n > 0 && m.err == nil; n-- {
+		paramFormatCodes = append(paramFormatCodes, m.int16())
+	}
+
+	values := [][]byte{}
+	nulls := []bool{}
+	for n := m.int16(); n > 0 && m.err == nil; n-- {
+		length := m.int32()
+		nulls = append(nulls, length == -1)
+		if length == -1 {
+			values = append(values, nil)
+			continue
+		}
+
+		values = append(values, m.bytes(int(length)))
+	}
+
+	resultFormatCodes := []int16{}
+	for n := m.int16(); n > 0 && m.err == nil; n-- {
+		resultFormatCodes = append(resultFormatCodes, m.int16())
+	}
+
+	if m.err != nil {
+		return nil
+	}
+
+	ps, ok := c.statements[statementName]
+	if !ok {
+		return newProtocolError(invalidStatementState, "Prepared statement %q does not exist", statementName)
+	}
+
+	if _, ok := c.portals[portalName]; ok && portalName != "" {
+		return newProtocolError(duplicateCursorState, "Portal %q already exists", portalName)
+	}
+
+	if len(values) != len(ps.paramTypes) {
+		return newProtocolError(protocolViolationState, "Bind message supplies %d parameters, but prepared statement %q requires %d", len(values), statementName, len(ps.paramTypes))
+	}
+
+	paramFormats, err := formatCodes(paramFormatCodes, len(values))
+	if err != nil {
+		return err
+	}
+
+	params := []any{}
+	for i, value := range values {
+		if nulls[i] {
+			return newProtocolError(nullValueState, "%s", errNullParameter)
+		}
+
+		param, err := parseParameter(value, ps.paramTypes[i], paramFormats[i])
+		if err != nil {
+			return err
+		}
+
+		params = append(params, param)
+	}
+
+	p := &portal{prepared: ps}
+
+	p.formats, err = formatCodes(resultFormatCodes, len(ps.columns))
+	if err != nil {
+		return err
+	}
+
+	if ps.stmt != nil {
+		// Extra parameters declared by the client are not referenced
+		p.stmt, err = ps.stmt.Bind(params[:ps.stmt.NumParameters()])
+		if err != nil {
+			return err
+		}
+	}
+
+	c.closePortal(portalName)
+	c.portals[portalName] = p
+
+	c.w.begin(bindCompleteMessage)
+	c.w.end()
+	return nil
+}
+
+func (s *Server) describe(c *conn, m *messageReader) error {
+	kind := m.bytes(1)
+	name := m.string()
+	if m.err != nil {
+		return nil
+	}
+
+	switch kind[0] {
+	case 'S':
+		ps, ok := c.statements[name]
+		if !ok {
+			return newProtocolError(invalidStatementState, "Prepared statement %q does not exist", name)
+		}
+
+		c.w.begin(parameterDescMessage)
+		c.w.int16(int16(len(ps.paramTypes)))
+		for _, ct := range ps.paramTypes {
+			oid, _ := typeOid(ct)
+			c.w.int32(oid)
+		}
+		c.w.end()
+
+		if ps.stmt == nil || ps.stmt.Kind != gosql.SelectKind {
+			c.w.begin(noDataMessage)
+			c.w.end()
+			return nil
+		}
+
+		c.w.rowDescription(ps.columns, make([]int16, len(ps.columns)))
+
+	case 'P':
+		p, ok := c.portals[name]
+		if !ok {
+			return newProtocolError(invalidCursorState, "Portal %q does not exist", name)
+		}
+
+		if p.stmt == nil || p.stmt.Kind != gosql.SelectKind {
+			c.w.begin(noDataMessage)
+			c.w.end()
+			return nil
+		}
+
+		c.w.rowDescription(p.prepared.columns, p.formats)
+
+	default:
+		return newProtocolError(protocolViolationState, "Invalid Describe kind %q", kind[0])
+	}
+
+	return nil
+}
+
+func (s *Server) executePortal(c *conn, m *messageReader) error {
+	name := m.string()
+	maxRows := m.int32()
+	if m.err != nil {
+		return nil
+	}
+
+	p, ok := c.portals[name]
+	if !ok {
+		return newProtocolError(invalidCursorState, "Portal %q does not exist", name)
+	}
+
+	if p.stmt == nil {
+		c.w.begin(emptyQueryMessage)
+		c.w.end()
+		return nil
+	}
+
+	if p.stmt.Kind != gosql.SelectKind {
+		if p.done {
+			return newProtocolError(invalidCursorState, "Portal %q has already been executed", name)
+		}
+
+		tag, err := s.run(c, p.stmt)
+		if err != nil {
+			return err
+		}
+
+		p.done = true
+		c.w.commandComplete(tag)
+		return nil
+	}
+
+	if p.done {
+		c.w.commandComplete("SELECT 0")
+		return nil
+	}
+
+	if p.rows == nil {
+		ctx, cancel := c.settings.Context(context.Background())
+		rows, err := s.query(ctx, p.stmt.SelectStatement)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		if len(rows.Columns()) != len(p.formats) {
+			rows.Close()
+			cancel()
+			return newProtocolError(featureNotSupportedState, "Cached plan must not change result type")
+		}
+
+		p.rows, p.cancel = rows, cancel
+	}
+
+	n, err := s.sendRows(c, p.rows, p.formats, int(max(maxRows, 0)))
+	if err != nil {
+		return err
+	}
+
+	if maxRows > 0 && n == int(maxRows) {
+		c.w.begin(portalSuspendedMessage)
+		c.w.end()
+		return nil
+	}
+
+	p.close()
+	p.done = true
+	c.w.commandComplete(fmt.Sprintf("SELECT %d", n))
+	return nil
+}
+
+func (s *Server) close(c *conn, m *messageReader) error {
+	kind := m.bytes(1)
+	name := m.string()
+	if m.err != nil {
+		return nil
+	}
+
+	switch kind[0] {
+	case 'S':
+		delete(c.statements, name)
+	case 'P':
+		c.closePortal(name)
+	default:
+		return newProtocolError(protocolViolationState, "Invalid Close kind %q", kind[0])
+	}
+
+	c.w.begin(closeCompleteMessage)
+	c.w.end()
+	return nil
+}
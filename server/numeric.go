@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sign values of the binary numeric format.
+const (
+	numericPositive = 0x0000
+	numericNegative = 0x4000
+)
+
+var errInvalidNumeric = errors.New("invalid binary numeric")
+
+// encodeNumeric converts the decimal text of a numeric value to the
+// binary format: the number of digits, the weight of the first digit,
+// the sign and the display scale, followed by the digits in base 10000.
+func encodeNumeric(s string) []byte {
+	sign := uint16(numericPositive)
+	if strings.HasPrefix(s, "-") {
+		sign = numericNegative
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	scale := len(fraction)
+
+	// Pad both parts to whole groups of four digits around the point
+	whole = strings.Repeat("0", (4-len(whole)%4)%4) + whole
+	fraction += strings.Repeat("0", (4-len(fraction)%4)%4)
+	weight := len(whole)/4 - 1
+
+	digits := []uint16{}
+	all := whole + fraction
+	for i := 0; i < len(all); i += 4 {
+		d := uint16(0)
+		for _, c := range all[i : i+4] {
+			d = d*10 + uint16(c-'0')
+		}
+		digits = append(digits, d)
+	}
+
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+		weight--
+	}
+
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+
+	if len(digits) == 0 {
+		weight, sign = 0, numericPositive
+	}
+
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(digits)))
+	b = binary.BigEndian.AppendUint16(b, uint16(int16(weight)))
+	b = binary.BigEndian.AppendUint16(b, sign)
+	b = binary.BigEndian.AppendUint16(b, uint16(scale))
+	for _, d := range digits {
+		b = binary.BigEndian.AppendUint16(b, d)
+	}
+
+	return b
+}
+
+// decodeNumeric is the inverse of encodeNumeric.
+func decodeNumeric(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", errInvalidNumeric
+	}
+
+	n := int(binary.BigEndian.Uint16(b))
+	weight := int(int16(binary.BigEndian.Uint16(b[2:])))
+	sign := binary.BigEndian.Uint16(b[4:])
+	scale := int(binary.BigEndian.Uint16(b[6:]))
+	if len(b) != 8+2*n || (sign != numericPositive && sign != numericNegative) {
+		return "", errInvalidNumeric
+	}
+
+	digits := make([]int, n)
+	for i := range digits {
+		digits[i] = int(binary.BigEndian.Uint16(b[8+2*i:]))
+		if digits[i] > 9999 {
+			return "", errInvalidNumeric
+		}
+	}
+
+	// digit returns the group of four digits with the given weight
+	digit := func(w int) int {
+		i := weight - w
+		if i < 0 || i >= n {
+			return 0
+		}
+		return digits[i]
+	}
+
+	var sb strings.Builder
+	if sign == numericNegative {
+		sb.WriteByte('-')
+	}
+
+	fmt.Fprintf(&sb, "%d", digit(max(weight, 0)))
+	for w := weight - 1; w >= 0; w-- {
+		fmt.Fprintf(&sb, "%04d", digit(w))
+	}
+
+	if scale > 0 {
+		var fraction strings.Builder
+		for w := -1; fraction.Len() < scale; w-- {
+			fmt.Fprintf(&fraction, "%04d", digit(w))
+		}
+
+		sb.WriteByte('.')
+		sb.WriteString(fraction.String()[:scale])
+	}
+
+	return sb.String(), nil
+}
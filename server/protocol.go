@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Request codes sent in place of a protocol version in the first
+// message of a connection.
+const (
+	protocolVersion   = 196608
+	sslRequestCode    = 80877103
+	gssRequestCode    = 80877104
+	cancelRequestCode = 80877102
+)
+
+// Frontend message types.
+const (
+	queryMessage     = 'Q'
+	terminateMessage = 'X'
+	passwordMessage  = 'p'
+	parseMessage     = 'P'
+	bindMessage      = 'B'
+	describeMessage  = 'D'
+	executeMessage   = 'E'
+	closeMessage     = 'C'
+	syncMessage      = 'S'
+	flushMessage     = 'H'
+)
+
+// Backend message types.
+const (
+	authenticationMessage  = 'R'
+	parameterStatusMessage = 'S'
+	backendKeyDataMessage  = 'K'
+	readyForQueryMessage   = 'Z'
+	rowDescriptionMessage  = 'T'
+	dataRowMessage         = 'D'
+	commandCompleteMessage = 'C'
+	emptyQueryMessage      = 'I'
+	errorResponseMessage   = 'E'
+	parseCompleteMessage   = '1'
+	bindCompleteMessage    = '2'
+	closeCompleteMessage   = '3'
+	parameterDescMessage   = 't'
+	noDataMessage          = 'n'
+	portalSuspendedMessage = 's'
+)
+
+const (
+	authOk                = 0
+	authCleartextPassword = 3
+
+	transactionStatusIdle = 'I'
+
+	// Messages read before the client is authenticated are kept
+	// small, the others are read in full as their bytes arrive.
+	maxMessageLength        = 1 << 30
+	maxStartupMessageLength = 10000
+
+	// maxPreallocated bounds the buffer allocated for a message before
+	// its bytes are seen.
+	maxPreallocated = 64 << 10
+)
+
+// jsonbVersion starts the binary format of jsonb.
+const jsonbVersion = 1
+
+// Type OIDs from pg_type.
+const (
+	unknownOid     = 0
+	boolOid        = 16
+	byteaOid       = 17
+	int8Oid        = 20
+	int2Oid        = 21
+	int4Oid        = 23
+	textOid        = 25
+	jsonOid        = 114
+	float4Oid      = 700
+	float8Oid      = 701
+	bpcharOid      = 1042
+	varcharOid     = 1043
+	dateOid        = 1082
+	timeOid        = 1083
+	timestampOid   = 1114
+	timestamptzOid = 1184
+	intervalOid    = 1186
+	numericOid     = 1700
+	jsonbOid       = 3802
+)
+
+const (
+	textFormat   = 0
+	binaryFormat = 1
+)
+
+var errMessageTooLong = errors.New("message too long")
+
+// readStartupMessage reads the untyped first message of a connection.
+func readStartupMessage(r *bufio.Reader) ([]byte, error) {
+	var length int32
+	err := binary.Read(r, binary.BigEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 8 || length > maxStartupMessageLength {
+		return nil, errMessageTooLong
+	}
+
+	return readBody(r, length-4)
+}
+
+// readMessage reads a typed frontend message of at most maxLength
+// bytes.
+func readMessage(r *bufio.Reader, maxLength int32) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var length int32
+	err = binary.Read(r, binary.BigEndian, &length)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if length < 4 || length > maxLength {
+		return 0, nil, errMessageTooLong
+	}
+
+	body, err := readBody(r, length-4)
+	return typ, body, err
+}
+
+// readBody reads the n bytes of a message body, growing the buffer as
+// they arrive rather than trusting n up front.
+func readBody(r *bufio.Reader, n int32) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, min(int(n), maxPreallocated)))
+	_, err := io.CopyN(buf, r, int64(n))
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return buf.Bytes(), err
+}
+
+// messageReader decodes the fields of a message body.
+type messageReader struct {
+	body []byte
+	err  error
+}
+
+func (m *messageReader) int32() int32 {
+	if len(m.body) < 4 {
+		m.err = io.ErrUnexpectedEOF
+		return 0
+	}
+
+	i := int32(binary.BigEndian.Uint32(m.body))
+	m.body = m.body[4:]
+	return i
+}
+
+func (m *messageReader) int16() int16 {
+	if len(m.body) < 2 {
+		m.err = io.ErrUnexpectedEOF
+		return 0
+	}
+
+	i := int16(binary.BigEndian.Uint16(m.body))
+	m.body = m.body[2:]
+	return i
+}
+
+func (m *messageReader) string() string {
+	for i, c := range m.body {
+		if c == 0 {
+			s := string(m.body[:i])
+			m.body = m.body[i+1:]
+			return s
+		}
+	}
+
+	m.err = io.ErrUnexpectedEOF
+	return ""
+}
+
+func (m *messageReader) bytes(n int) []byte {
+	if n < 0 || len(m.body) < n {
+		m.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	b := m.body[:n]
+	m.body = m.body[n:]
+	return b
+}
+
+// messageWriter builds backend messages, patching in each message
+// length once the message is complete.
+type messageWriter struct {
+	buf   []byte
+	start int
+}
+
+func (m *messageWriter) begin(typ byte) {
+	m.buf = append(m.buf, typ)
+	m.start = len(m.buf)
+	m.buf = append(m.buf, 0, 0, 0, 0)
+}
+
+func (m *messageWriter) int32(i int32) {
+	m.buf = binary.BigEndian.AppendUint32(m.buf, uint32(i))
+}
+
+func (m *messageWriter) int16(i int16) {
+	m.buf = binary.BigEndian.AppendUint16(m.buf, uint16(i))
+}
+
+func (m *messageWriter) byte(b byte) {
+	m.buf = append(m.buf, b)
+}
+
+func (m *messageWriter) string(s string) {
+	m.buf = append(m.buf, s...)
+	m.buf = append(m.buf, 0)
+}
+
+func (m *messageWriter) bytes(b []byte) {
+	m.buf = append(m.buf, b...)
+}
+
+func (m *messageWriter) end() {
+	binary.BigEndian.PutUint32(m.buf[m.start:], uint32(len(m.buf)-m.start))
+}
+
+func (m *messageWriter) flush(w io.Writer) error {
+	_, err := w.Write(m.buf)
+	m.buf = m.buf[:0]
+	return err
+}
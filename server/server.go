@@ -0,0 +1,482 @@
+// Package server exposes a gosql backend over the PostgreSQL v3
+// frontend/backend protocol so that psql and PostgreSQL drivers can
+// talk to it.
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gosql"
+)
+
+type Server struct {
+	// Password enables cleartext password authentication when set.
+	// Otherwise every connection is trusted.
+	Password string
+
+	// AllowFileCopy lets clients run COPY, which reads and writes files
+	// on the server with its permissions. It is off by default.
+	AllowFileCopy bool
+
+	backend gosql.Backend
+	// Backends are not safe for concurrent use so statements from all
+	// connections are serialized.
+	mu      sync.Mutex
+	nextPid atomic.Int32
+}
+
+func New(backend gosql.Backend) *Server {
+	return &Server{backend: backend}
+}
+
+// ListenAndServe listens on a "tcp" or "unix" address and serves
+// connections until the listener fails.
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			err := s.handle(nc)
+			if err != nil && err != io.EOF {
+				log.Printf("Connection from %s closed: %s", nc.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+type conn struct {
+	net.Conn
+	r      *bufio.Reader
+	w      messageWriter
+	params map[string]string
+
+	settings gosql.Settings
+
+	statements map[string]*preparedStatement
+	portals    map[string]*portal
+	// After an error in the extended query protocol every message up
+	// to the next Sync is ignored.
+	ignoreUntilSync bool
+}
+
+func (c *conn) flush() error {
+	return c.w.flush(c.Conn)
+}
+
+func (s *Server) handle(nc net.Conn) error {
+	defer nc.Close()
+
+	c := &conn{
+		Conn:       nc,
+		r:          bufio.NewReader(nc),
+		params:     map[string]string{},
+		statements: map[string]*preparedStatement{},
+		portals:    map[string]*portal{},
+	}
+
+	ok, err := s.startup(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	for {
+		typ, body, err := readMessage(c.r, maxMessageLength)
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case queryMessage:
+			m := messageReader{body: body}
+			query := m.string()
+			if m.err != nil {
+				return m.err
+			}
+
+			s.simpleQuery(c, query)
+
+		case terminateMessage:
+			return nil
+
+		case parseMessage, bindMessage, describeMessage, executeMessage, closeMessage:
+			// Responses are only sent once the client asks for them
+			s.extendedQuery(c, typ, body)
+			continue
+
+		case syncMessage, flushMessage:
+			s.extendedQuery(c, typ, body)
+
+		default:
+			c.w.errorResponse(protocolViolationState, fmt.Sprintf("Unsupported message type %q", typ))
+			c.w.readyForQuery()
+		}
+
+		err = c.flush()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startup negotiates the protocol and authenticates the client. It
+// returns false when the connection should be closed.
+func (s *Server) startup(c *conn) (bool, error) {
+	for {
+		body, err := readStartupMessage(c.r)
+		if err != nil {
+			return false, err
+		}
+
+		m := messageReader{body: body}
+		code := m.int32()
+
+		switch code {
+		case sslRequestCode, gssRequestCode:
+			// Encryption is not supported, the client may continue
+			// in plaintext.
+			_, err = c.Write([]byte{'N'})
+			if err != nil {
+				return false, err
+			}
+			continue
+
+		case cancelRequestCode:
+			return false, nil
+
+		case protocolVersion:
+			for {
+				key := m.string()
+				if key == "" || m.err != nil {
+					break
+				}
+
+				c.params[key] = m.string()
+			}
+
+		default:
+			c.w.errorResponse(protocolViolationState, fmt.Sprintf("Unsupported frontend protocol %d.%d", code>>16, code&0xffff))
+			return false, c.flush()
+		}
+
+		if m.err != nil {
+			return false, m.err
+		}
+
+		break
+	}
+
+	if s.Password != "" {
+		c.w.begin(authenticationMessage)
+		c.w.int32(authCleartextPassword)
+		c.w.end()
+		err := c.flush()
+		if err != nil {
+			return false, err
+		}
+
+		typ, body, err := readMessage(c.r, maxStartupMessageLength)
+		if err != nil {
+			return false, err
+		}
+
+		m := messageReader{body: body}
+		password := m.string()
+		if typ != passwordMessage || m.err != nil || password != s.Password {
+			c.w.errorResponse(invalidPasswordState, fmt.Sprintf("Password authentication failed for user %q", c.params["user"]))
+			return false, c.flush()
+		}
+	}
+
+	c.w.begin(authenticationMessage)
+	c.w.int32(authOk)
+	c.w.end()
+
+	for _, param := range [][2]string{
+		{"server_version", "14.0"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+	} {
+		c.w.begin(parameterStatusMessage)
+		c.w.string(param[0])
+		c.w.string(param[1])
+		c.w.end()
+	}
+
+	var secret [4]byte
+	_, err := rand.Read(secret[:])
+	if err != nil {
+		return false, err
+	}
+
+	c.w.begin(backendKeyDataMessage)
+	c.w.int32(s.nextPid.Add(1))
+	c.w.int32(int32(binary.BigEndian.Uint32(secret[:])))
+	c.w.end()
+
+	c.w.readyForQuery()
+	return true, c.flush()
+}
+
+func (m *messageWriter) readyForQuery() {
+	m.begin(readyForQueryMessage)
+	m.byte(transactionStatusIdle)
+	m.end()
+}
+
+func (s *Server) simpleQuery(c *conn, query string) {
+	defer c.w.readyForQuery()
+
+	if strings.TrimSpace(query) == "" {
+		c.w.begin(emptyQueryMessage)
+		c.w.end()
+		return
+	}
+
+	ast, err := gosql.Parse(query)
+	if err != nil {
+		c.w.errorResponse(syntaxErrorState, err.Error())
+		return
+	}
+
+	for _, stmt := range ast.Statements {
+		err = s.execute(c, stmt)
+		if err != nil {
+			c.w.errorResponse(sqlState(err), err.Error())
+			return
+		}
+	}
+}
+
+// run executes a statement other than SELECT and returns its command
+// tag.
+func (s *Server) run(c *conn, stmt *gosql.Statement) (string, error) {
+	if stmt.Kind == gosql.SetKind {
+		return "SET", c.settings.Set(stmt.SetStatement)
+	}
+
+	ctx, cancel := c.settings.Context(context.Background())
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backend := gosql.WithContext(s.backend)
+	switch stmt.Kind {
+	case gosql.CreateTableKind:
+		return "CREATE TABLE", backend.CreateTableContext(ctx, stmt.CreateTableStatement)
+
+	case gosql.InsertKind:
+		return "INSERT 0 1", backend.InsertContext(ctx, stmt.InsertStatement)
+
+	case gosql.CopyKind:
+		if !s.AllowFileCopy {
+			return "", newProtocolError(insufficientPrivilegeState, "COPY to or from a file is not allowed on this server")
+		}
+
+		cb, ok := s.backend.(gosql.CopyBackend)
+		if !ok {
+			return "", fmt.Errorf("%w: COPY", gosql.ErrNotSupported)
+		}
+
+		n, err := cb.CopyContext(ctx, stmt.CopyStatement)
+		return fmt.Sprintf("COPY %d", n), err
+	}
+
+	return "", nil
+}
+
+func (s *Server) query(ctx context.Context, slct *gosql.SelectStatement) (gosql.RowIterator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return gosql.WithContext(s.backend).QueryContext(ctx, slct)
+}
+
+// sendRows writes up to limit rows, or all of them if limit is 0, as
+// DataRow messages and returns how many were sent. Rows are pulled under
+// the lock since the backend may read its tables while producing them.
+func (s *Server) sendRows(c *conn, rows gosql.RowIterator, formats []int16, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	columns := rows.Columns()
+	n := 0
+	for (limit == 0 || n < limit) && rows.Next() {
+		c.w.dataRow(columns, formats, rows.Row())
+		n++
+	}
+
+	return n, rows.Err()
+}
+
+func (s *Server) execute(c *conn, stmt *gosql.Statement) error {
+	if stmt.Kind != gosql.SelectKind {
+		tag, err := s.run(c, stmt)
+		if err != nil {
+			return err
+		}
+
+		c.w.commandComplete(tag)
+		return nil
+	}
+
+	ctx, cancel := c.settings.Context(context.Background())
+	defer cancel()
+
+	rows, err := s.query(ctx, stmt.SelectStatement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	formats := make([]int16, len(rows.Columns()))
+	c.w.rowDescription(rows.Columns(), formats)
+
+	n, err := s.sendRows(c, rows, formats, 0)
+	if err != nil {
+		return err
+	}
+
+	c.w.commandComplete(fmt.Sprintf("SELECT %d", n))
+	return nil
+}
+
+func (m *messageWriter) commandComplete(tag string) {
+	m.begin(commandCompleteMessage)
+	m.string(tag)
+	m.end()
+}
+
+func typeOid(ct gosql.ColumnType) (int32, int16) {
+	switch ct {
+	case gosql.SmallIntType:
+		return int2Oid, 2
+	case gosql.IntType:
+		return int4Oid, 4
+	case gosql.BigIntType:
+		return int8Oid, 8
+	case gosql.BoolType:
+		return boolOid, 1
+	case gosql.RealType:
+		return float4Oid, 4
+	case gosql.FloatType:
+		return float8Oid, 8
+	case gosql.NumericType:
+		return numericOid, -1
+	case gosql.VarcharType:
+		return varcharOid, -1
+	case gosql.CharType:
+		return bpcharOid, -1
+	case gosql.DateType:
+		return dateOid, 4
+	case gosql.TimeType:
+		return timeOid, 8
+	case gosql.TimestampType:
+		return timestampOid, 8
+	case gosql.TimestampTzType:
+		return timestamptzOid, 8
+	case gosql.IntervalType:
+		return intervalOid, 16
+	case gosql.JsonType:
+		return jsonOid, -1
+	case gosql.JsonbType:
+		return jsonbOid, -1
+	case gosql.ByteaType:
+		return byteaOid, -1
+	default:
+		return textOid, -1
+	}
+}
+
+func (m *messageWriter) rowDescription(columns []gosql.ResultColumn, formats []int16) {
+	m.begin(rowDescriptionMessage)
+	m.int16(int16(len(columns)))
+
+	for i, col := range columns {
+		oid, size := typeOid(col.Type)
+
+		m.string(col.Name)
+		// No table OID or attribute number
+		m.int32(0)
+		m.int16(0)
+		m.int32(oid)
+		m.int16(size)
+		// No type modifier
+		m.int32(-1)
+		m.int16(formats[i])
+	}
+
+	m.end()
+}
+
+func formatCell(cell gosql.Cell, ct gosql.ColumnType, format int16) []byte {
+	if format == binaryFormat {
+		switch ct {
+		case gosql.SmallIntType:
+			return binary.BigEndian.AppendUint16(nil, uint16(cell.AsInt()))
+		case gosql.IntType:
+			return binary.BigEndian.AppendUint32(nil, uint32(cell.AsInt()))
+		case gosql.BigIntType:
+			return binary.BigEndian.AppendUint64(nil, uint64(cell.AsBigInt()))
+		case gosql.RealType:
+			return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(cell.AsFloat())))
+		case gosql.FloatType:
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(cell.AsFloat()))
+		case gosql.NumericType:
+			return encodeNumeric(cell.AsText())
+		case gosql.DateType, gosql.TimeType, gosql.TimestampType, gosql.TimestampTzType, gosql.IntervalType:
+			return encodeTemporal(cell, ct)
+		case gosql.JsonbType:
+			return append([]byte{jsonbVersion}, cell.AsText()...)
+		case gosql.ByteaType:
+			return []byte(cell.AsText())
+		case gosql.BoolType:
+			if cell.AsBool() {
+				return []byte{1}
+			}
+			return []byte{0}
+		}
+	}
+
+	return []byte(gosql.FormatCell(cell, ct))
+}
+
+func (m *messageWriter) dataRow(columns []gosql.ResultColumn, formats []int16, row []gosql.Cell) {
+	m.begin(dataRowMessage)
+	m.int16(int16(len(row)))
+
+	for i, cell := range row {
+		value := formatCell(cell, columns[i].Type, formats[i])
+		m.int32(int32(len(value)))
+		m.bytes(value)
+	}
+
+	m.end()
+}
@@ -0,0 +1,384 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"gosql"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClient struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+type testMessage struct {
+	typ  byte
+	body []byte
+}
+
+func newTestClient(t *testing.T, s *Server) *testClient {
+	client, srv := net.Pipe()
+	go s.handle(srv)
+	t.Cleanup(func() { client.Close() })
+
+	return &testClient{t: t, nc: client, r: bufio.NewReader(client)}
+}
+
+func (c *testClient) startup(params ...string) {
+	body := binary.BigEndian.AppendUint32(nil, protocolVersion)
+	for _, p := range params {
+		body = append(body, p...)
+		body = append(body, 0)
+	}
+	body = append(body, 0)
+
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))
+	_, err := c.nc.Write(append(msg, body...))
+	assert.Nil(c.t, err)
+}
+
+func (c *testClient) send(typ byte, body []byte) {
+	msg := binary.BigEndian.AppendUint32([]byte{typ}, uint32(len(body)+4))
+	_, err := c.nc.Write(append(msg, body...))
+	assert.Nil(c.t, err)
+}
+
+func (c *testClient) query(q string) {
+	c.send(queryMessage, append([]byte(q), 0))
+}
+
+// receive reads messages until ReadyForQuery or an error terminating
+// the connection.
+func (c *testClient) receive() []testMessage {
+	msgs := []testMessage{}
+	for {
+		typ, body, err := readMessage(c.r, maxMessageLength)
+		if err != nil {
+			return msgs
+		}
+
+		msgs = append(msgs, testMessage{typ, body})
+		if typ == readyForQueryMessage {
+			return msgs
+		}
+	}
+}
+
+func messageTypes(msgs []testMessage) string {
+	s := ""
+	for _, m := range msgs {
+		s += string(m.typ)
+	}
+
+	return s
+}
+
+func errorCode(m testMessage) string {
+	r := messageReader{body: m.body}
+	for {
+		field := r.bytes(1)
+		if r.err != nil || field[0] == 0 {
+			return ""
+		}
+
+		value := r.string()
+		if field[0] == 'C' {
+			return value
+		}
+	}
+}
+
+func TestSimpleQuery(t *testing.T) {
+	c := newTestClient(t, New(gosql.NewMemoryBackend()))
+	c.startup("user", "phil")
+	assert.Equal(t, "RSSSSSSKZ", messageTypes(c.receive()))
+
+	c.query("CREATE TABLE users (id INT, name TEXT, active BOOLEAN); INSERT INTO users VALUES (1, 'Phil', true);")
+	msgs := c.receive()
+	assert.Equal(t, "CCZ", messageTypes(msgs))
+	assert.Equal(t, "INSERT 0 1\x00", string(msgs[1].body))
+
+	c.query("SELECT id, name, active FROM users;")
+	msgs = c.receive()
+	assert.Equal(t, "TDCZ", messageTypes(msgs))
+
+	desc := messageReader{body: msgs[0].body}
+	assert.Equal(t, int16(3), desc.int16())
+	oids := []int32{}
+	for i := 0; i < 3; i++ {
+		desc.string()
+		desc.int32()
+		desc.int16()
+		oids = append(oids, desc.int32())
+		desc.bytes(8)
+	}
+	assert.Equal(t, []int32{int4Oid, textOid, boolOid}, oids)
+
+	row := messageReader{body: msgs[1].body}
+	values := []string{}
+	for n := row.int16(); n > 0; n-- {
+		values = append(values, string(row.bytes(int(row.int32()))))
+	}
+	assert.Equal(t, []string{"1", "Phil", "t"}, values)
+	assert.Equal(t, "SELECT 1\x00", string(msgs[2].body))
+
+	c.query("SELECT id FROM missing;")
+	msgs = c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, undefinedTableState, errorCode(msgs[0]))
+
+	c.query("SELEC 1;")
+	msgs = c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, syntaxErrorState, errorCode(msgs[0]))
+
+	c.query(" ")
+	assert.Equal(t, "IZ", messageTypes(c.receive()))
+
+	c.query("SET statement_timeout = '5s';")
+	msgs = c.receive()
+	assert.Equal(t, "CZ", messageTypes(msgs))
+	assert.Equal(t, "SET\x00", string(msgs[0].body))
+
+	c.query("SET statement_timeout = 'soon';")
+	msgs = c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, invalidParameterState, errorCode(msgs[0]))
+}
+
+func TestFileCopy(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "users.csv")
+	s := New(gosql.NewMemoryBackend())
+
+	c := newTestClient(t, s)
+	c.startup("user", "phil")
+	c.receive()
+
+	c.query("CREATE TABLE users (id INT); INSERT INTO users VALUES (1);")
+	c.receive()
+
+	c.query("COPY users TO '" + out + "';")
+	msgs := c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, insufficientPrivilegeState, errorCode(msgs[0]))
+	assert.NoFileExists(t, out)
+
+	s.AllowFileCopy = true
+	c.query("COPY users TO '" + out + "';")
+	msgs = c.receive()
+	assert.Equal(t, "CZ", messageTypes(msgs))
+	assert.Equal(t, "COPY 1\x00", string(msgs[0].body))
+	assert.FileExists(t, out)
+}
+
+// baseBackend has only the methods of gosql.Backend.
+type baseBackend struct {
+	mb *gosql.MemoryBackend
+}
+
+func (b baseBackend) CreateTable(crt *gosql.CreateTableStatement) error {
+	return b.mb.CreateTable(crt)
+}
+
+func (b baseBackend) Insert(inst *gosql.InsertStatement) error {
+	return b.mb.Insert(inst)
+}
+
+func (b baseBackend) Select(slct *gosql.SelectStatement) (*gosql.Results, error) {
+	return b.mb.Select(slct)
+}
+
+func TestBaseBackend(t *testing.T) {
+	s := New(baseBackend{gosql.NewMemoryBackend()})
+	s.AllowFileCopy = true
+
+	c := newTestClient(t, s)
+	c.startup("user", "phil")
+	assert.Equal(t, "RSSSSSSKZ", messageTypes(c.receive()))
+
+	c.query("CREATE TABLE users (id INT); INSERT INTO users VALUES (1); SELECT id FROM users;")
+	assert.Equal(t, "CCTDCZ", messageTypes(c.receive()))
+
+	c.query("COPY users TO '/tmp/users.csv';")
+	msgs := c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, featureNotSupportedState, errorCode(msgs[0]))
+
+	c.sendExtended(parseMessage, "", "SELECT id FROM users;", int16(0))
+	c.send(syncMessage, nil)
+	msgs = c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, featureNotSupportedState, errorCode(msgs[0]))
+}
+
+func TestCleartextPassword(t *testing.T) {
+	s := New(gosql.NewMemoryBackend())
+	s.Password = "secret"
+
+	c := newTestClient(t, s)
+	c.startup("user", "phil")
+	typ, _, err := readMessage(c.r, maxMessageLength)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(authenticationMessage), typ)
+	c.send(passwordMessage, []byte("secret\x00"))
+	assert.Equal(t, "RSSSSSSKZ", messageTypes(c.receive()))
+
+	c = newTestClient(t, s)
+	c.startup("user", "phil")
+	_, _, err = readMessage(c.r, maxMessageLength)
+	assert.Nil(t, err)
+	c.send(passwordMessage, []byte("wrong\x00"))
+	msgs := c.receive()
+	assert.Equal(t, "E", messageTypes(msgs))
+	assert.Equal(t, invalidPasswordState, errorCode(msgs[0]))
+
+	// A password message claiming to be large closes the connection
+	// before its body is read
+	c = newTestClient(t, s)
+	c.startup("user", "phil")
+	_, _, err = readMessage(c.r, maxMessageLength)
+	assert.Nil(t, err)
+	_, err = c.nc.Write(binary.BigEndian.AppendUint32([]byte{passwordMessage}, 1<<20))
+	assert.Nil(t, err)
+	assert.Equal(t, "", messageTypes(c.receive()))
+}
+
+func TestReadMessage(t *testing.T) {
+	msg := binary.BigEndian.AppendUint32([]byte{queryMessage}, 1<<29)
+	_, _, err := readMessage(bufio.NewReader(bytes.NewReader(append(msg, "SELECT"...))), maxMessageLength)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, _, err = readMessage(bufio.NewReader(bytes.NewReader(msg)), maxStartupMessageLength)
+	assert.Equal(t, errMessageTooLong, err)
+
+	msg = binary.BigEndian.AppendUint32([]byte{queryMessage}, 7)
+	typ, body, err := readMessage(bufio.NewReader(bytes.NewReader(append(msg, "abc"...))), maxStartupMessageLength)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(queryMessage), typ)
+	assert.Equal(t, "abc", string(body))
+}
+
+func (c *testClient) sendExtended(typ byte, fields ...any) {
+	var w messageWriter
+	for _, f := range fields {
+		switch v := f.(type) {
+		case string:
+			w.string(v)
+		case int16:
+			w.int16(v)
+		case int32:
+			w.int32(v)
+		case []byte:
+			w.bytes(v)
+		}
+	}
+
+	c.send(typ, w.buf)
+}
+
+func TestExtendedQuery(t *testing.T) {
+	c := newTestClient(t, New(gosql.NewMemoryBackend()))
+	c.startup("user", "phil")
+	c.receive()
+
+	c.query("CREATE TABLE users (id INT, name TEXT); INSERT INTO users VALUES (1, 'Phil'); INSERT INTO users VALUES (2, 'Kate'); INSERT INTO users VALUES (3, 'Ann');")
+	c.receive()
+
+	c.sendExtended(parseMessage, "byid", "SELECT id, name FROM users WHERE id > $1;", int16(0))
+	c.sendExtended(describeMessage, []byte{'S'}, "byid")
+	// Text parameter, binary results
+	c.sendExtended(bindMessage, "", "byid", int16(0), int16(1), int32(1), []byte("1"), int16(1), int16(binaryFormat))
+	c.sendExtended(executeMessage, "", int32(1))
+	c.sendExtended(executeMessage, "", int32(0))
+	c.sendExtended(syncMessage)
+
+	msgs := c.receive()
+	assert.Equal(t, "1tT2DsDCZ", messageTypes(msgs))
+
+	params := messageReader{body: msgs[1].body}
+	assert.Equal(t, int16(1), params.int16())
+	assert.Equal(t, int32(int4Oid), params.int32())
+
+	row := messageReader{body: msgs[4].body}
+	assert.Equal(t, int16(2), row.int16())
+	assert.Equal(t, int32(4), row.int32())
+	assert.Equal(t, []byte{0, 0, 0, 2}, row.bytes(4))
+	assert.Equal(t, "SELECT 1\x00", string(msgs[7].body))
+
+	// Binary parameter inferred from the column type
+	c.sendExtended(parseMessage, "", "INSERT INTO users VALUES ($1, $2);", int16(0))
+	c.sendExtended(bindMessage, "", "", int16(2), int16(binaryFormat), int16(textFormat), int16(2), int32(4), []byte{0, 0, 0, 4}, int32(3), []byte("Bob"), int16(0))
+	c.sendExtended(executeMessage, "", int32(0))
+	c.sendExtended(syncMessage)
+	msgs = c.receive()
+	assert.Equal(t, "12CZ", messageTypes(msgs))
+	assert.Equal(t, "INSERT 0 1\x00", string(msgs[2].body))
+
+	c.query("SELECT name FROM users WHERE id = 4;")
+	msgs = c.receive()
+	assert.Equal(t, "TDCZ", messageTypes(msgs))
+
+	// Errors skip everything up to Sync
+	c.sendExtended(bindMessage, "", "missing", int16(0), int16(0), int16(0))
+	c.sendExtended(executeMessage, "", int32(0))
+	c.sendExtended(syncMessage)
+	msgs = c.receive()
+	assert.Equal(t, "EZ", messageTypes(msgs))
+	assert.Equal(t, invalidStatementState, errorCode(msgs[0]))
+
+	c.sendExtended(parseMessage, "", "SELECT id FROM users WHERE id = $1;", int16(0))
+	c.sendExtended(bindMessage, "", "", int16(0), int16(1), int32(3), []byte("abc"), int16(0))
+	c.sendExtended(syncMessage)
+	msgs = c.receive()
+	assert.Equal(t, "1EZ", messageTypes(msgs))
+	assert.Equal(t, invalidTextState, errorCode(msgs[1]))
+}
+
+func TestNumericBinary(t *testing.T) {
+	for _, s := range []string{"0", "12.50", "-0.0001", "123456789.123456789", "10000", "-5"} {
+		value, err := decodeNumeric(encodeNumeric(s))
+		assert.Nil(t, err)
+		assert.Equal(t, s, value)
+	}
+
+	// 12.5 is one group before the point and one after, with two
+	// digits shown
+	assert.Equal(t, []byte{0, 2, 0, 0, 0, 0, 0, 2, 0, 12, 19, 136}, encodeNumeric("12.50"))
+
+	_, err := decodeNumeric([]byte{0, 1, 0, 0, 0, 0, 0, 0})
+	assert.NotNil(t, err)
+}
+
+func TestTemporalBinary(t *testing.T) {
+	ast, err := gosql.Parse("SELECT DATE '2000-01-02', TIME '00:00:01', TIMESTAMPTZ '1999-12-31 23:59:59+00', INTERVAL '1 mon 2 days 3 microseconds';")
+	assert.Nil(t, err)
+
+	results, err := gosql.NewMemoryBackend().Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+
+	row := results.Rows[0]
+	assert.Equal(t, []byte{0, 0, 0, 1}, encodeTemporal(row[0], gosql.DateType))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 15, 66, 64}, encodeTemporal(row[1], gosql.TimeType))
+	assert.Equal(t, []byte{255, 255, 255, 255, 255, 240, 189, 192}, encodeTemporal(row[2], gosql.TimestampTzType))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 2, 0, 0, 0, 1}, encodeTemporal(row[3], gosql.IntervalType))
+
+	value, err := decodeTemporal(encodeTemporal(row[2], gosql.TimestampTzType), gosql.TimestampTzType)
+	assert.Nil(t, err)
+	assert.Equal(t, row[2].AsTime(), value)
+
+	value, err = decodeTemporal(encodeTemporal(row[3], gosql.IntervalType), gosql.IntervalType)
+	assert.Nil(t, err)
+	assert.Equal(t, "1 mons 2 days 3 microseconds", value)
+
+	_, err = decodeTemporal([]byte{0, 0, 1}, gosql.DateType)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,105 @@
+package gosql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings are the session settings changed with SET.
+type Settings struct {
+	// StatementTimeout cancels statements that run for longer. Zero
+	// means no timeout.
+	StatementTimeout time.Duration
+}
+
+var timeoutUnits = map[string]time.Duration{
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// parseTimeout reads a duration the way PostgreSQL does: a number
+// followed by an optional unit, milliseconds by default. Fractions are
+// rounded to the nearest millisecond.
+func parseTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	number := strings.TrimRightFunc(value, func(r rune) bool {
+		return r >= 'a' && r <= 'z'
+	})
+
+	unit := time.Millisecond
+	if suffix := value[len(number):]; suffix != "" {
+		var ok bool
+		unit, ok = timeoutUnits[suffix]
+		if !ok {
+			return 0, ErrInvalidSetting
+		}
+	}
+
+	// ParseFloat also takes signs, hex and NaN, which are not durations
+	number = strings.TrimSpace(number)
+	if number == "" || strings.ContainsAny(number, "+-xXnN") {
+		return 0, ErrInvalidSetting
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n > math.MaxUint32 {
+		return 0, ErrInvalidSetting
+	}
+
+	return time.Duration(n * float64(unit)).Round(time.Millisecond), nil
+}
+
+// Set applies a SET statement. The value DEFAULT restores the initial
+// value of a setting.
+func (s *Settings) Set(set *SetStatement) error {
+	name := set.name.value
+	value := set.value.value
+	isDefault := set.value.kind == identifierKind && !set.value.quoted && value == "default"
+
+	switch name {
+	case "statement_timeout":
+		if isDefault {
+			s.StatementTimeout = 0
+			return nil
+		}
+
+		timeout, err := parseTimeout(value)
+		if err != nil {
+			return fmt.Errorf("%w %s: %q", err, name, value)
+		}
+
+		s.StatementTimeout = timeout
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownSetting, name)
+}
+
+// Context returns the context a statement runs with, limited by the
+// statement timeout.
+func (s *Settings) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.StatementTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.StatementTimeout)
+}
+
+// checkContext returns ErrQueryCanceled, along with the reason, once ctx
+// is done.
+func checkContext(ctx context.Context) error {
+	err := ctx.Err()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrQueryCanceled, err)
+	}
+
+	return nil
+}
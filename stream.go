@@ -0,0 +1,133 @@
+package gosql
+
+import (
+	"bufio"
+	"io"
+)
+
+// Parser parses statements one at a time from a reader, only holding
+// the text of the statement being parsed in memory. Positions in errors
+// and tokens are relative to the start of the reader.
+type Parser struct {
+	r *bufio.Reader
+	// start is the location of the next statement
+	start location
+	buf   []byte
+}
+
+// NewParser returns a Parser reading statements from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r)}
+}
+
+const (
+	scanCode = iota
+	scanString
+	scanQuoted
+	scanLineComment
+	scanBlockComment
+)
+
+// readStatement reads up to and including the next semicolon that is
+// not in a string, quoted identifier or comment. At the end of the
+// reader it returns what is left along with io.EOF.
+func (sp *Parser) readStatement() (string, error) {
+	sp.buf = sp.buf[:0]
+
+	state := scanCode
+	depth := 0
+	var prev byte
+	for {
+		c, err := sp.r.ReadByte()
+		if err != nil {
+			return string(sp.buf), err
+		}
+		sp.buf = append(sp.buf, c)
+
+		switch state {
+		case scanCode:
+			switch {
+			case c == ';':
+				return string(sp.buf), nil
+			case c == '\'':
+				state = scanString
+			case c == '"':
+				state = scanQuoted
+			case prev == '-' && c == '-':
+				state = scanLineComment
+			case prev == '/' && c == '*':
+				state = scanBlockComment
+				depth = 1
+				c = 0
+			}
+
+		case scanString, scanQuoted:
+			// Doubled quotes leave and enter again
+			if (state == scanString && c == '\'') || (state == scanQuoted && c == '"') {
+				state = scanCode
+				c = 0
+			}
+
+		case scanLineComment:
+			if c == '\n' || c == '\r' {
+				state = scanCode
+			}
+
+		case scanBlockComment:
+			switch {
+			case prev == '/' && c == '*':
+				depth++
+				c = 0
+			case prev == '*' && c == '/':
+				depth--
+				c = 0
+				if depth == 0 {
+					state = scanCode
+				}
+			}
+		}
+
+		// Characters that completed a pair cannot start another
+		prev = c
+	}
+}
+
+// Next returns the next statement, or io.EOF after the last one. A
+// syntax error only skips the statement it is in, so Next can be called
+// again to carry on after it.
+func (sp *Parser) Next() (*Statement, error) {
+	for {
+		source, err := sp.readStatement()
+		if err != nil && (err != io.EOF || source == "") {
+			return nil, err
+		}
+
+		start := sp.start
+		sp.start = location{}.advance(source, uint(len(source))).shift(start)
+
+		tokens, errs := lexTokens(source, false, false)
+		if len(errs) > 0 {
+			return nil, errs[0].shift(start)
+		}
+
+		// Skip empty statements like a trailing comment
+		semicolonToken := tokenFromSymbol(semicolonSymbol)
+		if len(tokens) == 0 || (len(tokens) == 1 && tokens[0].equals(&semicolonToken)) {
+			continue
+		}
+
+		p := newParser(source, tokens)
+		p.eof = p.eof.shift(start)
+		for _, t := range tokens {
+			t.loc = t.loc.shift(start)
+			t.end = t.end.shift(start)
+		}
+
+		ast, err := p.parseStatements(false, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.Statements[0], nil
+	}
+}
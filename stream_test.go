@@ -0,0 +1,64 @@
+package gosql
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParserNext(t *testing.T) {
+	source := `CREATE TABLE "a;b" (id INT); -- first; still a comment
+INSERT INTO "a;b" VALUES (1);;
+/* a ; /* nested ; */ comment */ SELECT 'x;''y' FROM "a;b";
+SELECT FROM;
+SELECT id FROM "a;b"; -- trailing`
+
+	p := NewParser(iotest.OneByteReader(strings.NewReader(source)))
+
+	stmt, err := p.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, CreateTableKind, stmt.Kind)
+	assert.Equal(t, "a;b", stmt.CreateTableStatement.name.value)
+
+	stmt, err = p.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, InsertKind, stmt.Kind)
+	assert.Equal(t, location{line: 1, col: 12, offset: 67}, stmt.InsertStatement.table.loc)
+
+	stmt, err = p.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, SelectKind, stmt.Kind)
+	lit := (*stmt.SelectStatement.item)[0].exp.literal
+	assert.Equal(t, "x;'y", lit.value)
+	assert.Equal(t, location{line: 2, col: 40, offset: 126}, lit.loc)
+
+	_, err = p.Next()
+	assert.Equal(t, &ParseError{Line: 3, Column: 11, Offset: 157, End: 158, Token: ";", Msg: "Expected table name after FROM"}, err)
+
+	stmt, err = p.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, SelectKind, stmt.Kind)
+
+	_, err = p.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParserNextErrors(t *testing.T) {
+	p := NewParser(strings.NewReader("SELECT 1;\nSELECT #;\nSELECT 2"))
+
+	_, err := p.Next()
+	assert.Nil(t, err)
+
+	_, err = p.Next()
+	assert.Equal(t, &ParseError{Line: 1, Column: 7, Offset: 17, End: 18, Token: "#", Msg: "Unable to lex token after select"}, err)
+
+	// The last statement is missing its semicolon
+	_, err = p.Next()
+	assert.Equal(t, &ParseError{Line: 2, Column: 8, Offset: 28, End: 28, Msg: "Expected binary operator"}, err)
+
+	_, err = p.Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,312 @@
+package gosql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// structField is an exported struct field and the column it maps to,
+// given by a `gosql:"col"` tag or else the field name. Fields tagged
+// `gosql:"-"` are skipped.
+type structField struct {
+	index  int
+	column string
+	tagged bool
+}
+
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag, tagged := f.Tag.Lookup("gosql")
+		if tag == "-" {
+			continue
+		}
+
+		if !tagged || tag == "" {
+			fields = append(fields, structField{index: i, column: f.Name})
+			continue
+		}
+
+		fields = append(fields, structField{index: i, column: tag, tagged: true})
+	}
+
+	return fields
+}
+
+// fieldForColumn prefers a field tagged with the column name over one
+// whose name matches it case-insensitively.
+func fieldForColumn(fields []structField, column string) (structField, bool) {
+	for _, f := range fields {
+		if f.tagged && f.column == column {
+			return f, true
+		}
+	}
+
+	for _, f := range fields {
+		if !f.tagged && strings.EqualFold(f.column, column) {
+			return f, true
+		}
+	}
+
+	return structField{}, false
+}
+
+// ScanStructs reads the remaining rows into dest, a pointer to a slice
+// of structs or of struct pointers, appending one element per row. Every
+// column must map to a field that Rows.Scan could scan it into, or to
+// one of a named type of the same kind.
+func ScanStructs(rows RowIterator, dest any) error {
+	defer rows.Close()
+
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Pointer || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: expected pointer to slice, got %T", ErrInvalidScan, dest)
+	}
+	slice = slice.Elem()
+
+	elemType := slice.Type().Elem()
+	isPointer := elemType.Kind() == reflect.Pointer
+	structType := elemType
+	if isPointer {
+		structType = elemType.Elem()
+	}
+
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: expected slice of structs, got %T", ErrInvalidScan, dest)
+	}
+
+	fields := structFields(structType)
+	columns := rows.Columns()
+
+	indexes := []int{}
+	for _, col := range columns {
+		f, ok := fieldForColumn(fields, col.Name)
+		if !ok {
+			return fmt.Errorf("%w: no field for column %s", ErrInvalidScan, col.Name)
+		}
+
+		indexes = append(indexes, f.index)
+	}
+
+	for rows.Next() {
+		elem := reflect.New(structType)
+		for i, cell := range rows.Row() {
+			field := elem.Elem().Field(indexes[i])
+			err := scanField(cell, columns[i].Type, field)
+			if err != nil {
+				return fmt.Errorf("%w, column %s", err, columns[i].Name)
+			}
+		}
+
+		if !isPointer {
+			elem = elem.Elem()
+		}
+
+		slice.Set(reflect.Append(slice, elem))
+	}
+
+	return rows.Err()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// basicTypes are the unnamed types of the kinds fieldColumnType maps.
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+// scanField scans a cell into a struct field. Fields of a named type
+// such as `type ID int64` are scanned as their kind and converted, so
+// that what InsertSQL writes can be read back.
+func scanField(cell Cell, ct ColumnType, field reflect.Value) error {
+	basic, ok := basicTypes[field.Kind()]
+	if !ok || field.Type() == basic {
+		return scanCell(cell, ct, field.Addr().Interface())
+	}
+
+	v := reflect.New(basic)
+	err := scanCell(cell, ct, v.Interface())
+	if err != nil {
+		return err
+	}
+
+	field.Set(v.Elem().Convert(field.Type()))
+	return nil
+}
+
+func fieldColumnType(t reflect.Type) (ColumnType, bool) {
+	if t == timeType {
+		return TimestampTzType, true
+	}
+
+	switch t.Kind() {
+	case reflect.Int16:
+		return SmallIntType, true
+	case reflect.Int32:
+		return IntType, true
+	case reflect.Int, reflect.Uint, reflect.Int64:
+		return BigIntType, true
+	case reflect.String:
+		return TextType, true
+	case reflect.Bool:
+		return BoolType, true
+	case reflect.Float32:
+		return RealType, true
+	case reflect.Float64:
+		return FloatType, true
+	}
+
+	return 0, false
+}
+
+func structType(v any) (reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: expected struct, got %T", ErrInvalidDatatype, v)
+	}
+
+	return t, nil
+}
+
+// tableColumns returns the fields of a struct in column order. Column
+// names are the tags or the lowercased field names.
+func tableColumns(t reflect.Type) ([]structField, []ColumnType, error) {
+	fields := structFields(t)
+	types := []ColumnType{}
+
+	for i, f := range fields {
+		field := t.Field(f.index)
+		ct, ok := fieldColumnType(field.Type)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: field %s has unsupported type %s", ErrInvalidDatatype, field.Name, field.Type)
+		}
+
+		if !f.tagged {
+			fields[i].column = strings.ToLower(f.column)
+		}
+
+		types = append(types, ct)
+	}
+
+	return fields, types, nil
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for a table holding
+// values of the struct type of v. int16, int32, int64, string, bool,
+// float32 and float64 fields become SMALLINT, INT, BIGINT, TEXT,
+// BOOLEAN, REAL and FLOAT columns, and int and uint ones BIGINT too.
+func CreateTableSQL(table string, v any) (string, error) {
+	t, err := structType(v)
+	if err != nil {
+		return "", err
+	}
+
+	fields, types, err := tableColumns(t)
+	if err != nil {
+		return "", err
+	}
+
+	cols := []string{}
+	for i, f := range fields {
+		cols = append(cols, quoteIdentifier(f.column)+" "+columnTypeName(types[i], typeModifier{}))
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s);", quoteIdentifier(table), strings.Join(cols, ", ")), nil
+}
+
+// InsertSQL returns one INSERT statement per struct in v, which may be a
+// struct, a pointer to one or a slice of either.
+func InsertSQL(table string, v any) (string, error) {
+	t, err := structType(v)
+	if err != nil {
+		return "", err
+	}
+
+	fields, types, err := tableColumns(t)
+	if err != nil {
+		return "", err
+	}
+
+	values := []reflect.Value{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Slice {
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, reflect.Indirect(rv.Index(i)))
+		}
+	} else {
+		values = append(values, rv)
+	}
+
+	var sb strings.Builder
+	for _, value := range values {
+		if !value.IsValid() {
+			return "", fmt.Errorf("%w: nil %s", ErrInvalidDatatype, t)
+		}
+
+		literals := []string{}
+		for i, f := range fields {
+			field := value.Field(f.index)
+			switch types[i] {
+			case SmallIntType, IntType, BigIntType:
+				if field.Kind() == reflect.Uint {
+					if field.Uint() > math.MaxInt64 {
+						return "", fmt.Errorf("%w: field %s", ErrNumericOutOfRange, t.Field(f.index).Name)
+					}
+					literals = append(literals, fmt.Sprintf("%d", field.Uint()))
+				} else {
+					literals = append(literals, fmt.Sprintf("%d", field.Int()))
+				}
+			case RealType, FloatType:
+				// There is no literal for NaN or infinity
+				if math.IsNaN(field.Float()) || math.IsInf(field.Float(), 0) {
+					return "", fmt.Errorf("%w: field %s is %v", ErrInvalidDatatype, t.Field(f.index).Name, field.Float())
+				}
+
+				if types[i] == RealType {
+					literals = append(literals, formatFloat(field.Float(), 32))
+				} else {
+					literals = append(literals, floatLiteral(field.Float()))
+				}
+			case BoolType:
+				if field.Bool() {
+					literals = append(literals, string(trueKeyword))
+				} else {
+					literals = append(literals, string(falseKeyword))
+				}
+			case TimestampTzType:
+				cell := timestampCell(field.Interface().(time.Time))
+				literals = append(literals, quoteString(formatTemporal(cell, TimestampTzType)))
+			default:
+				literals = append(literals, quoteString(field.String()))
+			}
+		}
+
+		fmt.Fprintf(&sb, "INSERT INTO %s VALUES (%s);\n", quoteIdentifier(table), strings.Join(literals, ", "))
+	}
+
+	return sb.String(), nil
+}
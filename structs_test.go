@@ -0,0 +1,128 @@
+package gosql
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	testID     int64
+	testStatus string
+)
+
+type testUser struct {
+	ID       int32 `gosql:"id"`
+	FullName string
+	Active   bool
+	Note     string `gosql:"-"`
+	secret   string
+}
+
+func TestStructs(t *testing.T) {
+	ddl, err := CreateTableSQL("users", testUser{})
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE TABLE users (id int, fullname text, active boolean);", ddl)
+
+	users := []testUser{{ID: 1, FullName: "Phil", Active: true}, {ID: 2, FullName: "O'Brien"}}
+	inserts, err := InsertSQL("users", users)
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO users VALUES (1, 'Phil', true);\nINSERT INTO users VALUES (2, 'O''Brien', false);\n", inserts)
+
+	_, err = CreateTableSQL("bad", struct{ N complex128 }{})
+	assert.ErrorIs(t, err, ErrInvalidDatatype)
+
+	_, err = InsertSQL("users", []*testUser{nil})
+	assert.ErrorIs(t, err, ErrInvalidDatatype)
+
+	db := NewDB(NewMemoryBackend())
+	_, err = db.Exec(ddl + inserts)
+	assert.Nil(t, err)
+
+	rows, err := db.Query("SELECT id, fullname, active FROM users;")
+	assert.Nil(t, err)
+
+	scanned := []testUser{}
+	assert.Nil(t, ScanStructs(rows, &scanned))
+	assert.Equal(t, users, scanned)
+
+	rows, err = db.Query("SELECT id AS Id FROM users;")
+	assert.Nil(t, err)
+
+	ids := []*struct{ Id int }{}
+	assert.Nil(t, ScanStructs(rows, &ids))
+	assert.Equal(t, 2, len(ids))
+	assert.Equal(t, 2, ids[1].Id)
+
+	rows, err = db.Query("SELECT id, fullname FROM users;")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ScanStructs(rows, &[]struct{ ID int32 }{}), ErrInvalidScan)
+
+	rows, err = db.Query("SELECT fullname FROM users;")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ScanStructs(rows, &[]struct{ FullName int32 }{}), ErrInvalidScan)
+
+	type counter struct {
+		N     int
+		Count uint
+	}
+	ddl, err = CreateTableSQL("counters", counter{})
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE TABLE counters (n bigint, count bigint);", ddl)
+
+	inserts, err = InsertSQL("counters", counter{N: -1, Count: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO counters VALUES (-1, 2);\n", inserts)
+
+	_, err = InsertSQL("counters", counter{Count: math.MaxUint64})
+	assert.ErrorIs(t, err, ErrNumericOutOfRange)
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, err = InsertSQL("points", struct{ X float64 }{f})
+		assert.ErrorIs(t, err, ErrInvalidDatatype, f)
+
+		_, err = InsertSQL("points", struct{ X float32 }{float32(f)})
+		assert.ErrorIs(t, err, ErrInvalidDatatype, f)
+	}
+
+	db = NewDB(NewMemoryBackend())
+	_, err = db.Exec(ddl + inserts)
+	assert.Nil(t, err)
+
+	rows, err = db.Query("SELECT n, count FROM counters;")
+	assert.Nil(t, err)
+
+	counters := []counter{}
+	assert.Nil(t, ScanStructs(rows, &counters))
+	assert.Equal(t, []counter{{N: -1, Count: 2}}, counters)
+
+	rows, err = db.Query("SELECT n AS count FROM counters;")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ScanStructs(rows, &counters), ErrInvalidScan)
+
+	// Named types read back what InsertSQL wrote
+	type order struct {
+		ID     testID
+		Status testStatus
+	}
+	orders := []order{{ID: 1 << 40, Status: "paid"}}
+	ddl, err = CreateTableSQL("orders", order{})
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE TABLE orders (id bigint, status text);", ddl)
+	inserts, err = InsertSQL("orders", orders)
+	assert.Nil(t, err)
+	_, err = db.Exec(ddl + inserts)
+	assert.Nil(t, err)
+
+	rows, err = db.Query("SELECT id, status FROM orders;")
+	assert.Nil(t, err)
+
+	scannedOrders := []order{}
+	assert.Nil(t, ScanStructs(rows, &scannedOrders))
+	assert.Equal(t, orders, scannedOrders)
+
+	rows, err = db.Query("SELECT status AS id FROM orders;")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ScanStructs(rows, &scannedOrders), ErrInvalidScan)
+}
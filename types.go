@@ -0,0 +1,660 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// columnTypeOf returns the column type a type name stands for.
+func columnTypeOf(name string) (ColumnType, bool) {
+	switch name {
+	case "int":
+		return IntType, true
+	case "text":
+		return TextType, true
+	case "boolean":
+		return BoolType, true
+	case "float", "double precision":
+		return FloatType, true
+	case "smallint":
+		return SmallIntType, true
+	case "bigint":
+		return BigIntType, true
+	case "real":
+		return RealType, true
+	case "numeric", "decimal":
+		return NumericType, true
+	case "varchar", "character varying":
+		return VarcharType, true
+	case "char", "character":
+		return CharType, true
+	case "date":
+		return DateType, true
+	case "time":
+		return TimeType, true
+	case "timestamp":
+		return TimestampType, true
+	case "timestamptz", "timestamp with time zone":
+		return TimestampTzType, true
+	case "interval":
+		return IntervalType, true
+	case "json":
+		return JsonType, true
+	case "jsonb":
+		return JsonbType, true
+	case "bytea":
+		return ByteaType, true
+	}
+
+	return 0, false
+}
+
+// typeModifier holds the (n) of varchar(n) and char(n) and the (p,s) of
+// numeric(p,s). A zero length or precision means there is no limit.
+type typeModifier struct {
+	length    uint
+	precision uint
+	scale     uint
+}
+
+// typeModifierOf checks the modifiers given to a column type. varchar
+// and char take a length, char defaulting to 1, and numeric a precision
+// of up to 1000 digits and a scale.
+func typeModifierOf(ct ColumnType, modifiers []*token) (typeModifier, error) {
+	values := []uint{}
+	for _, m := range modifiers {
+		n, err := strconv.ParseUint(m.value, 10, 32)
+		if err != nil || n == 0 && (ct != NumericType || len(values) == 0) {
+			return typeModifier{}, newSourceError(ErrInvalidModifier, m)
+		}
+
+		values = append(values, uint(n))
+	}
+
+	mod := typeModifier{}
+	switch {
+	case len(values) == 0:
+		if ct == CharType {
+			mod.length = 1
+		}
+
+	case (ct == VarcharType || ct == CharType) && len(values) == 1:
+		mod.length = values[0]
+
+	case ct == NumericType && len(values) <= 2:
+		mod.precision = values[0]
+		if len(values) == 2 {
+			mod.scale = values[1]
+		}
+
+		if mod.precision > 1000 {
+			return typeModifier{}, newSourceError(ErrInvalidModifier, modifiers[0])
+		}
+
+		if mod.scale > mod.precision {
+			return typeModifier{}, newSourceError(ErrInvalidModifier, modifiers[1])
+		}
+
+	default:
+		return typeModifier{}, newSourceError(ErrInvalidModifier, modifiers[0])
+	}
+
+	return mod, nil
+}
+
+func intCell(i int32) MemoryCell {
+	return binary.BigEndian.AppendUint32(nil, uint32(i))
+}
+
+func floatCell(f float64) MemoryCell {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(f))
+}
+
+func realCell(f float32) MemoryCell {
+	return binary.BigEndian.AppendUint32(nil, math.Float32bits(f))
+}
+
+// integerCell encodes i in the width of the integer type ct, which it
+// must fit in.
+func integerCell(i int64, ct ColumnType) MemoryCell {
+	switch ct {
+	case SmallIntType:
+		return binary.BigEndian.AppendUint16(nil, uint16(i))
+	case BigIntType:
+		return binary.BigEndian.AppendUint64(nil, uint64(i))
+	default:
+		return intCell(int32(i))
+	}
+}
+
+// decimalCell stores a numeric value as its decimal text rounded to
+// scale digits after the point.
+func decimalCell(r *big.Rat, scale int) MemoryCell {
+	s := r.FloatString(scale)
+	if strings.Trim(s, "-0.") == "" {
+		s = strings.TrimPrefix(s, "-")
+	}
+
+	return MemoryCell(s)
+}
+
+func isIntegerType(ct ColumnType) bool {
+	return ct == SmallIntType || ct == IntType || ct == BigIntType
+}
+
+func isNumericType(ct ColumnType) bool {
+	return isIntegerType(ct) || ct == NumericType || ct == RealType || ct == FloatType
+}
+
+func isTextType(ct ColumnType) bool {
+	return ct == TextType || ct == VarcharType || ct == CharType
+}
+
+// numericRank orders the numeric types so that an operation on two of
+// them gives the higher ranked one.
+func numericRank(ct ColumnType) int {
+	switch ct {
+	case SmallIntType:
+		return 1
+	case IntType:
+		return 2
+	case BigIntType:
+		return 3
+	case NumericType:
+		return 4
+	case RealType:
+		return 5
+	case FloatType:
+		return 6
+	}
+
+	return 0
+}
+
+func widerNumericType(a, b ColumnType) ColumnType {
+	if numericRank(b) > numericRank(a) {
+		return b
+	}
+
+	return a
+}
+
+func integerRange(ct ColumnType) (int64, int64) {
+	switch ct {
+	case SmallIntType:
+		return math.MinInt16, math.MaxInt16
+	case BigIntType:
+		return math.MinInt64, math.MaxInt64
+	default:
+		return math.MinInt32, math.MaxInt32
+	}
+}
+
+func fitsInteger(i int64, ct ColumnType) bool {
+	lo, hi := integerRange(ct)
+	return i >= lo && i <= hi
+}
+
+// isDecimalLiteral tells literals with a point or exponent, which are
+// numeric, from integer ones.
+func isDecimalLiteral(value string) bool {
+	return !isHexLiteral(value) && strings.ContainsAny(value, ".eE")
+}
+
+func isHexLiteral(value string) bool {
+	value = strings.TrimPrefix(value, "-")
+	return len(value) > 2 && value[0] == '0' && (value[1] == 'x' || value[1] == 'X')
+}
+
+// numericToMemoryCell converts a numeric literal to a cell. As in
+// PostgreSQL, integers are an int if they fit, else a bigint, else a
+// numeric, and literals with a point or exponent are numeric. Values no
+// type can hold are an ErrNumericOutOfRange.
+func numericToMemoryCell(value string) (MemoryCell, ColumnType, error) {
+	if isDecimalLiteral(value) {
+		r, scale, ok := parseDecimal(value)
+		if !ok {
+			return nil, 0, ErrNumericOutOfRange
+		}
+
+		return decimalCell(r, scale), NumericType, nil
+	}
+
+	digits, base := value, 10
+	if isHexLiteral(value) {
+		digits = strings.TrimPrefix(value, "-")[2:]
+		if strings.HasPrefix(value, "-") {
+			digits = "-" + digits
+		}
+		base = 16
+	}
+
+	i, err := strconv.ParseInt(digits, base, 64)
+	if err == nil {
+		if fitsInteger(i, IntType) {
+			return intCell(int32(i)), IntType, nil
+		}
+
+		return integerCell(i, BigIntType), BigIntType, nil
+	}
+
+	if base == 16 {
+		return nil, 0, ErrNumericOutOfRange
+	}
+
+	r, _ := new(big.Rat).SetString(digits)
+	return decimalCell(r, 0), NumericType, nil
+}
+
+// parseDecimal parses a decimal number such as -12.50 or 1.5e3 and
+// returns its value and the digits after the point it has.
+func parseDecimal(s string) (*big.Rat, int, bool) {
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		// Exponents are limited like PostgreSQL's so that the value
+		// stays a reasonable size
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > 131072 || e < -16383 {
+			return nil, 0, false
+		}
+
+		mantissa, exponent = s[:i], e
+	}
+
+	digits := strings.TrimLeft(mantissa, "+-")
+	if len(mantissa)-len(digits) > 1 {
+		return nil, 0, false
+	}
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return nil, 0, false
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return r, max(len(fraction)-exponent, 0), true
+}
+
+// decimalValue returns the value of a numeric cell and its scale.
+func decimalValue(mc MemoryCell) (*big.Rat, int) {
+	r, scale, ok := parseDecimal(mc.AsText())
+	if !ok {
+		return new(big.Rat), 0
+	}
+
+	return r, scale
+}
+
+// fitDecimal rounds a numeric value to the scale of mod, or keeps scale
+// digits if it has no precision, and checks that it has no more digits
+// than the precision allows.
+func fitDecimal(r *big.Rat, scale int, mod typeModifier) (MemoryCell, error) {
+	if mod.precision > 0 {
+		scale = int(mod.scale)
+	}
+
+	mc := decimalCell(r, scale)
+	if mod.precision == 0 {
+		return mc, nil
+	}
+
+	whole, _, _ := strings.Cut(strings.TrimPrefix(mc.AsText(), "-"), ".")
+	if len(strings.TrimLeft(whole, "0")) > int(mod.precision-mod.scale) {
+		return nil, ErrNumericOutOfRange
+	}
+
+	return mc, nil
+}
+
+// parseNumeric converts the text of a number, as found in CSV or sent
+// by a client, to a cell of the numeric type ct.
+func parseNumeric(value string, ct ColumnType) (MemoryCell, error) {
+	value = strings.TrimSpace(value)
+
+	switch {
+	case isIntegerType(ct):
+		lo, hi := integerRange(ct)
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || i < lo || i > hi {
+			return nil, ErrInvalidDatatype
+		}
+
+		return integerCell(i, ct), nil
+
+	case ct == NumericType:
+		r, scale, ok := parseDecimal(value)
+		if !ok {
+			return nil, ErrInvalidDatatype
+		}
+
+		return decimalCell(r, scale), nil
+
+	case ct == RealType:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, ErrInvalidDatatype
+		}
+
+		return realCell(float32(f)), nil
+
+	default:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, ErrInvalidDatatype
+		}
+
+		return floatCell(f), nil
+	}
+}
+
+// numericValue returns the value of a numeric cell as a float.
+func numericValue(mc MemoryCell, ct ColumnType) float64 {
+	switch {
+	case isIntegerType(ct):
+		return float64(mc.AsBigInt())
+	case ct == NumericType:
+		r, _ := decimalValue(mc)
+		f, _ := r.Float64()
+		return f
+	default:
+		return mc.AsFloat()
+	}
+}
+
+// exactValue returns the value of an integer or numeric cell and its
+// scale.
+func exactValue(mc MemoryCell, ct ColumnType) (*big.Rat, int) {
+	if isIntegerType(ct) {
+		return new(big.Rat).SetInt64(mc.AsBigInt()), 0
+	}
+
+	return decimalValue(mc)
+}
+
+// compareNumeric compares two numeric cells, returning -1, 0 or 1.
+// Integers and numerics are compared exactly and anything involving a
+// float as floats.
+func compareNumeric(l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) int {
+	switch ct := widerNumericType(lt, rt); {
+	case isIntegerType(ct):
+		a, b := l.AsBigInt(), r.AsBigInt()
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+
+	case ct == NumericType:
+		a, _ := exactValue(l, lt)
+		b, _ := exactValue(r, rt)
+		return a.Cmp(b)
+
+	default:
+		a, b := numericValue(l, lt), numericValue(r, rt)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+}
+
+// arithmetic applies op to two numeric cells. The result has the wider
+// of the two types and is an ErrNumericOutOfRange if it overflows it.
+func arithmetic(op symbol, l MemoryCell, lt ColumnType, r MemoryCell, rt ColumnType) (MemoryCell, ColumnType, error) {
+	ct := widerNumericType(lt, rt)
+
+	switch {
+	case isIntegerType(ct):
+		a, b := l.AsBigInt(), r.AsBigInt()
+		result := a + b
+		overflow := (a^result)&(b^result) < 0
+		if op == minusSymbol {
+			result = a - b
+			overflow = (a^b)&(a^result) < 0
+		}
+
+		if overflow || !fitsInteger(result, ct) {
+			return nil, 0, ErrNumericOutOfRange
+		}
+
+		return integerCell(result, ct), ct, nil
+
+	case ct == NumericType:
+		a, as := exactValue(l, lt)
+		b, bs := exactValue(r, rt)
+		result := new(big.Rat).Add(a, b)
+		if op == minusSymbol {
+			result.Sub(a, b)
+		}
+
+		return decimalCell(result, max(as, bs)), ct, nil
+	}
+
+	a, b := numericValue(l, lt), numericValue(r, rt)
+	result := a + b
+	if op == minusSymbol {
+		result = a - b
+	}
+
+	if ct == RealType {
+		f := float32(result)
+		if math.IsInf(float64(f), 0) {
+			return nil, 0, ErrNumericOutOfRange
+		}
+
+		return realCell(f), ct, nil
+	}
+
+	if math.IsInf(result, 0) {
+		return nil, 0, ErrNumericOutOfRange
+	}
+
+	return floatCell(result), ct, nil
+}
+
+// convertNumeric converts a numeric cell to the numeric column type to,
+// rounding to the nearest value it can hold. numeric values are fitted
+// to the precision and scale of mod.
+func convertNumeric(mc MemoryCell, from, to ColumnType, mod typeModifier) (MemoryCell, error) {
+	switch {
+	case from == to && to != NumericType:
+		return mc, nil
+
+	case isIntegerType(to):
+		var i int64
+		switch {
+		case isIntegerType(from):
+			i = mc.AsBigInt()
+		case from == NumericType:
+			r, _ := decimalValue(mc)
+			n, err := strconv.ParseInt(r.FloatString(0), 10, 64)
+			if err != nil {
+				return nil, ErrNumericOutOfRange
+			}
+			i = n
+		default:
+			f := math.RoundToEven(mc.AsFloat())
+			if !(f >= math.MinInt64 && f < math.MaxInt64) {
+				return nil, ErrNumericOutOfRange
+			}
+			i = int64(f)
+		}
+
+		if !fitsInteger(i, to) {
+			return nil, ErrNumericOutOfRange
+		}
+
+		return integerCell(i, to), nil
+
+	case to == NumericType:
+		if isIntegerType(from) || from == NumericType {
+			r, scale := exactValue(mc, from)
+			return fitDecimal(r, scale, mod)
+		}
+
+		// Floats keep the digits they are printed with
+		bitSize := 64
+		if from == RealType {
+			bitSize = 32
+		}
+
+		f := mc.AsFloat()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrNumericOutOfRange
+		}
+
+		r, scale, _ := parseDecimal(strconv.FormatFloat(f, 'f', -1, bitSize))
+		return fitDecimal(r, scale, mod)
+
+	case to == RealType:
+		f := float32(numericValue(mc, from))
+		if math.IsInf(float64(f), 0) {
+			return nil, ErrNumericOutOfRange
+		}
+
+		return realCell(f), nil
+	}
+
+	f := numericValue(mc, from)
+	if math.IsInf(f, 0) {
+		return nil, ErrNumericOutOfRange
+	}
+
+	return floatCell(f), nil
+}
+
+// textValue returns the text of a text, varchar or char cell. The
+// padding of char values is not significant and is dropped.
+func textValue(mc MemoryCell, ct ColumnType) string {
+	if ct == CharType {
+		return strings.TrimRight(mc.AsText(), " ")
+	}
+
+	return mc.AsText()
+}
+
+// fitText checks that s fits in a varchar or char column and pads char
+// values to their length. Like PostgreSQL, text that is too long only
+// because of trailing spaces is cut to the length instead.
+func fitText(s string, ct ColumnType, mod typeModifier) (MemoryCell, error) {
+	if ct == TextType || mod.length == 0 {
+		return MemoryCell(s), nil
+	}
+
+	n := utf8.RuneCountInString(s)
+	if n > int(mod.length) {
+		cut := 0
+		for i := range s {
+			if cut == int(mod.length) {
+				if strings.TrimRight(s[i:], " ") != "" {
+					return nil, ErrValueTooLong
+				}
+
+				s = s[:i]
+				break
+			}
+			cut++
+		}
+		n = int(mod.length)
+	}
+
+	if ct == CharType {
+		s += strings.Repeat(" ", int(mod.length)-n)
+	}
+
+	return MemoryCell(s), nil
+}
+
+// storeCell converts a value of type ct for storing in column i,
+// enforcing the length of varchar and char columns and the precision
+// and scale of numeric ones.
+func (t *table) storeCell(i int, mc MemoryCell, ct ColumnType) (MemoryCell, error) {
+	return convertCell(mc, ct, t.columnTypes[i], t.modifier(i))
+}
+
+// convertCell converts a value of type from to the type to, fitting it
+// to the modifier of the type. Text is read as a date, time, timestamp,
+// interval, JSON or bytea, and dates and timestamps convert to each
+// other.
+func convertCell(mc MemoryCell, from, to ColumnType, mod typeModifier) (MemoryCell, error) {
+	switch {
+	case isNumericType(from) && isNumericType(to):
+		return convertNumeric(mc, from, to, mod)
+	case isTextType(from) && isTextType(to):
+		return fitText(textValue(mc, from), to, mod)
+	case isTextType(from) && isTemporalType(to):
+		return parseTemporal(textValue(mc, from), to)
+	case isTextType(from) && isJSONType(to):
+		return parseJSON(textValue(mc, from), to)
+	case isTextType(from) && to == ByteaType:
+		return parseBytea(textValue(mc, from))
+	case from == to:
+		return mc, nil
+	case isJSONType(from) && isJSONType(to):
+		return parseJSON(mc.AsText(), to)
+	case isInstantType(from) && to == DateType:
+		return dateCell(daysOf(mc.AsTime())), nil
+	case isInstantType(from) && isInstantType(to):
+		return timestampCell(mc.AsTime()), nil
+	}
+
+	return nil, ErrInvalidDatatype
+}
+
+// castCell converts a value like convertCell, also making the
+// conversions only an explicit cast makes: text to and from any type,
+// and booleans to and from integers. Text too long for a varchar or
+// char is cut to its length.
+func castCell(mc MemoryCell, from, to ColumnType, mod typeModifier) (MemoryCell, error) {
+	switch {
+	case isTextType(from) && !isTextType(to):
+		mc, err := textToMemoryCell(textValue(mc, from), to)
+		if err != nil {
+			return nil, err
+		}
+
+		return convertCell(mc, to, to, mod)
+
+	case isTextType(to):
+		s := memoryCellToText(mc, from)
+		switch {
+		case isTextType(from):
+			s = textValue(mc, from)
+		case from == BoolType:
+			s = strconv.FormatBool(mc.AsBool())
+		}
+
+		if runes := []rune(s); mod.length > 0 && len(runes) > int(mod.length) {
+			s = string(runes[:mod.length])
+		}
+
+		return fitText(s, to, mod)
+
+	case from == BoolType && isIntegerType(to):
+		if mc.AsBool() {
+			return integerCell(1, to), nil
+		}
+		return integerCell(0, to), nil
+
+	case isIntegerType(from) && to == BoolType:
+		if mc.AsBigInt() != 0 {
+			return trueMemoryCell, nil
+		}
+		return falseMemoryCell, nil
+	}
+
+	return convertCell(mc, from, to, mod)
+}